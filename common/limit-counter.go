@@ -0,0 +1,67 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 未开启 Redis 时在本节点计数
+var (
+	localLimitCounters     = make(map[string]int64)
+	localLimitCounterTimes = make(map[string]int64)
+	localLimitCounterLock  sync.Mutex
+)
+
+// GetLimitCounter 读取限流计数，开启 Redis 时多节点共享
+func GetLimitCounter(key string) int64 {
+	if RedisEnabled {
+		value, err := RDB.Get(context.Background(), key).Int64()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			SysError("failed to get limit counter: " + err.Error())
+		}
+		return value
+	}
+	localLimitCounterLock.Lock()
+	defer localLimitCounterLock.Unlock()
+	if expireAt, ok := localLimitCounterTimes[key]; ok && expireAt < time.Now().Unix() {
+		return 0
+	}
+	return localLimitCounters[key]
+}
+
+// IncrLimitCounter 增加限流计数并刷新过期时间，返回增加后的值
+func IncrLimitCounter(key string, delta int64, expiration time.Duration) int64 {
+	if RedisEnabled {
+		ctx := context.Background()
+		pipe := RDB.TxPipeline()
+		incr := pipe.IncrBy(ctx, key, delta)
+		pipe.Expire(ctx, key, expiration)
+		if _, err := pipe.Exec(ctx); err != nil {
+			SysError("failed to update limit counter: " + err.Error())
+		}
+		return incr.Val()
+	}
+	now := time.Now().Unix()
+	localLimitCounterLock.Lock()
+	defer localLimitCounterLock.Unlock()
+	// 清理过期的计数
+	for counterKey, expireAt := range localLimitCounterTimes {
+		if expireAt < now {
+			delete(localLimitCounters, counterKey)
+			delete(localLimitCounterTimes, counterKey)
+		}
+	}
+	localLimitCounters[key] += delta
+	value := localLimitCounters[key]
+	if value <= 0 {
+		delete(localLimitCounters, key)
+		delete(localLimitCounterTimes, key)
+		return value
+	}
+	localLimitCounterTimes[key] = now + int64(expiration.Seconds())
+	return value
+}
@@ -0,0 +1,126 @@
+package common
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ModelPatternRegexPrefix 渠道模型与模型映射中以该前缀开头的名称按正则表达式完整匹配，
+// 例如 regex:^gpt-4o(-mini)?-\d{4}-\d{2}-\d{2}$；包含 * 的名称按通配符匹配，例如 claude-3-5-*。
+// 渠道模型以逗号分隔，正则表达式中不能包含逗号
+const ModelPatternRegexPrefix = "regex:"
+
+// 编译后的模式，无效的模式缓存为 nil
+var modelPatternCache sync.Map
+
+func IsModelPattern(name string) bool {
+	return strings.HasPrefix(name, ModelPatternRegexPrefix) || strings.Contains(name, "*")
+}
+
+func compileModelPattern(pattern string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(pattern, ModelPatternRegexPrefix) {
+		return regexp.Compile("^(?:" + strings.TrimPrefix(pattern, ModelPatternRegexPrefix) + ")$")
+	}
+	return regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$")
+}
+
+func getModelPattern(pattern string) *regexp.Regexp {
+	if re, ok := modelPatternCache.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := compileModelPattern(pattern)
+	if err != nil {
+		SysError("invalid model pattern " + pattern + ": " + err.Error())
+		re = nil
+	}
+	modelPatternCache.Store(pattern, re)
+	return re
+}
+
+// ValidateModelPattern 校验模型名称中的模式，普通的模型名称总是有效
+func ValidateModelPattern(name string) error {
+	if !IsModelPattern(name) {
+		return nil
+	}
+	_, err := compileModelPattern(name)
+	return err
+}
+
+// MatchModelPattern 判断模型名称是否匹配模式
+func MatchModelPattern(pattern string, name string) bool {
+	re := getModelPattern(pattern)
+	return re != nil && re.MatchString(name)
+}
+
+// MapModelName 按模型映射替换模型名称，精确匹配优先，其次按模式由长到短匹配；
+// 正则模式的映射值可以使用 $1 等引用分组，没有匹配时返回原名称
+func MapModelName(modelMap map[string]string, name string) string {
+	if mapped := modelMap[name]; mapped != "" {
+		return mapped
+	}
+	var patterns []string
+	for pattern, mapped := range modelMap {
+		if mapped != "" && IsModelPattern(pattern) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	for _, pattern := range patterns {
+		re := getModelPattern(pattern)
+		if re == nil || !re.MatchString(name) {
+			continue
+		}
+		if strings.HasPrefix(pattern, ModelPatternRegexPrefix) {
+			return re.ReplaceAllString(name, modelMap[pattern])
+		}
+		return modelMap[pattern]
+	}
+	return name
+}
+
+// ExpandModelPatterns 将模型列表中的模式展开为匹配的已知模型（列表中的模型以及设置了倍率或价格的模型），
+// 本身设置了倍率或价格的模式（如 gpt-4-gizmo-*）保留原样
+func ExpandModelPatterns(models []string) []string {
+	modelRatioMap := GetModelRatioMap()
+	modelPriceMap := GetModelPriceMap()
+	var patterns []string
+	expanded := make([]string, 0, len(models))
+	seen := make(map[string]bool)
+	for _, name := range models {
+		_, hasRatio := modelRatioMap[name]
+		_, hasPrice := modelPriceMap[name]
+		if IsModelPattern(name) && !hasRatio && !hasPrice {
+			patterns = append(patterns, name)
+		} else if !seen[name] {
+			seen[name] = true
+			expanded = append(expanded, name)
+		}
+	}
+	if len(patterns) == 0 {
+		return expanded
+	}
+	known := append([]string{}, expanded...)
+	for name := range modelRatioMap {
+		known = append(known, name)
+	}
+	for name := range modelPriceMap {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+	for _, pattern := range patterns {
+		for _, name := range known {
+			if !seen[name] && !IsModelPattern(name) && MatchModelPattern(pattern, name) {
+				seen[name] = true
+				expanded = append(expanded, name)
+			}
+		}
+	}
+	return expanded
+}
@@ -481,3 +481,133 @@ func GetCompletionRatioMap() map[string]float64 {
 	}
 	return CompletionRatio
 }
+
+// CacheRatio 命中缓存的输入 token 的计费倍率，CreateCacheRatio 写入缓存的输入 token 的计费倍率，
+// 均相对于模型倍率，未配置的模型为 1。各厂商的官方折扣只作为预置值，由管理员重置缓存倍率后才生效，
+// 避免升级后已有部署的计费被悄悄改变。Claude 例外：它的缓存 token 原本不计入输入用量，
+// 现在计入 prompt_tokens，未配置时直接使用官方的 0.1 与 1.25，否则缓存读取会按 10 倍的价格计费
+var CacheRatio map[string]float64 = nil
+var CreateCacheRatio map[string]float64 = nil
+
+const (
+	claudeCacheRatio       = 0.1
+	claudeCreateCacheRatio = 1.25
+)
+
+// defaultCacheRatio 各厂商公布的缓存读取价格相对输入价格的倍率
+// https://openai.com/api/pricing https://www.anthropic.com/pricing https://api-docs.deepseek.com/quick_start/pricing
+var defaultCacheRatio = map[string]float64{
+	"gpt-5":                      0.1,
+	"gpt-5-mini":                 0.1,
+	"gpt-5-nano":                 0.1,
+	"gpt-4.1":                    0.25,
+	"gpt-4.1-mini":               0.25,
+	"gpt-4.1-nano":               0.25,
+	"o3":                         0.25,
+	"o4-mini":                    0.25,
+	"gpt-4o":                     0.5,
+	"gpt-4o-2024-08-06":          0.5,
+	"gpt-4o-2024-11-20":          0.5,
+	"gpt-4o-mini":                0.5,
+	"gpt-4o-mini-2024-07-18":     0.5,
+	"chatgpt-4o-latest":          0.5,
+	"o1":                         0.5,
+	"o1-2024-12-17":              0.5,
+	"o1-mini":                    0.5,
+	"o3-mini":                    0.5,
+	"deepseek-chat":              0.1,
+	"deepseek-reasoner":          0.1,
+	"gemini-2.5-pro":             0.25,
+	"gemini-2.5-flash":           0.25,
+	"gemini-2.0-flash":           0.25,
+	"claude-3-haiku-20240307":    0.1,
+	"claude-3-opus-20240229":     0.1,
+	"claude-3-5-haiku-20241022":  0.1,
+	"claude-3-5-sonnet-20240620": 0.1,
+	"claude-3-5-sonnet-20241022": 0.1,
+	"claude-3-7-sonnet-20250219": 0.1,
+	"claude-sonnet-4-20250514":   0.1,
+	"claude-opus-4-20250514":     0.1,
+}
+
+// defaultCreateCacheRatio Claude 写入缓存（5 分钟）的价格为输入价格的 1.25 倍
+var defaultCreateCacheRatio = map[string]float64{
+	"claude-3-haiku-20240307":    1.25,
+	"claude-3-opus-20240229":     1.25,
+	"claude-3-5-haiku-20241022":  1.25,
+	"claude-3-5-sonnet-20240620": 1.25,
+	"claude-3-5-sonnet-20241022": 1.25,
+	"claude-3-7-sonnet-20250219": 1.25,
+	"claude-sonnet-4-20250514":   1.25,
+	"claude-opus-4-20250514":     1.25,
+}
+
+func DefaultCacheRatio2JSONString() string {
+	jsonBytes, err := json.Marshal(defaultCacheRatio)
+	if err != nil {
+		SysError("error marshalling cache ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func DefaultCreateCacheRatio2JSONString() string {
+	jsonBytes, err := json.Marshal(defaultCreateCacheRatio)
+	if err != nil {
+		SysError("error marshalling create cache ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func CacheRatio2JSONString() string {
+	if CacheRatio == nil {
+		CacheRatio = make(map[string]float64)
+	}
+	jsonBytes, err := json.Marshal(CacheRatio)
+	if err != nil {
+		SysError("error marshalling cache ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateCacheRatioByJSONString(jsonStr string) error {
+	CacheRatio = make(map[string]float64)
+	return json.Unmarshal([]byte(jsonStr), &CacheRatio)
+}
+
+// GetCacheRatio 返回模型命中缓存的输入 token 的倍率，未配置时 Claude 模型为 0.1，其他模型为 1
+func GetCacheRatio(name string) float64 {
+	if ratio, ok := CacheRatio[name]; ok {
+		return ratio
+	}
+	if strings.Contains(name, "claude") {
+		return claudeCacheRatio
+	}
+	return 1
+}
+
+func CreateCacheRatio2JSONString() string {
+	if CreateCacheRatio == nil {
+		CreateCacheRatio = make(map[string]float64)
+	}
+	jsonBytes, err := json.Marshal(CreateCacheRatio)
+	if err != nil {
+		SysError("error marshalling create cache ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateCreateCacheRatioByJSONString(jsonStr string) error {
+	CreateCacheRatio = make(map[string]float64)
+	return json.Unmarshal([]byte(jsonStr), &CreateCacheRatio)
+}
+
+// GetCreateCacheRatio 返回模型写入缓存的输入 token 的倍率，未配置时 Claude 模型为 1.25，其他模型为 1
+func GetCreateCacheRatio(name string) float64 {
+	if ratio, ok := CreateCacheRatio[name]; ok {
+		return ratio
+	}
+	if strings.Contains(name, "claude") {
+		return claudeCreateCacheRatio
+	}
+	return 1
+}
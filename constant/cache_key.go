@@ -11,10 +11,11 @@ var (
 
 // Cache keys
 const (
-	UserGroupKeyFmt    = "user_group:%d"
-	UserQuotaKeyFmt    = "user_quota:%d"
-	UserEnabledKeyFmt  = "user_enabled:%d"
-	UserUsernameKeyFmt = "user_name:%d"
+	UserGroupKeyFmt     = "user_group:%d"
+	UserQuotaKeyFmt     = "user_quota:%d"
+	UserEnabledKeyFmt   = "user_enabled:%d"
+	UserUsernameKeyFmt  = "user_name:%d"
+	UserRateLimitKeyFmt = "user_rate_limit:%d"
 )
 
 const (
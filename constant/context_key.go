@@ -2,4 +2,5 @@ package constant
 
 const (
 	ContextKeyRequestStartTime = "request_start_time"
+	ContextKeyBatchId          = "batch_id"
 )
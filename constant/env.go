@@ -13,6 +13,15 @@ var DifyDebug = common.GetEnvOrDefaultBool("DIFY_DEBUG", true)
 
 var MaxFileDownloadMB = common.GetEnvOrDefault("MAX_FILE_DOWNLOAD_MB", 20)
 
+// FileStorageType Files API 的存储后端，目前支持 local
+var FileStorageType = common.GetEnvOrDefaultString("FILE_STORAGE_TYPE", "local")
+var FileStoragePath = common.GetEnvOrDefaultString("FILE_STORAGE_PATH", "./data/files")
+
+var MaxFileUploadMB = common.GetEnvOrDefault("MAX_FILE_UPLOAD_MB", 512)
+
+// UserFileStorageQuotaMB 每个用户可占用的文件存储空间，0 表示不限制
+var UserFileStorageQuotaMB = common.GetEnvOrDefault("USER_FILE_STORAGE_QUOTA_MB", 1024)
+
 // ForceStreamOption 覆盖请求参数，强制返回usage信息
 var ForceStreamOption = common.GetEnvOrDefaultBool("FORCE_STREAM_OPTION", true)
 
@@ -22,6 +31,16 @@ var GetMediaTokenNotStream = common.GetEnvOrDefaultBool("GET_MEDIA_TOKEN_NOT_STR
 
 var UpdateTask = common.GetEnvOrDefaultBool("UPDATE_TASK", true)
 
+// BatchWorkerCount 同时执行的 batch 数量，BatchRequestConcurrency 为单个 batch 内的请求并发数
+var BatchWorkerCount = common.GetEnvOrDefault("BATCH_WORKER_COUNT", 2)
+var BatchRequestConcurrency = common.GetEnvOrDefault("BATCH_REQUEST_CONCURRENCY", 4)
+
+var BatchMaxRequests = common.GetEnvOrDefault("BATCH_MAX_REQUESTS", 50000)
+
+// ResponseCacheMemoryEntries 未启用 Redis 时内存中最多缓存的响应数，ResponseCacheMaxEntryKB 为单个响应的大小上限
+var ResponseCacheMemoryEntries = common.GetEnvOrDefault("RESPONSE_CACHE_MEMORY_ENTRIES", 1000)
+var ResponseCacheMaxEntryKB = common.GetEnvOrDefault("RESPONSE_CACHE_MAX_ENTRY_KB", 512)
+
 var GeminiModelMap = map[string]string{
 	"gemini-1.0-pro": "v1",
 }
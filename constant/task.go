@@ -5,6 +5,7 @@ type TaskPlatform string
 const (
 	TaskPlatformSuno       TaskPlatform = "suno"
 	TaskPlatformMidjourney              = "mj"
+	TaskPlatformFineTuning TaskPlatform = "fine_tuning"
 )
 
 const (
@@ -12,6 +13,10 @@ const (
 	SunoActionLyrics = "LYRICS"
 )
 
+const (
+	FineTuningActionTrain = "TRAIN"
+)
+
 var SunoModel2Action = map[string]string{
 	"suno_music":  SunoActionMusic,
 	"suno_lyrics": SunoActionLyrics,
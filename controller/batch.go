@@ -0,0 +1,516 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting"
+	"strconv"
+	"sync"
+	"time"
+
+	"one-api/logging")
+
+var batchEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+}
+
+const batchCompletionWindow = "24h"
+
+func CreateBatch(c *gin.Context) {
+	userId := c.GetInt("id")
+	var request dto.BatchRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&request); err != nil {
+		fileErrorResponse(c, err, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	if !batchEndpoints[request.Endpoint] {
+		fileErrorResponse(c, fmt.Errorf("unsupported endpoint: %s", request.Endpoint), "invalid_endpoint", http.StatusBadRequest)
+		return
+	}
+	if request.CompletionWindow != batchCompletionWindow {
+		fileErrorResponse(c, fmt.Errorf("completion_window must be %s", batchCompletionWindow), "invalid_completion_window", http.StatusBadRequest)
+		return
+	}
+	file, exist, err := model.GetUserFileByFileId(userId, request.InputFileId)
+	if err != nil {
+		fileErrorResponse(c, err, "query_file_failed", http.StatusInternalServerError)
+		return
+	}
+	if !exist {
+		fileErrorResponse(c, fmt.Errorf("No such File object: %s", request.InputFileId), "file_not_found", http.StatusNotFound)
+		return
+	}
+	if file.Purpose != "batch" {
+		fileErrorResponse(c, errors.New("input file must be uploaded with purpose batch"), "invalid_input_file", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	batch := &model.Batch{
+		BatchId:          "batch_" + common.GetUUID(),
+		UserId:           userId,
+		TokenId:          c.GetInt("token_id"),
+		Endpoint:         request.Endpoint,
+		CompletionWindow: request.CompletionWindow,
+		InputFileId:      request.InputFileId,
+		Status:           model.BatchStatusValidating,
+		Progress:         "0%",
+		ExpiresAt:        now.Add(24 * time.Hour).Unix(),
+	}
+	if len(request.Metadata) > 0 {
+		batch.Metadata, _ = json.Marshal(request.Metadata)
+	}
+	if err = batch.Insert(); err != nil {
+		fileErrorResponse(c, err, "insert_batch_failed", http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, batch.ToOpenAIBatch())
+}
+
+func getUserBatchOrAbort(c *gin.Context) *model.Batch {
+	batchId := c.Param("id")
+	batch, exist, err := model.GetUserBatchByBatchId(c.GetInt("id"), batchId)
+	if err != nil {
+		fileErrorResponse(c, err, "query_batch_failed", http.StatusInternalServerError)
+		return nil
+	}
+	if !exist {
+		fileErrorResponse(c, fmt.Errorf("No such Batch object: %s", batchId), "batch_not_found", http.StatusNotFound)
+		return nil
+	}
+	return batch
+}
+
+func RetrieveBatch(c *gin.Context) {
+	batch := getUserBatchOrAbort(c)
+	if batch == nil {
+		return
+	}
+	c.JSON(http.StatusOK, batch.ToOpenAIBatch())
+}
+
+func ListBatches(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	batches, err := model.GetUserBatches(c.GetInt("id"), c.Query("after"), limit+1)
+	if err != nil {
+		fileErrorResponse(c, err, "query_batches_failed", http.StatusInternalServerError)
+		return
+	}
+	hasMore := len(batches) > limit
+	if hasMore {
+		batches = batches[:limit]
+	}
+	response := dto.OpenAIBatchListResponse{
+		Object:  "list",
+		Data:    make([]dto.OpenAIBatch, 0, len(batches)),
+		HasMore: hasMore,
+	}
+	for _, batch := range batches {
+		response.Data = append(response.Data, batch.ToOpenAIBatch())
+	}
+	if len(batches) > 0 {
+		response.FirstId = batches[0].BatchId
+		response.LastId = batches[len(batches)-1].BatchId
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func CancelBatch(c *gin.Context) {
+	batch := getUserBatchOrAbort(c)
+	if batch == nil {
+		return
+	}
+	if batch.Status != model.BatchStatusValidating && batch.Status != model.BatchStatusInProgress {
+		fileErrorResponse(c, fmt.Errorf("cannot cancel batch with status %s", batch.Status), "invalid_batch_status", http.StatusConflict)
+		return
+	}
+	if err := model.CancelBatch(batch); err != nil {
+		fileErrorResponse(c, err, "cancel_batch_failed", http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, batch.ToOpenAIBatch())
+}
+
+// UpdateBatchBulk 在主节点上轮询待执行的 batch，最多同时执行 BatchWorkerCount 个
+func UpdateBatchBulk() {
+	if err := model.RecoverInterruptedBatches(); err != nil {
+		logging.SysError("recover interrupted batches failed: " + err.Error())
+	}
+	workerCount := constant.BatchWorkerCount
+	if workerCount <= 0 {
+		workerCount = 1
+	}
+	workers := make(chan struct{}, workerCount)
+	for {
+		workers <- struct{}{}
+		batch, err := model.ClaimPendingBatch()
+		if err != nil {
+			logging.SysError("claim pending batch failed: " + err.Error())
+		}
+		if batch == nil {
+			<-workers
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		gopool.Go(func() {
+			defer func() {
+				<-workers
+			}()
+			runBatch(batch)
+		})
+	}
+}
+
+type batchRunner struct {
+	batch   *model.Batch
+	token   *model.Token
+	group   string
+	lines   []*dto.BatchRequestLine
+	lock    sync.Mutex
+	output  bytes.Buffer
+	errOut  bytes.Buffer
+	checkAt time.Time
+}
+
+func failBatch(batch *model.Batch, reason string) {
+	batch.Status = model.BatchStatusFailed
+	batch.FailReason = reason
+	batch.Progress = "100%"
+	batch.FinishTime = time.Now().Unix()
+	updated, err := model.UpdateBatchUnlessCancelled(batch, map[string]any{
+		"status":      batch.Status,
+		"fail_reason": batch.FailReason,
+		"progress":    batch.Progress,
+		"finish_time": batch.FinishTime,
+	})
+	if err != nil {
+		logging.SysError(fmt.Sprintf("update batch %s failed: %s", batch.BatchId, err.Error()))
+		return
+	}
+	if !updated {
+		// 并发的取消请求先生效，以取消为准
+		if err := model.FinishCancellingBatch(batch); err != nil {
+			logging.SysError(fmt.Sprintf("update batch %s failed: %s", batch.BatchId, err.Error()))
+		}
+	}
+}
+
+func runBatch(batch *model.Batch) {
+	common.SysLog(fmt.Sprintf("batch %s started", batch.BatchId))
+	runner := &batchRunner{batch: batch}
+	if err := runner.prepare(); err != nil {
+		failBatch(batch, err.Error())
+		common.SysLog(fmt.Sprintf("batch %s failed: %s", batch.BatchId, err.Error()))
+		return
+	}
+	batch.TotalCount = len(runner.lines)
+	batch.UpdateProgress()
+	if err := model.UpdateBatchProgress(batch); err != nil {
+		logging.SysError(fmt.Sprintf("update batch %s failed: %s", batch.BatchId, err.Error()))
+	}
+	finalStatus := runner.run()
+
+	if finalStatus != model.BatchStatusCancelled {
+		batch.Status = model.BatchStatusFinalizing
+		batch.FinalizingAt = time.Now().Unix()
+		updated, err := model.UpdateBatchUnlessCancelled(batch, map[string]any{
+			"status":        batch.Status,
+			"finalizing_at": batch.FinalizingAt,
+		})
+		if err != nil {
+			logging.SysError(fmt.Sprintf("update batch %s failed: %s", batch.BatchId, err.Error()))
+		} else if !updated {
+			// 执行结束前收到取消请求，以取消为准
+			finalStatus = model.BatchStatusCancelled
+		}
+	}
+	if runner.output.Len() > 0 {
+		file, err := service.SaveUserFile(batch.UserId, batch.TokenId, batch.BatchId+"_output.jsonl", "batch_output", &runner.output)
+		if err != nil {
+			failBatch(batch, "save output file failed: "+err.Error())
+			return
+		}
+		batch.OutputFileId = file.FileId
+	}
+	if runner.errOut.Len() > 0 {
+		file, err := service.SaveUserFile(batch.UserId, batch.TokenId, batch.BatchId+"_error.jsonl", "batch_output", &runner.errOut)
+		if err != nil {
+			failBatch(batch, "save error file failed: "+err.Error())
+			return
+		}
+		batch.ErrorFileId = file.FileId
+	}
+	if finalStatus != model.BatchStatusCancelled {
+		batch.Progress = "100%"
+		batch.FinishTime = time.Now().Unix()
+		updated, err := model.UpdateBatchUnlessCancelled(batch, map[string]any{
+			"status":          finalStatus,
+			"output_file_id":  batch.OutputFileId,
+			"error_file_id":   batch.ErrorFileId,
+			"completed_count": batch.CompletedCount,
+			"failed_count":    batch.FailedCount,
+			"progress":        batch.Progress,
+			"finish_time":     batch.FinishTime,
+		})
+		if err != nil {
+			logging.SysError(fmt.Sprintf("update batch %s failed: %s", batch.BatchId, err.Error()))
+		} else if updated {
+			batch.Status = finalStatus
+		} else {
+			finalStatus = model.BatchStatusCancelled
+		}
+	}
+	if finalStatus == model.BatchStatusCancelled {
+		if err := model.FinishCancellingBatch(batch); err != nil {
+			logging.SysError(fmt.Sprintf("update batch %s failed: %s", batch.BatchId, err.Error()))
+		}
+	}
+	common.SysLog(fmt.Sprintf("batch %s %s, completed %d, failed %d", batch.BatchId, finalStatus, batch.CompletedCount, batch.FailedCount))
+}
+
+// prepare 校验令牌和输入文件，解析出所有请求
+func (r *batchRunner) prepare() error {
+	batch := r.batch
+	token, err := model.GetTokenById(batch.TokenId)
+	if err != nil {
+		return errors.New("token not found")
+	}
+	if token, err = model.ValidateUserToken(token.Key); err != nil {
+		return err
+	}
+	userEnabled, err := model.IsUserEnabled(batch.UserId, false)
+	if err != nil {
+		return err
+	}
+	if !userEnabled {
+		return errors.New("用户已被封禁")
+	}
+	r.token = token
+
+	group, err := model.GetUserGroup(batch.UserId, false)
+	if err != nil {
+		return err
+	}
+	if token.Group != "" {
+		if _, ok := setting.GetUserUsableGroups(group)[token.Group]; !ok {
+			return fmt.Errorf("令牌分组 %s 已被禁用", token.Group)
+		}
+		if !setting.ContainsGroupRatio(token.Group) {
+			return fmt.Errorf("分组 %s 已被弃用", token.Group)
+		}
+		group = token.Group
+	}
+	r.group = group
+
+	file, exist, err := model.GetUserFileByFileId(batch.UserId, batch.InputFileId)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return fmt.Errorf("input file %s not found", batch.InputFileId)
+	}
+	storage, err := service.GetFileStorageByName(file.Storage)
+	if err != nil {
+		return err
+	}
+	reader, err := storage.Open(file.StoragePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	customIds := make(map[string]bool)
+	bufReader := bufio.NewReader(reader)
+	for lineNum := 1; ; lineNum++ {
+		data, readErr := bufReader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) > 0 {
+			line := &dto.BatchRequestLine{}
+			if err = json.Unmarshal(data, line); err != nil {
+				return fmt.Errorf("line %d: invalid json", lineNum)
+			}
+			if line.CustomId == "" || customIds[line.CustomId] {
+				return fmt.Errorf("line %d: custom_id is empty or duplicated", lineNum)
+			}
+			customIds[line.CustomId] = true
+			if line.Method != http.MethodPost {
+				return fmt.Errorf("line %d: method must be POST", lineNum)
+			}
+			if line.Url != batch.Endpoint {
+				return fmt.Errorf("line %d: url %s does not match batch endpoint %s", lineNum, line.Url, batch.Endpoint)
+			}
+			var body struct {
+				Model  string `json:"model"`
+				Stream bool   `json:"stream"`
+			}
+			if err = json.Unmarshal(line.Body, &body); err != nil || body.Model == "" {
+				return fmt.Errorf("line %d: body must be a json object with model", lineNum)
+			}
+			if body.Stream {
+				return fmt.Errorf("line %d: stream is not supported in batch", lineNum)
+			}
+			r.lines = append(r.lines, line)
+			if len(r.lines) > constant.BatchMaxRequests {
+				return fmt.Errorf("batch exceeds max requests %d", constant.BatchMaxRequests)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	if len(r.lines) == 0 {
+		return errors.New("input file is empty")
+	}
+	return nil
+}
+
+// checkStatus 定期从数据库读取状态，返回 batch 是否应当停止以及停止后的状态
+func (r *batchRunner) checkStatus() (bool, model.BatchStatus) {
+	if time.Now().Unix() > r.batch.ExpiresAt {
+		return true, model.BatchStatusExpired
+	}
+	if time.Since(r.checkAt) < 3*time.Second {
+		return false, ""
+	}
+	r.checkAt = time.Now()
+	r.lock.Lock()
+	r.batch.UpdateProgress()
+	err := model.UpdateBatchProgress(r.batch)
+	r.lock.Unlock()
+	if err != nil {
+		logging.SysError(fmt.Sprintf("update batch %s progress failed: %s", r.batch.BatchId, err.Error()))
+	}
+	current, err := model.GetBatchById(r.batch.ID)
+	if err == nil && current.Status == model.BatchStatusCancelling {
+		r.batch.CancellingAt = current.CancellingAt
+		return true, model.BatchStatusCancelled
+	}
+	return false, ""
+}
+
+func (r *batchRunner) run() model.BatchStatus {
+	concurrency := constant.BatchRequestConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	finalStatus := model.BatchStatusCompleted
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, line := range r.lines {
+		if stop, status := r.checkStatus(); stop {
+			finalStatus = status
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		line := line
+		gopool.Go(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			r.relayLine(line)
+		})
+	}
+	wg.Wait()
+	return finalStatus
+}
+
+func (r *batchRunner) relayLine(line *dto.BatchRequestLine) {
+	statusCode, body, requestId := relayBatchRequest(r.batch, r.token, r.group, line)
+	if !json.Valid(body) {
+		body, _ = json.Marshal(string(body))
+	}
+	result := dto.BatchResponseLine{
+		Id:       "batch_req_" + common.GetUUID(),
+		CustomId: line.CustomId,
+		Response: &dto.BatchResponseBody{
+			StatusCode: statusCode,
+			RequestId:  requestId,
+			Body:       body,
+		},
+	}
+	data, _ := json.Marshal(result)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if statusCode == http.StatusOK {
+		r.output.Write(data)
+		r.output.WriteByte('\n')
+		r.batch.CompletedCount++
+	} else {
+		r.errOut.Write(data)
+		r.errOut.WriteByte('\n')
+		r.batch.FailedCount++
+	}
+}
+
+// relayBatchRequest 构造与 TokenAuth、Distribute 一致的上下文，交由 Relay 处理单个请求
+func relayBatchRequest(batch *model.Batch, token *model.Token, group string, line *dto.BatchRequestLine) (int, json.RawMessage, string) {
+	requestId := common.GetTimeString() + common.GetRandomString(8)
+	writer := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(writer)
+	req, _ := http.NewRequestWithContext(context.WithValue(context.Background(), common.RequestIdKey, requestId),
+		http.MethodPost, line.Url, bytes.NewReader(line.Body))
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+	c.Set(common.RequestIdKey, requestId)
+
+	// 与 TokenAuth、Distribute、RelayRateLimit 保持一致：令牌信息、模型别名与限制、渠道选择、限流
+	middleware.SetupContextForToken(c, token)
+	c.Set("group", group)
+	c.Set(constant.ContextKeyBatchId, batch.BatchId)
+
+	localError := func(err error, code string, statusCode int) (int, json.RawMessage, string) {
+		openaiErr := service.OpenAIErrorWrapperLocal(err, code, statusCode)
+		data, _ := json.Marshal(gin.H{"error": openaiErr.Error})
+		return statusCode, data, requestId
+	}
+	var modelRequest middleware.ModelRequest
+	if err := json.Unmarshal(line.Body, &modelRequest); err != nil {
+		return localError(err, "invalid_request", http.StatusBadRequest)
+	}
+	middleware.ResolveRequestModelAlias(c, group, &modelRequest)
+	if selectErr := middleware.CheckTokenModelLimit(c, modelRequest.Model); selectErr != nil {
+		return localError(errors.New(selectErr.Message), "model_not_allowed", selectErr.StatusCode)
+	}
+	channel, selectErr := middleware.SelectRequestChannel(c, group, &modelRequest)
+	if selectErr != nil {
+		return localError(errors.New(selectErr.Message), "get_channel_failed", selectErr.StatusCode)
+	}
+	if scopes := service.GetRelayRateLimitScopes(c); len(scopes) > 0 {
+		// batch 不支持流式请求，只检查每分钟请求数与 token 数
+		release, limitErr := service.AcquireRelayRateLimit(c, scopes, false)
+		if limitErr != nil {
+			return localError(errors.New(limitErr.Message), "rate_limit_exceeded", http.StatusTooManyRequests)
+		}
+		defer release()
+	}
+	c.Set(constant.ContextKeyRequestStartTime, time.Now())
+	middleware.SetupContextForSelectedChannel(c, channel, modelRequest.Model)
+
+	Relay(c)
+	return writer.Code, writer.Body.Bytes(), requestId
+}
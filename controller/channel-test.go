@@ -29,16 +29,16 @@ import (
 
 	"one-api/logging")
 
-func testChannel(channel *model.Channel, testModel string) (err error, openAIErrorWithStatusCode *dto.OpenAIErrorWithStatusCode) {
+func testChannel(channel *model.Channel, testModel string) (err error, openAIErrorWithStatusCode *dto.OpenAIErrorWithStatusCode, channelKeyId int) {
 	tik := time.Now()
 	if channel.Type == common.ChannelTypeMidjourney {
-		return errors.New("midjourney channel test is not supported"), nil
+		return errors.New("midjourney channel test is not supported"), nil, channelKeyId
 	}
 	if channel.Type == common.ChannelTypeMidjourneyPlus {
-		return errors.New("midjourney plus channel test is not supported!!!"), nil
+		return errors.New("midjourney plus channel test is not supported!!!"), nil, channelKeyId
 	}
 	if channel.Type == common.ChannelTypeSunoAPI {
-		return errors.New("suno channel test is not supported"), nil
+		return errors.New("suno channel test is not supported"), nil, channelKeyId
 	}
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -53,10 +53,13 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 		if channel.TestModel != nil && *channel.TestModel != "" {
 			testModel = *channel.TestModel
 		} else {
-			if len(channel.GetModels()) > 0 {
-				testModel = channel.GetModels()[0]
-			} else {
-				testModel = "gpt-3.5-turbo"
+			// 通配符或正则表达式不能作为模型名称请求上游，取第一个具体的模型
+			testModel = "gpt-3.5-turbo"
+			for _, modelName := range channel.GetModels() {
+				if !common.IsModelPattern(modelName) {
+					testModel = modelName
+					break
+				}
 			}
 		}
 	} else {
@@ -65,10 +68,10 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 			modelMap := make(map[string]string)
 			err := json.Unmarshal([]byte(modelMapping), &modelMap)
 			if err != nil {
-				return err, service.OpenAIErrorWrapperLocal(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
+				return err, service.OpenAIErrorWrapperLocal(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError), channelKeyId
 			}
-			if modelMap[testModel] != "" {
-				testModel = modelMap[testModel]
+			if mapped := common.MapModelName(modelMap, testModel); mapped != testModel {
+				testModel = mapped
 			}
 		}
 	}
@@ -79,12 +82,13 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 	c.Set("base_url", channel.GetBaseURL())
 
 	middleware.SetupContextForSelectedChannel(c, channel, testModel)
+	channelKeyId = c.GetInt("channel_key_id")
 
 	meta := relaycommon.GenRelayInfo(c)
 	apiType, _ := constant.ChannelType2APIType(channel.Type)
 	adaptor := relay.GetAdaptor(apiType)
 	if adaptor == nil {
-		return fmt.Errorf("invalid api type: %d, adaptor is nil", apiType), nil
+		return fmt.Errorf("invalid api type: %d, adaptor is nil", apiType), nil, channelKeyId
 	}
 
 	request := buildTestRequest(testModel)
@@ -95,38 +99,42 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 
 	convertedRequest, err := adaptor.ConvertRequest(c, meta, request)
 	if err != nil {
-		return err, nil
+		return err, nil, channelKeyId
 	}
 	jsonData, err := json.Marshal(convertedRequest)
 	if err != nil {
-		return err, nil
+		return err, nil, channelKeyId
+	}
+	jsonData, err = meta.ApplyParamPolicy(jsonData)
+	if err != nil {
+		return err, nil, channelKeyId
 	}
 	requestBody := bytes.NewBuffer(jsonData)
 	c.Request.Body = io.NopCloser(requestBody)
 	resp, err := adaptor.DoRequest(c, meta, requestBody)
 	if err != nil {
-		return err, nil
+		return err, nil, channelKeyId
 	}
 	var httpResp *http.Response
 	if resp != nil {
 		httpResp = resp.(*http.Response)
 		if httpResp.StatusCode != http.StatusOK {
 			err := service.RelayErrorHandler(httpResp)
-			return fmt.Errorf("status code %d: %s", httpResp.StatusCode, err.Error.Message), err
+			return fmt.Errorf("status code %d: %s", httpResp.StatusCode, err.Error.Message), err, channelKeyId
 		}
 	}
 	usageA, respErr := adaptor.DoResponse(c, httpResp, meta)
 	if respErr != nil {
-		return fmt.Errorf("%s", respErr.Error.Message), respErr
+		return fmt.Errorf("%s", respErr.Error.Message), respErr, channelKeyId
 	}
 	if usageA == nil {
-		return errors.New("usage is nil"), nil
+		return errors.New("usage is nil"), nil, channelKeyId
 	}
 	usage := usageA.(*dto.Usage)
 	result := w.Result()
 	respBody, err := io.ReadAll(result.Body)
 	if err != nil {
-		return err, nil
+		return err, nil, channelKeyId
 	}
 	modelPrice, usePrice := common.GetModelPrice(testModel, false)
 	modelRatio := common.GetModelRatio(testModel)
@@ -149,7 +157,7 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 	model.RecordConsumeLog(c, 1, channel.Id, usage.PromptTokens, usage.CompletionTokens, testModel, "模型测试",
 		quota, "模型测试", 0, quota, int(consumedTime), false, "default", other)
 	common.SysLog(fmt.Sprintf("testing channel #%d, response: \n%s", channel.Id, string(respBody)))
-	return nil, nil
+	return nil, nil, channelKeyId
 }
 
 func buildTestRequest(model string) *dto.GeneralOpenAIRequest {
@@ -193,7 +201,7 @@ func TestChannel(c *gin.Context) {
 	}
 	testModel := c.Query("model")
 	tik := time.Now()
-	err, _ = testChannel(channel, testModel)
+	err, _, _ = testChannel(channel, testModel)
 	tok := time.Now()
 	milliseconds := tok.Sub(tik).Milliseconds()
 	go channel.UpdateResponseTime(milliseconds)
@@ -240,7 +248,7 @@ func testAllChannels(notify bool) error {
 		for _, channel := range channels {
 			isChannelEnabled := channel.Status == common.ChannelStatusEnabled
 			tik := time.Now()
-			err, openaiWithStatusErr := testChannel(channel, "")
+			err, openaiWithStatusErr, channelKeyId := testChannel(channel, "")
 			tok := time.Now()
 			milliseconds := tok.Sub(tik).Milliseconds()
 
@@ -260,7 +268,12 @@ func testAllChannels(notify bool) error {
 
 			// disable channel
 			if isChannelEnabled && shouldBanChannel && channel.GetAutoBan() {
-				service.DisableChannel(channel.Id, channel.Name, err.Error())
+				if channelKeyId != 0 {
+					// 多密钥渠道只禁用测试所用的密钥
+					service.DisableChannelKey(channel.Id, channelKeyId, channel.Name, err.Error())
+				} else {
+					service.DisableChannel(channel.Id, channel.Name, err.Error())
+				}
 			}
 
 			// enable channel
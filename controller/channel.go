@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
 	"strconv"
 	"strings"
 	"sync"
@@ -175,7 +177,7 @@ func FetchUpstreamModels(c *gin.Context) {
 		baseURL = channel.GetBaseURL()
 	}
 	url := fmt.Sprintf("%s/v1/models", baseURL)
-	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.GetFirstKey()))
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -338,6 +340,19 @@ func AddChannel(c *gin.Context) {
 
 	// Split and validate keys
 	keys := strings.Split(channel.Key, "\n")
+	if channel.IsMultiKey() {
+		// 多密钥渠道的所有密钥保存在同一个渠道中
+		for _, key := range channel.GetKeys() {
+			if len(key) > maxKeyLength {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"message": fmt.Sprintf("Key too long. Maximum length is %d", maxKeyLength),
+				})
+				return
+			}
+		}
+		keys = []string{channel.Key}
+	}
 	if len(keys) > maxBatchSize {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
@@ -376,7 +391,9 @@ func AddChannel(c *gin.Context) {
 		}
 
 		localChannel := channel
-		localChannel.Key = key
+		if !channel.IsMultiKey() {
+			localChannel.Key = key
+		}
 
 		if err := validateModels(&localChannel); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -432,9 +449,52 @@ func validateChannel(channel *model.Channel) error {
 	if channel.Key == "" {
 		return fmt.Errorf("channel key is required")
 	}
-	if len(channel.Key) > maxKeyLength {
+	if !model.IsValidChannelMultiKeyMode(channel.MultiKeyMode) {
+		return fmt.Errorf("invalid multi key mode: %s", channel.MultiKeyMode)
+	}
+	if !channel.IsMultiKey() && len(channel.Key) > maxKeyLength {
 		return fmt.Errorf("key too long, maximum length is %d", maxKeyLength)
 	}
+	if err := validateChannelModelPatterns(channel.Models, channel.ModelMapping); err != nil {
+		return err
+	}
+	return validateChannelSetting(channel.Setting)
+}
+
+// validateChannelSetting 校验渠道 Setting 中的参数策略与出站网络设置
+func validateChannelSetting(setting string) error {
+	if setting == "" {
+		return nil
+	}
+	channelSetting := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(setting), &channelSetting); err != nil {
+		return fmt.Errorf("invalid channel setting: %s", err.Error())
+	}
+	if _, err := relaycommon.GetParamPolicy(channelSetting); err != nil {
+		return err
+	}
+	return service.CheckChannelNetworkSetting(channelSetting)
+}
+
+// validateChannelModelPatterns 校验渠道模型与模型映射中的通配符和正则表达式
+func validateChannelModelPatterns(models string, modelMapping *string) error {
+	for _, name := range strings.Split(models, ",") {
+		if err := common.ValidateModelPattern(name); err != nil {
+			return fmt.Errorf("invalid model pattern %s: %s", name, err.Error())
+		}
+	}
+	if modelMapping == nil || *modelMapping == "" || *modelMapping == "{}" {
+		return nil
+	}
+	modelMap := make(map[string]string)
+	if err := json.Unmarshal([]byte(*modelMapping), &modelMap); err != nil {
+		return fmt.Errorf("invalid model mapping: %s", err.Error())
+	}
+	for name := range modelMap {
+		if err := common.ValidateModelPattern(name); err != nil {
+			return fmt.Errorf("invalid model pattern %s: %s", name, err.Error())
+		}
+	}
 	return nil
 }
 
@@ -590,6 +650,17 @@ func EditTagChannels(c *gin.Context) {
 		})
 		return
 	}
+	models := ""
+	if channelTag.Models != nil {
+		models = *channelTag.Models
+	}
+	if err := validateChannelModelPatterns(models, channelTag.ModelMapping); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	err = model.EditChannelByTag(channelTag.Tag, channelTag.NewTag, channelTag.ModelMapping, channelTag.Models, channelTag.Groups, channelTag.Priority, channelTag.Weight)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -646,6 +717,27 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	if !model.IsValidChannelMultiKeyMode(channel.MultiKeyMode) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的多密钥选择方式",
+		})
+		return
+	}
+	if err := validateChannelModelPatterns(channel.Models, channel.ModelMapping); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := validateChannelSetting(channel.Setting); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	if channel.Type == common.ChannelTypeVertexAi {
 		if channel.Other == "" {
 			c.JSON(http.StatusOK, gin.H{
@@ -784,3 +876,125 @@ func BatchSetChannelTag(c *gin.Context) {
 	})
 	return
 }
+
+// GetChannelBreakers 查询渠道熔断器状态，可通过 channel_id 只查询单个渠道
+func GetChannelBreakers(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.GetChannelBreakers(channelId),
+	})
+}
+
+func GetRelayQueueStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    service.GetRelayQueueStats(),
+	})
+}
+
+func ResetChannelBreaker(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	model.ResetChannelBreaker(channelId)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// maskChannelKey 只显示密钥的首尾，避免在列表中泄露完整密钥
+func maskChannelKey(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:4] + strings.Repeat("*", 8) + key[len(key)-4:]
+}
+
+// GetChannelKeys 查询多密钥渠道中每个密钥的状态与用量
+func GetChannelKeys(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	keys, err := model.GetChannelKeys(channelId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	for _, key := range keys {
+		key.Key = maskChannelKey(key.Key)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    keys,
+	})
+}
+
+// UpdateChannelKeyStatus 手动启用或禁用多密钥渠道中的单个密钥
+func UpdateChannelKeyStatus(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	keyId, err := strconv.Atoi(c.Param("key_id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	var req struct {
+		Status int `json:"status"`
+	}
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if req.Status != common.ChannelStatusEnabled && req.Status != common.ChannelStatusManuallyDisabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的密钥状态",
+		})
+		return
+	}
+	reason := ""
+	if req.Status != common.ChannelStatusEnabled {
+		reason = "手动禁用"
+	}
+	if _, err = model.UpdateChannelKeyStatus(channelId, keyId, req.Status, reason); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+	"one-api/logging")
+
+var validFilePurposes = map[string]bool{
+	"assistants": true,
+	"batch":      true,
+	"fine-tune":  true,
+	"vision":     true,
+	"user_data":  true,
+	"evals":      true,
+}
+
+func fileErrorResponse(c *gin.Context, err error, code string, statusCode int) {
+	openaiErr := service.OpenAIErrorWrapperLocal(err, code, statusCode)
+	openaiErr.Error.Type = "invalid_request_error"
+	c.JSON(statusCode, gin.H{
+		"error": openaiErr.Error,
+	})
+}
+
+func getRequestGroup(c *gin.Context) (string, error) {
+	group := c.GetString("token_group")
+	if group != "" {
+		return group, nil
+	}
+	return model.GetUserGroup(c.GetInt("id"), false)
+}
+
+func UploadFile(c *gin.Context) {
+	userId := c.GetInt("id")
+	purpose := c.PostForm("purpose")
+	if !validFilePurposes[purpose] {
+		fileErrorResponse(c, fmt.Errorf("invalid purpose: %s", purpose), "invalid_purpose", http.StatusBadRequest)
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		fileErrorResponse(c, errors.New("file is required"), "invalid_file", http.StatusBadRequest)
+		return
+	}
+	if header.Size > int64(constant.MaxFileUploadMB)*1024*1024 {
+		fileErrorResponse(c, fmt.Errorf("file size exceeds limit of %d MB", constant.MaxFileUploadMB), "file_too_large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if constant.UserFileStorageQuotaMB > 0 {
+		used, err := model.SumUserFileBytes(userId)
+		if err != nil {
+			fileErrorResponse(c, err, "query_file_usage_failed", http.StatusInternalServerError)
+			return
+		}
+		if used+header.Size > int64(constant.UserFileStorageQuotaMB)*1024*1024 {
+			fileErrorResponse(c, fmt.Errorf("file storage quota exceeded, limit is %d MB", constant.UserFileStorageQuotaMB), "storage_quota_exceeded", http.StatusForbidden)
+			return
+		}
+	}
+	storage, err := service.GetFileStorage()
+	if err != nil {
+		fileErrorResponse(c, err, "get_file_storage_failed", http.StatusInternalServerError)
+		return
+	}
+	src, err := header.Open()
+	if err != nil {
+		fileErrorResponse(c, err, "read_file_failed", http.StatusBadRequest)
+		return
+	}
+	defer src.Close()
+
+	fileId := "file-" + common.GetUUID()
+	path, size, err := storage.Save(fmt.Sprintf("%d/%s", userId, fileId), src)
+	if err != nil {
+		fileErrorResponse(c, err, "save_file_failed", http.StatusInternalServerError)
+		return
+	}
+	file := &model.File{
+		FileId:      fileId,
+		UserId:      userId,
+		TokenId:     c.GetInt("token_id"),
+		Filename:    header.Filename,
+		Purpose:     purpose,
+		Bytes:       size,
+		Status:      model.FileStatusUploaded,
+		Storage:     storage.Name(),
+		StoragePath: path,
+	}
+
+	// 指定 model 时立即透传到上游渠道
+	if modelName := c.PostForm("model"); modelName != "" {
+		group, err := getRequestGroup(c)
+		if err != nil {
+			_ = storage.Delete(path)
+			fileErrorResponse(c, err, "get_user_group_failed", http.StatusInternalServerError)
+			return
+		}
+		channel, err := model.CacheGetRandomSatisfiedChannel(group, modelName, 0)
+		if err != nil || channel == nil {
+			_ = storage.Delete(path)
+			fileErrorResponse(c, fmt.Errorf("当前分组 %s 下对于模型 %s 无可用渠道", group, modelName), "model_not_found", http.StatusServiceUnavailable)
+			return
+		}
+		if _, err = src.Seek(0, io.SeekStart); err != nil {
+			_ = storage.Delete(path)
+			fileErrorResponse(c, err, "read_file_failed", http.StatusInternalServerError)
+			return
+		}
+		_, keyId := channel.GetPinnedKey(0)
+		upstreamFileId, err := service.UploadFileToChannel(channel, keyId, purpose, header.Filename, src)
+		if err != nil {
+			_ = storage.Delete(path)
+			logging.LogError(c, fmt.Sprintf("upload file to channel #%d failed: %s", channel.Id, err.Error()))
+			fileErrorResponse(c, err, "upload_file_to_channel_failed", http.StatusBadGateway)
+			return
+		}
+		file.ChannelId = channel.Id
+		file.KeyId = keyId
+		file.UpstreamFileId = upstreamFileId
+		file.Status = model.FileStatusProcessed
+	}
+
+	if err = file.Insert(); err != nil {
+		_ = storage.Delete(path)
+		fileErrorResponse(c, err, "insert_file_failed", http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, file.ToOpenAIFile())
+}
+
+func ListFiles(c *gin.Context) {
+	userId := c.GetInt("id")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 10000 {
+		limit = 10000
+	}
+	files, err := model.GetUserFiles(userId, c.Query("purpose"), c.Query("after"), limit+1)
+	if err != nil {
+		fileErrorResponse(c, err, "query_files_failed", http.StatusInternalServerError)
+		return
+	}
+	hasMore := len(files) > limit
+	if hasMore {
+		files = files[:limit]
+	}
+	data := make([]dto.OpenAIFile, 0, len(files))
+	for _, file := range files {
+		data = append(data, file.ToOpenAIFile())
+	}
+	c.JSON(http.StatusOK, dto.OpenAIFileListResponse{
+		Object:  "list",
+		Data:    data,
+		HasMore: hasMore,
+	})
+}
+
+func getUserFileOrAbort(c *gin.Context) *model.File {
+	fileId := c.Param("id")
+	file, exist, err := model.GetUserFileByFileId(c.GetInt("id"), fileId)
+	if err != nil {
+		fileErrorResponse(c, err, "query_file_failed", http.StatusInternalServerError)
+		return nil
+	}
+	if !exist {
+		fileErrorResponse(c, fmt.Errorf("No such File object: %s", fileId), "file_not_found", http.StatusNotFound)
+		return nil
+	}
+	return file
+}
+
+func RetrieveFile(c *gin.Context) {
+	file := getUserFileOrAbort(c)
+	if file == nil {
+		return
+	}
+	c.JSON(http.StatusOK, file.ToOpenAIFile())
+}
+
+func DeleteFile(c *gin.Context) {
+	file := getUserFileOrAbort(c)
+	if file == nil {
+		return
+	}
+	if file.ChannelId != 0 && file.UpstreamFileId != "" {
+		channel, err := model.GetChannelById(file.ChannelId, true)
+		if err == nil {
+			if err = service.DeleteChannelFile(channel, file.KeyId, file.UpstreamFileId); err != nil {
+				logging.LogError(c, fmt.Sprintf("delete file %s from channel #%d failed: %s", file.FileId, file.ChannelId, err.Error()))
+			}
+		}
+	}
+	storage, err := service.GetFileStorageByName(file.Storage)
+	if err == nil {
+		err = storage.Delete(file.StoragePath)
+	}
+	if err != nil {
+		logging.LogError(c, fmt.Sprintf("delete file %s from storage failed: %s", file.FileId, err.Error()))
+	}
+	if err = file.Delete(); err != nil {
+		fileErrorResponse(c, err, "delete_file_failed", http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, dto.OpenAIFileDeleteResponse{
+		Id:      file.FileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
+
+func RetrieveFileContent(c *gin.Context) {
+	file := getUserFileOrAbort(c)
+	if file == nil {
+		return
+	}
+	storage, err := service.GetFileStorageByName(file.Storage)
+	if err != nil {
+		fileErrorResponse(c, err, "get_file_storage_failed", http.StatusInternalServerError)
+		return
+	}
+	reader, err := storage.Open(file.StoragePath)
+	if err != nil {
+		fileErrorResponse(c, err, "read_file_failed", http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+	c.DataFromReader(http.StatusOK, file.Bytes, "application/octet-stream", reader, nil)
+}
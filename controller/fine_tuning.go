@@ -0,0 +1,298 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strconv"
+
+	"one-api/logging")
+
+// resolveFineTuningFile 训练文件是通过网关上传的文件时，先上传到任务所属渠道再替换为上游文件id
+func resolveFineTuningFile(c *gin.Context, channel *model.Channel, keyId int, requestBody map[string]json.RawMessage, field string) error {
+	var fileId string
+	if err := json.Unmarshal(requestBody[field], &fileId); err != nil || fileId == "" {
+		return nil
+	}
+	file, exist, err := model.GetUserFileByFileId(c.GetInt("id"), fileId)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		// 已在上游渠道中的文件，原样转发
+		return nil
+	}
+	upstreamFileId, err := service.EnsureChannelFile(file, channel, keyId)
+	if err != nil {
+		return err
+	}
+	requestBody[field], _ = json.Marshal(upstreamFileId)
+	return nil
+}
+
+func CreateFineTuningJob(c *gin.Context) {
+	userId := c.GetInt("id")
+	channel, err := model.CacheGetChannel(c.GetInt("channel_id"))
+	if err != nil {
+		fileErrorResponse(c, err, "get_channel_failed", http.StatusInternalServerError)
+		return
+	}
+	if channel.Type != common.ChannelTypeOpenAI && channel.Type != common.ChannelTypeAzure {
+		fileErrorResponse(c, fmt.Errorf("channel #%d does not support fine-tuning", channel.Id), "channel_not_supported", http.StatusBadRequest)
+		return
+	}
+	requestBody := make(map[string]json.RawMessage)
+	if err = common.UnmarshalBodyReusable(c, &requestBody); err != nil {
+		fileErrorResponse(c, err, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	var modelName string
+	if err = json.Unmarshal(requestBody["model"], &modelName); err != nil || modelName == "" {
+		fileErrorResponse(c, errors.New("model is required"), "invalid_request", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requestBody["training_file"]; !ok {
+		fileErrorResponse(c, errors.New("training_file is required"), "invalid_request", http.StatusBadRequest)
+		return
+	}
+	modelMapping := c.GetString("model_mapping")
+	if modelMapping != "" && modelMapping != "{}" {
+		modelMap := make(map[string]string)
+		if err = json.Unmarshal([]byte(modelMapping), &modelMap); err != nil {
+			fileErrorResponse(c, err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
+			return
+		}
+		if mapped := common.MapModelName(modelMap, modelName); mapped != modelName {
+			modelName = mapped
+		}
+	}
+	requestBody["model"], _ = json.Marshal(modelName)
+
+	// 微调按训练的 token 数在任务完成后结算，提交时只检查余额
+	userQuota, err := model.GetUserQuota(userId, false)
+	if err != nil {
+		fileErrorResponse(c, err, "get_user_quota_failed", http.StatusInternalServerError)
+		return
+	}
+	if userQuota <= 0 {
+		fileErrorResponse(c, errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
+		return
+	}
+	// 上游的文件与任务只在创建它们的密钥下可见，多密钥渠道固定使用第一个启用的密钥并记录在任务上
+	_, keyId := channel.GetPinnedKey(0)
+	for _, field := range []string{"training_file", "validation_file"} {
+		if err = resolveFineTuningFile(c, channel, keyId, requestBody, field); err != nil {
+			fileErrorResponse(c, err, "upload_file_to_channel_failed", http.StatusInternalServerError)
+			return
+		}
+	}
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		fileErrorResponse(c, err, "json_marshal_failed", http.StatusInternalServerError)
+		return
+	}
+	responseBody, ok := doFineTuningRequest(c, channel, keyId, http.MethodPost, "", nil, bytes.NewReader(jsonData))
+	if !ok {
+		return
+	}
+	var job dto.FineTuningJob
+	if err = json.Unmarshal(responseBody, &job); err != nil || job.Id == "" {
+		fileErrorResponse(c, fmt.Errorf("invalid fine-tuning job response: %s", string(responseBody)), "invalid_upstream_response", http.StatusInternalServerError)
+		return
+	}
+
+	relayInfo := relaycommon.GenTaskRelayInfo(c)
+	task := model.InitTask(constant.TaskPlatformFineTuning, relayInfo)
+	task.TaskID = job.Id
+	task.KeyId = keyId
+	task.Action = constant.FineTuningActionTrain
+	task.Status = service.FineTuningStatus2TaskStatus(job.Status)
+	task.Data = responseBody
+	task.Properties = model.Properties{
+		Input:   job.TrainingFile,
+		Model:   modelName,
+		TokenId: relayInfo.TokenId,
+		Group:   relayInfo.Group,
+	}
+	if err = task.Insert(); err != nil {
+		logging.LogError(c, fmt.Sprintf("insert fine-tuning task %s failed: %s", job.Id, err.Error()))
+	}
+	c.Data(http.StatusOK, "application/json", responseBody)
+}
+
+// doFineTuningRequest 向渠道发送微调接口请求，上游返回错误时原样写回客户端
+func doFineTuningRequest(c *gin.Context, channel *model.Channel, keyId int, method string, path string, query url.Values, body io.Reader) ([]byte, bool) {
+	resp, err := service.DoChannelFineTuningRequest(channel, keyId, method, path, query, body)
+	if err != nil {
+		fileErrorResponse(c, err, "do_request_failed", http.StatusInternalServerError)
+		return nil, false
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fileErrorResponse(c, err, "read_response_body_failed", http.StatusInternalServerError)
+		return nil, false
+	}
+	if resp.StatusCode != http.StatusOK {
+		c.Data(resp.StatusCode, "application/json", responseBody)
+		return nil, false
+	}
+	return responseBody, true
+}
+
+// getUserFineTuningTaskOrAbort 查询微调任务以及创建任务时使用的渠道
+func getUserFineTuningTaskOrAbort(c *gin.Context) (*model.Task, *model.Channel) {
+	jobId := c.Param("id")
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), jobId)
+	if err != nil {
+		fileErrorResponse(c, err, "query_fine_tuning_job_failed", http.StatusInternalServerError)
+		return nil, nil
+	}
+	if !exist || task.Platform != constant.TaskPlatformFineTuning {
+		fileErrorResponse(c, fmt.Errorf("No such fine-tuning job: %s", jobId), "fine_tuning_job_not_found", http.StatusNotFound)
+		return nil, nil
+	}
+	channel, err := model.GetChannelById(task.ChannelId, true)
+	if err != nil {
+		fileErrorResponse(c, fmt.Errorf("channel #%d of fine-tuning job %s not found", task.ChannelId, jobId), "channel_not_found", http.StatusInternalServerError)
+		return nil, nil
+	}
+	return task, channel
+}
+
+func ListFineTuningJobs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	tasks, err := model.GetUserTasksByPlatform(c.GetInt("id"), constant.TaskPlatformFineTuning, c.Query("after"), limit+1)
+	if err != nil {
+		fileErrorResponse(c, err, "query_fine_tuning_jobs_failed", http.StatusInternalServerError)
+		return
+	}
+	hasMore := len(tasks) > limit
+	if hasMore {
+		tasks = tasks[:limit]
+	}
+	response := dto.FineTuningJobListResponse{
+		Object:  "list",
+		Data:    make([]json.RawMessage, 0, len(tasks)),
+		HasMore: hasMore,
+	}
+	for _, task := range tasks {
+		if len(task.Data) > 0 {
+			response.Data = append(response.Data, task.Data)
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func RetrieveFineTuningJob(c *gin.Context) {
+	task, channel := getUserFineTuningTaskOrAbort(c)
+	if task == nil {
+		return
+	}
+	responseBody, ok := doFineTuningRequest(c, channel, task.KeyId, http.MethodGet, "/"+task.TaskID, nil, nil)
+	if !ok {
+		return
+	}
+	if err := service.UpdateFineTuningTask(c, task, responseBody); err != nil {
+		logging.LogError(c, fmt.Sprintf("update fine-tuning task %s failed: %s", task.TaskID, err.Error()))
+	}
+	c.Data(http.StatusOK, "application/json", responseBody)
+}
+
+func CancelFineTuningJob(c *gin.Context) {
+	task, channel := getUserFineTuningTaskOrAbort(c)
+	if task == nil {
+		return
+	}
+	responseBody, ok := doFineTuningRequest(c, channel, task.KeyId, http.MethodPost, "/"+task.TaskID+"/cancel", nil, nil)
+	if !ok {
+		return
+	}
+	if err := service.UpdateFineTuningTask(c, task, responseBody); err != nil {
+		logging.LogError(c, fmt.Sprintf("update fine-tuning task %s failed: %s", task.TaskID, err.Error()))
+	}
+	c.Data(http.StatusOK, "application/json", responseBody)
+}
+
+func ListFineTuningEvents(c *gin.Context) {
+	relayFineTuningList(c, "events")
+}
+
+func ListFineTuningCheckpoints(c *gin.Context) {
+	relayFineTuningList(c, "checkpoints")
+}
+
+// relayFineTuningList 转发任务的事件、检查点等分页查询
+func relayFineTuningList(c *gin.Context, resource string) {
+	task, channel := getUserFineTuningTaskOrAbort(c)
+	if task == nil {
+		return
+	}
+	query := url.Values{}
+	for _, key := range []string{"after", "limit"} {
+		if value := c.Query(key); value != "" {
+			query.Set(key, value)
+		}
+	}
+	responseBody, ok := doFineTuningRequest(c, channel, task.KeyId, http.MethodGet, "/"+task.TaskID+"/"+resource, query, nil)
+	if !ok {
+		return
+	}
+	c.Data(http.StatusOK, "application/json", responseBody)
+}
+
+// UpdateFineTuningTaskAll 由 UpdateTaskBulk 调用，逐个查询未完成的微调任务
+func UpdateFineTuningTaskAll(ctx context.Context, taskChannelM map[int][]string, taskM map[string]*model.Task) error {
+	for channelId, taskIds := range taskChannelM {
+		logging.LogInfo(ctx, fmt.Sprintf("渠道 #%d 未完成的微调任务有: %d", channelId, len(taskIds)))
+		channel, err := model.GetChannelById(channelId, true)
+		if err != nil {
+			common.SysLog(fmt.Sprintf("GetChannelById: %v", err))
+			err = model.TaskBulkUpdate(taskIds, map[string]any{
+				"fail_reason": fmt.Sprintf("获取渠道信息失败，请联系管理员，渠道ID：%d", channelId),
+				"status":      "FAILURE",
+				"progress":    "100%",
+			})
+			if err != nil {
+				logging.SysError(fmt.Sprintf("UpdateFineTuningTask error: %v", err))
+			}
+			continue
+		}
+		for _, taskId := range taskIds {
+			resp, err := service.DoChannelFineTuningRequest(channel, taskM[taskId].KeyId, http.MethodGet, "/"+taskId, nil, nil)
+			if err != nil {
+				logging.LogError(ctx, fmt.Sprintf("Get fine-tuning job %s error: %v", taskId, err))
+				continue
+			}
+			responseBody, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				logging.LogError(ctx, fmt.Sprintf("Get fine-tuning job %s error: %v", taskId, err))
+				continue
+			}
+			if resp.StatusCode != http.StatusOK {
+				logging.LogError(ctx, fmt.Sprintf("Get fine-tuning job %s status code: %d, body: %s", taskId, resp.StatusCode, string(responseBody)))
+				continue
+			}
+			if err = service.UpdateFineTuningTask(ctx, taskM[taskId], responseBody); err != nil {
+				logging.LogError(ctx, fmt.Sprintf("update fine-tuning task %s failed: %s", taskId, err.Error()))
+			}
+		}
+	}
+	return nil
+}
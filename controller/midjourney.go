@@ -92,8 +92,14 @@ func UpdateMidjourneyTaskBulk() {
 			// 使用带有超时的 context 创建新的请求
 			req = req.WithContext(ctx)
 			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("mj-api-secret", midjourneyChannel.Key)
-			resp, err := service.GetHttpClient().Do(req)
+			req.Header.Set("mj-api-secret", midjourneyChannel.GetFirstKey())
+			client, err := service.GetChannelHttpClient(midjourneyChannel.Id, midjourneyChannel.GetSetting())
+			if err != nil {
+				logging.LogError(ctx, fmt.Sprintf("Get Task http client error: %v", err))
+				cancel()
+				continue
+			}
+			resp, err := client.Do(req)
 			if err != nil {
 				logging.LogError(ctx, fmt.Sprintf("Get Task Do req error: %v", err))
 				continue
@@ -92,6 +92,51 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "ModelFallback":
+		err = setting.CheckModelFallback(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "GroupModelAlias":
+		err = setting.CheckGroupModelAlias(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "GroupChannelSelect":
+		err = setting.CheckGroupChannelSelect(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "GroupRelayRateLimit":
+		err = setting.CheckGroupRelayRateLimit(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "GroupQueuePriority":
+		err = setting.CheckGroupQueuePriority(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	}
 	err = model.UpdateOption(option.Key, option.Value)
 	if err != nil {
@@ -62,3 +62,22 @@ func ResetModelRatio(c *gin.Context) {
 		"message": "重置模型倍率成功",
 	})
 }
+
+// ResetCacheRatio 使用各厂商公布的缓存折扣覆盖缓存倍率配置，除 Claude 外升级后不会自动生效，需要管理员主动重置
+func ResetCacheRatio(c *gin.Context) {
+	err := model.UpdateOption("CacheRatio", common.DefaultCacheRatio2JSONString())
+	if err == nil {
+		err = model.UpdateOption("CreateCacheRatio", common.DefaultCreateCacheRatio2JSONString())
+	}
+	if err != nil {
+		c.JSON(200, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": "重置缓存倍率成功",
+	})
+}
@@ -14,17 +14,24 @@ import (
 	"one-api/middleware"
 	"one-api/model"
 	"one-api/relay"
+	"one-api/relay/channel/claude"
+	"one-api/relay/channel/gemini"
 	"one-api/relay/constant"
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
+	"one-api/setting"
 	"strings"
+	"time"
 
 	"one-api/logging")
 
+// relayQueueRetryDelay 上游返回 429 后重新排队时，首次重试前的最短等待时间
+const relayQueueRetryDelay = time.Second
+
 func relayHandler(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
 	var err *dto.OpenAIErrorWithStatusCode
 	switch relayMode {
-	case relayconstant.RelayModeImagesGenerations:
+	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits, relayconstant.RelayModeImagesVariations:
 		err = relay.ImageHelper(c, relayMode)
 	case relayconstant.RelayModeAudioSpeech:
 		fallthrough
@@ -34,6 +41,12 @@ func relayHandler(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode
 		err = relay.AudioHelper(c)
 	case relayconstant.RelayModeRerank:
 		err = relay.RerankHelper(c, relayMode)
+	case relayconstant.RelayModeClaudeMessages:
+		err = relay.ClaudeHelper(c)
+	case relayconstant.RelayModeGeminiGenerateContent:
+		err = relay.GeminiHelper(c)
+	case relayconstant.RelayModeResponses:
+		err = relay.ResponsesHelper(c)
 	default:
 		err = relay.TextHelper(c)
 	}
@@ -56,26 +69,50 @@ func Relay(c *gin.Context) {
 	originalModel := c.GetString("original_model")
 	var openaiErr *dto.OpenAIErrorWithStatusCode
 
+	noChannel := false
+	saturated := false
+
 	for i := 0; i <= common.RetryTimes; i++ {
 		channel, err := getChannel(c, group, originalModel, i)
 		if err != nil {
 			logging.LogError(c, err.Error())
 			openaiErr = service.OpenAIErrorWrapperLocal(err, "get_channel_failed", http.StatusInternalServerError)
+			noChannel = true
+			if errors.Is(err, model.ErrChannelsSaturated) {
+				openaiErr.StatusCode = http.StatusTooManyRequests
+				saturated = true
+			}
 			break
 		}
 
+		setRetryRemaining(c, common.RetryTimes-i)
 		openaiErr = relayRequest(c, relayMode, channel)
 
 		if openaiErr == nil {
 			return // 成功处理请求，直接返回
 		}
 
-		go processChannelError(c, channel.Id, channel.Type, channel.Name, channel.GetAutoBan(), openaiErr)
+		if openaiErr.Error.Code != "channel_saturated" {
+			go processChannelError(c, channel.Id, c.GetInt("channel_key_id"), channel.Type, channel.Name, channel.GetAutoBan(), openaiErr)
+		}
 
 		if !shouldRetry(c, openaiErr, common.RetryTimes-i) {
 			break
 		}
 	}
+
+	// 所有渠道都返回 429 或已饱和时，排队等待后重试
+	if openaiErr != nil && openaiErr.StatusCode == http.StatusTooManyRequests && (saturated || !openaiErr.LocalError) && setting.RelayQueueEnabled {
+		if relayQueueRetry(c, relayMode, group, originalModel, &openaiErr) {
+			return
+		}
+	}
+	// 所有重试都失败时，按降级链尝试其他模型
+	if openaiErr != nil && (noChannel || shouldRetry(c, openaiErr, 1)) && service.ShouldModelFallback(c) {
+		if relayModelFallback(c, relayMode, group, originalModel, &openaiErr) {
+			return
+		}
+	}
 	useChannel := c.GetStringSlice("use_channel")
 	if len(useChannel) > 1 {
 		retryLogStr := fmt.Sprintf("重试：%s", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(useChannel)), "->"), "[]"))
@@ -83,14 +120,103 @@ func Relay(c *gin.Context) {
 	}
 
 	if openaiErr != nil {
-		if openaiErr.StatusCode == http.StatusTooManyRequests {
+		// 令牌与用户限流、排队失败的提示不覆盖
+		if openaiErr.StatusCode == http.StatusTooManyRequests && openaiErr.Error.Code != "rate_limit_exceeded" && openaiErr.Error.Code != "relay_queue_failed" {
 			openaiErr.Error.Message = "当前分组上游负载已饱和，请稍后再试"
 		}
 		openaiErr.Error.Message = common.MessageWithRequestId(openaiErr.Error.Message, requestId)
-		c.JSON(openaiErr.StatusCode, gin.H{
-			"error": openaiErr.Error,
+		switch relayMode {
+		case relayconstant.RelayModeClaudeMessages:
+			c.JSON(openaiErr.StatusCode, claude.ErrorOpenAI2Claude(openaiErr))
+		case relayconstant.RelayModeGeminiGenerateContent:
+			c.JSON(openaiErr.StatusCode, gemini.ErrorOpenAI2Gemini(openaiErr))
+		default:
+			c.JSON(openaiErr.StatusCode, gin.H{
+				"error": openaiErr.Error,
+			})
+		}
+	}
+}
+
+// relayQueueRetry 在最长排队时间内排队等待空闲的渠道并重试，上游仍返回 429 时重新排队，成功时返回 true
+func relayQueueRetry(c *gin.Context, relayMode int, group string, originalModel string, openaiErr **dto.OpenAIErrorWithStatusCode) bool {
+	deadline := service.RelayQueueDeadline()
+	for {
+		var channel *model.Channel
+		var channelErr error
+		queueErr := service.WaitRelayQueue(c, group, originalModel, deadline, relayQueueRetryDelay, func() (bool, error) {
+			channel, channelErr = model.CacheGetRandomSatisfiedChannel(group, originalModel, 0)
+			// 只有渠道饱和时才继续等待，其他错误（如没有可用渠道）等待也无法恢复
+			if errors.Is(channelErr, model.ErrChannelsSaturated) {
+				return false, nil
+			}
+			return channelErr == nil, channelErr
 		})
+		if queueErr != nil && queueErr == channelErr {
+			logging.LogError(c, queueErr.Error())
+			*openaiErr = service.OpenAIErrorWrapperLocal(queueErr, "get_channel_failed", http.StatusInternalServerError)
+			return false
+		}
+		if queueErr != nil {
+			logging.LogWarn(c, fmt.Sprintf("relay queue for model %s failed: %s", originalModel, queueErr.Error()))
+			*openaiErr = service.OpenAIErrorWrapperLocal(errors.New(service.RelayQueueErrorMessage(queueErr)), "relay_queue_failed", http.StatusTooManyRequests)
+			return false
+		}
+		middleware.SetupContextForSelectedChannel(c, channel, originalModel)
+		setRetryRemaining(c, 0)
+		*openaiErr = relayRequest(c, relayMode, channel)
+		if *openaiErr == nil {
+			return true
+		}
+		if (*openaiErr).Error.Code != "channel_saturated" {
+			go processChannelError(c, channel.Id, c.GetInt("channel_key_id"), channel.Type, channel.Name, channel.GetAutoBan(), *openaiErr)
+		}
+		if (*openaiErr).StatusCode != http.StatusTooManyRequests || (*openaiErr).LocalError {
+			return false
+		}
+	}
+}
+
+// relayModelFallback 依次使用降级链中的模型处理请求，每个模型只尝试一个渠道，成功时返回 true
+func relayModelFallback(c *gin.Context, relayMode int, group string, originalModel string, openaiErr **dto.OpenAIErrorWithStatusCode) bool {
+	// Distribute 已经降级过时，降级链以用户请求的模型为准
+	requestedModel := c.GetString("requested_model")
+	if requestedModel == "" {
+		requestedModel = originalModel
+	}
+	chain := service.GetModelFallbackChain(c, requestedModel)
+	for i, fallbackModel := range chain {
+		if fallbackModel == originalModel {
+			// 跳过已经尝试过的模型
+			chain = chain[i+1:]
+			break
+		}
+	}
+	for _, fallbackModel := range chain {
+		channel, err := model.CacheGetRandomSatisfiedChannel(group, fallbackModel, 0)
+		if err != nil || channel == nil {
+			continue
+		}
+		if err = service.ReplaceRequestModel(c, fallbackModel); err != nil {
+			logging.LogError(c, "replace request model failed: "+err.Error())
+			return false
+		}
+		logging.LogInfo(c, fmt.Sprintf("模型降级：%s -> %s", requestedModel, fallbackModel))
+		c.Set("requested_model", requestedModel)
+		middleware.SetupContextForSelectedChannel(c, channel, fallbackModel)
+		setRetryRemaining(c, 0)
+		*openaiErr = relayRequest(c, relayMode, channel)
+		if *openaiErr == nil {
+			return true
+		}
+		if (*openaiErr).Error.Code != "channel_saturated" {
+			go processChannelError(c, channel.Id, c.GetInt("channel_key_id"), channel.Type, channel.Name, channel.GetAutoBan(), *openaiErr)
+		}
+		if !shouldRetry(c, *openaiErr, 1) {
+			return false
+		}
 	}
+	return false
 }
 
 var upgrader = websocket.Upgrader{
@@ -133,7 +259,9 @@ func WssRelay(c *gin.Context) {
 			return // 成功处理请求，直接返回
 		}
 
-		go processChannelError(c, channel.Id, channel.Type, channel.Name, channel.GetAutoBan(), openaiErr)
+		if openaiErr.Error.Code != "channel_saturated" {
+			go processChannelError(c, channel.Id, c.GetInt("channel_key_id"), channel.Type, channel.Name, channel.GetAutoBan(), openaiErr)
+		}
 
 		if !shouldRetry(c, openaiErr, common.RetryTimes-i) {
 			break
@@ -158,16 +286,49 @@ func relayRequest(c *gin.Context, relayMode int, channel *model.Channel) *dto.Op
 	addUsedChannel(c, channel.Id)
 	requestBody, _ := common.GetRequestBody(c)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
-	return relayHandler(c, relayMode)
+	release, ok := model.AcquireChannelLimit(channel.Id, c.GetString("original_model"))
+	if !ok {
+		return channelSaturatedError()
+	}
+	defer func() {
+		release(!c.GetBool("response_cache_hit"))
+		service.NotifyRelayQueue()
+	}()
+	recorder := service.NewChannelHealthRecorder(c, channel.Id)
+	openaiErr := relayHandler(c, relayMode)
+	recorder.Finish(c, openaiErr)
+	return openaiErr
 }
 
 func wssRequest(c *gin.Context, ws *websocket.Conn, relayMode int, channel *model.Channel) *dto.OpenAIErrorWithStatusCode {
 	addUsedChannel(c, channel.Id)
 	requestBody, _ := common.GetRequestBody(c)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+	release, ok := model.AcquireChannelLimit(channel.Id, c.GetString("original_model"))
+	if !ok {
+		return channelSaturatedError()
+	}
+	defer func() {
+		release(true)
+		service.NotifyRelayQueue()
+	}()
 	return relay.WssHelper(c, ws)
 }
 
+// channelSaturatedError 选中的渠道在请求开始前已达到上游限制，按上游 429 处理以便换渠道重试或排队
+func channelSaturatedError() *dto.OpenAIErrorWithStatusCode {
+	return service.OpenAIErrorWrapper(model.ErrChannelsSaturated, "channel_saturated", http.StatusTooManyRequests)
+}
+
+// setRetryRemaining 记录本次请求失败后还能换渠道重试的次数，指定渠道时不重试。
+// 处理器据此决定把可以重试的问题（如结构化输出不符合要求）作为错误返回，还是直接返回上游的响应
+func setRetryRemaining(c *gin.Context, retryTimes int) {
+	if _, ok := c.Get("specific_channel_id"); ok {
+		retryTimes = 0
+	}
+	c.Set("retry_remaining", retryTimes)
+}
+
 func addUsedChannel(c *gin.Context, channelId int) {
 	useChannel := c.GetStringSlice("use_channel")
 	useChannel = append(useChannel, fmt.Sprintf("%d", channelId))
@@ -190,7 +351,7 @@ func getChannel(c *gin.Context, group, originalModel string, retryCount int) (*m
 	}
 	channel, err := model.CacheGetRandomSatisfiedChannel(group, originalModel, retryCount)
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("获取重试渠道失败: %s", err.Error()))
+		return nil, fmt.Errorf("获取重试渠道失败: %w", err)
 	}
 	middleware.SetupContextForSelectedChannel(c, channel, originalModel)
 	return channel, nil
@@ -215,6 +376,10 @@ func shouldRetry(c *gin.Context, openaiErr *dto.OpenAIErrorWithStatusCode, retry
 	if openaiErr.StatusCode == 307 {
 		return true
 	}
+	if openaiErr.Error.Code == service.StreamTimeoutErrorCode {
+		// 上游流式响应超时且客户端尚未收到内容，可以换渠道重试
+		return true
+	}
 	if openaiErr.StatusCode/100 == 5 {
 		// 超时不重试
 		if openaiErr.StatusCode == 504 || openaiErr.StatusCode == 524 {
@@ -239,12 +404,17 @@ func shouldRetry(c *gin.Context, openaiErr *dto.OpenAIErrorWithStatusCode, retry
 	return true
 }
 
-func processChannelError(c *gin.Context, channelId int, channelType int, channelName string, autoBan bool, err *dto.OpenAIErrorWithStatusCode) {
+func processChannelError(c *gin.Context, channelId int, channelKeyId int, channelType int, channelName string, autoBan bool, err *dto.OpenAIErrorWithStatusCode) {
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
 	logging.LogError(c, fmt.Sprintf("relay error (channel #%d, status code: %d): %s", channelId, err.StatusCode, err.Error.Message))
 	if service.ShouldDisableChannel(channelType, err) && autoBan {
-		service.DisableChannel(channelId, channelName, err.Error.Message)
+		if channelKeyId != 0 {
+			// 多密钥渠道只禁用出错的密钥，所有密钥都被禁用时才禁用渠道
+			service.DisableChannelKey(channelId, channelKeyId, channelName, err.Error.Message)
+		} else {
+			service.DisableChannel(channelId, channelName, err.Error.Message)
+		}
 	}
 }
 
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	"one-api/setting"
 	"strconv"
 
 	"one-api/logging")
@@ -124,6 +125,33 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if token.ModelFallback != "" {
+		if err := setting.CheckModelFallback(token.ModelFallback); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "模型降级链格式错误: " + err.Error(),
+			})
+			return
+		}
+	}
+	if token.ModelAlias != "" {
+		if err := setting.CheckModelAlias(token.ModelAlias); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "模型别名格式错误: " + err.Error(),
+			})
+			return
+		}
+	}
+	if token.RateLimit != "" {
+		if err := setting.CheckRelayRateLimit(token.RateLimit); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "请求限制格式错误: " + err.Error(),
+			})
+			return
+		}
+	}
 	key, err := common.GenerateKey()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -144,6 +172,9 @@ func AddToken(c *gin.Context) {
 		UnlimitedQuota:     token.UnlimitedQuota,
 		ModelLimitsEnabled: token.ModelLimitsEnabled,
 		ModelLimits:        token.ModelLimits,
+		ModelFallback:      token.ModelFallback,
+		ModelAlias:         token.ModelAlias,
+		RateLimit:          token.RateLimit,
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
 	}
@@ -199,6 +230,33 @@ func UpdateToken(c *gin.Context) {
 		})
 		return
 	}
+	if token.ModelFallback != "" {
+		if err := setting.CheckModelFallback(token.ModelFallback); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "模型降级链格式错误: " + err.Error(),
+			})
+			return
+		}
+	}
+	if token.ModelAlias != "" {
+		if err := setting.CheckModelAlias(token.ModelAlias); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "模型别名格式错误: " + err.Error(),
+			})
+			return
+		}
+	}
+	if token.RateLimit != "" {
+		if err := setting.CheckRelayRateLimit(token.RateLimit); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "请求限制格式错误: " + err.Error(),
+			})
+			return
+		}
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -233,6 +291,9 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.UnlimitedQuota = token.UnlimitedQuota
 		cleanToken.ModelLimitsEnabled = token.ModelLimitsEnabled
 		cleanToken.ModelLimits = token.ModelLimits
+		cleanToken.ModelFallback = token.ModelFallback
+		cleanToken.ModelAlias = token.ModelAlias
+		cleanToken.RateLimit = token.RateLimit
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 	}
@@ -543,6 +543,15 @@ func UpdateUser(c *gin.Context) {
 	if updatedUser.Password == "$I_LOVE_U" {
 		updatedUser.Password = "" // rollback to what it should be
 	}
+	if updatedUser.RateLimit != "" {
+		if err := setting.CheckRelayRateLimit(updatedUser.RateLimit); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "请求限制格式错误: " + err.Error(),
+			})
+			return
+		}
+	}
 	updatePassword := updatedUser.Password != ""
 	if err := updatedUser.Edit(updatePassword); err != nil {
 		c.JSON(http.StatusOK, gin.H{
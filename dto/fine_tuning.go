@@ -0,0 +1,29 @@
+package dto
+
+import "encoding/json"
+
+type FineTuningJobError struct {
+	Code    string  `json:"code"`
+	Message string  `json:"message"`
+	Param   *string `json:"param"`
+}
+
+// FineTuningJob 上游返回的微调任务，只解析网关需要的字段，其余字段原样保存
+type FineTuningJob struct {
+	Id             string              `json:"id"`
+	Object         string              `json:"object"`
+	Model          string              `json:"model"`
+	FineTunedModel *string             `json:"fine_tuned_model"`
+	Status         string              `json:"status"`
+	TrainingFile   string              `json:"training_file"`
+	TrainedTokens  *int                `json:"trained_tokens"`
+	CreatedAt      int64               `json:"created_at"`
+	FinishedAt     *int64              `json:"finished_at"`
+	Error          *FineTuningJobError `json:"error"`
+}
+
+type FineTuningJobListResponse struct {
+	Object  string            `json:"object"`
+	Data    []json.RawMessage `json:"data"`
+	HasMore bool              `json:"has_more"`
+}
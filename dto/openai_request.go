@@ -95,9 +95,11 @@ type Message struct {
 
 type MediaContent struct {
 	Type       string `json:"type"`
-	Text       string `json:"text"`
+	Text       string `json:"text,omitempty"`
 	ImageUrl   any    `json:"image_url,omitempty"`
 	InputAudio any    `json:"input_audio,omitempty"`
+	// CacheControl Anthropic 的 prompt caching 标记，例如 {"type": "ephemeral"}，转发给 Claude 时保留
+	CacheControl any `json:"cache_control,omitempty"`
 }
 
 type MessageImageUrl struct {
@@ -174,8 +176,9 @@ func (m *Message) ParseContent() []MediaContent {
 			case ContentTypeText:
 				if subStr, ok := contentMap["text"].(string); ok {
 					contentList = append(contentList, MediaContent{
-						Type: ContentTypeText,
-						Text: subStr,
+						Type:         ContentTypeText,
+						Text:         subStr,
+						CacheControl: contentMap["cache_control"],
 					})
 				}
 			case ContentTypeImageURL:
@@ -192,6 +195,7 @@ func (m *Message) ParseContent() []MediaContent {
 							Url:    subObj["url"].(string),
 							Detail: subObj["detail"].(string),
 						},
+						CacheControl: contentMap["cache_control"],
 					})
 				} else if url, ok := contentMap["image_url"].(string); ok {
 					contentList = append(contentList, MediaContent{
@@ -200,6 +204,7 @@ func (m *Message) ParseContent() []MediaContent {
 							Url:    url,
 							Detail: "high",
 						},
+						CacheControl: contentMap["cache_control"],
 					})
 				}
 			case ContentTypeInputAudio:
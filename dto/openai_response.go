@@ -137,4 +137,15 @@ type Usage struct {
 	TotalTokens            int                `json:"total_tokens"`
 	PromptTokensDetails    InputTokenDetails  `json:"prompt_tokens_details"`
 	CompletionTokenDetails OutputTokenDetails `json:"completion_tokens_details"`
+	// DeepSeek 返回的缓存命中与未命中的输入 token
+	PromptCacheHitTokens  int `json:"prompt_cache_hit_tokens,omitempty"`
+	PromptCacheMissTokens int `json:"prompt_cache_miss_tokens,omitempty"`
+}
+
+// NormalizeCachedTokens 将上游以不同字段返回的缓存命中 token 统一到 prompt_tokens_details.cached_tokens，
+// 计费时只读取该字段
+func (u *Usage) NormalizeCachedTokens() {
+	if u.PromptTokensDetails.CachedTokens == 0 && u.PromptCacheHitTokens > 0 {
+		u.PromptTokensDetails.CachedTokens = u.PromptCacheHitTokens
+	}
 }
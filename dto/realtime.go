@@ -45,9 +45,11 @@ type RealtimeUsage struct {
 
 type InputTokenDetails struct {
 	CachedTokens int `json:"cached_tokens"`
-	TextTokens   int `json:"text_tokens"`
-	AudioTokens  int `json:"audio_tokens"`
-	ImageTokens  int `json:"image_tokens"`
+	// CachedCreationTokens 写入缓存的输入 token，目前只有 Claude 返回
+	CachedCreationTokens int `json:"cached_creation_tokens,omitempty"`
+	TextTokens           int `json:"text_tokens"`
+	AudioTokens          int `json:"audio_tokens"`
+	ImageTokens          int `json:"image_tokens"`
 }
 
 type OutputTokenDetails struct {
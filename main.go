@@ -88,6 +88,10 @@ func main() {
 		go model.SyncOptions(common.SyncFrequency)
 		go model.SyncChannelCache(common.SyncFrequency)
 	}
+	if common.RedisEnabled {
+		go model.SyncChannelHealth(common.SyncFrequency)
+		go model.SyncChannelBreaker(common.SyncFrequency)
+	}
 
 	// 数据看板
 	go model.UpdateQuotaData()
@@ -114,6 +118,11 @@ func main() {
 			controller.UpdateTaskBulk()
 		})
 	}
+	if common.IsMasterNode {
+		gopool.Go(func() {
+			controller.UpdateBatchBulk()
+		})
+	}
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		common.BatchUpdateEnabled = true
 		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
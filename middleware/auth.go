@@ -174,6 +174,16 @@ func TokenAuth() func(c *gin.Context) {
 			}
 			c.Request.Header.Set("Authorization", "Bearer "+key)
 		}
+		// Anthropic 客户端通过 x-api-key 传递令牌，Gemini 客户端通过 x-goog-api-key 或 ?key= 传递令牌
+		if c.Request.Header.Get("Authorization") == "" {
+			if key := c.Request.Header.Get("x-api-key"); key != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+key)
+			} else if key := c.Request.Header.Get("x-goog-api-key"); key != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+key)
+			} else if key := c.Query("key"); key != "" && strings.HasPrefix(c.Request.URL.Path, "/v1beta/") {
+				c.Request.Header.Set("Authorization", "Bearer "+key)
+			}
+		}
 		key := c.Request.Header.Get("Authorization")
 		parts := make([]string, 0)
 		key = strings.TrimPrefix(key, "Bearer ")
@@ -208,22 +218,7 @@ func TokenAuth() func(c *gin.Context) {
 			abortWithOpenAiMessage(c, http.StatusForbidden, "用户已被封禁")
 			return
 		}
-		c.Set("id", token.UserId)
-		c.Set("token_id", token.Id)
-		c.Set("token_key", token.Key)
-		c.Set("token_name", token.Name)
-		c.Set("token_unlimited_quota", token.UnlimitedQuota)
-		if !token.UnlimitedQuota {
-			c.Set("token_quota", token.RemainQuota)
-		}
-		if token.ModelLimitsEnabled {
-			c.Set("token_model_limit_enabled", true)
-			c.Set("token_model_limit", token.GetModelLimitsMap())
-		} else {
-			c.Set("token_model_limit_enabled", false)
-		}
-		c.Set("allow_ips", token.GetIpLimitsMap())
-		c.Set("token_group", token.Group)
+		SetupContextForToken(c, token)
 		if len(parts) > 1 {
 			if model.IsAdmin(token.UserId) {
 				c.Set("specific_channel_id", parts[1])
@@ -235,3 +230,32 @@ func TokenAuth() func(c *gin.Context) {
 		c.Next()
 	}
 }
+
+// SetupContextForToken 将令牌的信息写入上下文，供后续的模型限制、渠道选择、限流与计费使用
+func SetupContextForToken(c *gin.Context, token *model.Token) {
+	c.Set("id", token.UserId)
+	c.Set("token_id", token.Id)
+	c.Set("token_key", token.Key)
+	c.Set("token_name", token.Name)
+	c.Set("token_unlimited_quota", token.UnlimitedQuota)
+	if !token.UnlimitedQuota {
+		c.Set("token_quota", token.RemainQuota)
+	}
+	if token.ModelLimitsEnabled {
+		c.Set("token_model_limit_enabled", true)
+		c.Set("token_model_limit", token.GetModelLimitsMap())
+	} else {
+		c.Set("token_model_limit_enabled", false)
+	}
+	if token.ModelFallback != "" {
+		c.Set("token_model_fallback", token.GetModelFallbackMap())
+	}
+	if token.ModelAlias != "" {
+		c.Set("token_model_alias", token.GetModelAliasMap())
+	}
+	if token.RateLimit != "" {
+		c.Set("token_rate_limit", token.GetRateLimit())
+	}
+	c.Set("allow_ips", token.GetIpLimitsMap())
+	c.Set("token_group", token.Group)
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -20,7 +21,8 @@ import (
 	"one-api/logging")
 
 type ModelRequest struct {
-	Model string `json:"model"`
+	Model string          `json:"model"`
+	User  json.RawMessage `json:"user,omitempty"`
 }
 
 func Distribute() func(c *gin.Context) {
@@ -57,6 +59,9 @@ func Distribute() func(c *gin.Context) {
 			userGroup = tokenGroup
 		}
 		c.Set("group", userGroup)
+		if shouldSelectChannel {
+			ResolveRequestModelAlias(c, userGroup, modelRequest)
+		}
 		if ok {
 			id, err := strconv.Atoi(channelId.(string))
 			if err != nil {
@@ -74,43 +79,15 @@ func Distribute() func(c *gin.Context) {
 			}
 		} else {
 			// Select a channel for the user
-			// check token model mapping
-			modelLimitEnable := c.GetBool("token_model_limit_enabled")
-			if modelLimitEnable {
-				s, ok := c.Get("token_model_limit")
-				var tokenModelLimit map[string]bool
-				if ok {
-					tokenModelLimit = s.(map[string]bool)
-				} else {
-					tokenModelLimit = map[string]bool{}
-				}
-				if tokenModelLimit != nil {
-					if _, ok := tokenModelLimit[modelRequest.Model]; !ok {
-						abortWithOpenAiMessage(c, http.StatusForbidden, "该令牌无权访问模型 "+modelRequest.Model)
-						return
-					}
-				} else {
-					// token model limit is empty, all models are not allowed
-					abortWithOpenAiMessage(c, http.StatusForbidden, "该令牌无权访问任何模型")
-					return
-				}
+			if selectErr := CheckTokenModelLimit(c, modelRequest.Model); selectErr != nil {
+				abortWithOpenAiMessage(c, selectErr.StatusCode, selectErr.Message)
+				return
 			}
-
 			if shouldSelectChannel {
-				channel, err = model.CacheGetRandomSatisfiedChannel(userGroup, modelRequest.Model, 0)
-				if err != nil {
-					message := fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", userGroup, modelRequest.Model)
-					// 如果错误，但是渠道不为空，说明是数据库一致性问题
-					if channel != nil {
-						logging.SysError(fmt.Sprintf("渠道不存在：%d", channel.Id))
-						message = "数据库一致性已被破坏，请联系管理员"
-					}
-					// 如果错误，而且渠道为空，说明是没有可用渠道
-					abortWithOpenAiMessage(c, http.StatusServiceUnavailable, message)
-					return
-				}
-				if channel == nil {
-					abortWithOpenAiMessage(c, http.StatusServiceUnavailable, fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道（数据库一致性已被破坏）", userGroup, modelRequest.Model))
+				var selectErr *ChannelSelectError
+				channel, selectErr = SelectRequestChannel(c, userGroup, modelRequest)
+				if selectErr != nil {
+					abortWithOpenAiMessage(c, selectErr.StatusCode, selectErr.Message)
 					return
 				}
 			}
@@ -121,6 +98,128 @@ func Distribute() func(c *gin.Context) {
 	}
 }
 
+// ChannelSelectError 检查模型或选择渠道失败时返回给客户端的状态码与提示
+type ChannelSelectError struct {
+	StatusCode int
+	Message    string
+}
+
+// ResolveRequestModelAlias 先将别名替换为实际的模型，令牌的模型限制与渠道选择都使用实际的模型
+func ResolveRequestModelAlias(c *gin.Context, group string, modelRequest *ModelRequest) {
+	if modelRequest.Model == "" || !service.CanReplaceRequestModel(c) {
+		return
+	}
+	aliasModel := service.ResolveModelAlias(c, group, modelRequest.Model)
+	if aliasModel == "" {
+		return
+	}
+	if err := service.ReplaceRequestModel(c, aliasModel); err != nil {
+		logging.LogError(c, "replace request model failed: "+err.Error())
+		return
+	}
+	c.Set("model_alias", modelRequest.Model)
+	modelRequest.Model = aliasModel
+}
+
+// CheckTokenModelLimit 检查令牌是否有权访问模型
+func CheckTokenModelLimit(c *gin.Context, modelName string) *ChannelSelectError {
+	if !c.GetBool("token_model_limit_enabled") {
+		return nil
+	}
+	s, ok := c.Get("token_model_limit")
+	var tokenModelLimit map[string]bool
+	if ok {
+		tokenModelLimit = s.(map[string]bool)
+	} else {
+		tokenModelLimit = map[string]bool{}
+	}
+	if tokenModelLimit == nil {
+		// token model limit is empty, all models are not allowed
+		return &ChannelSelectError{StatusCode: http.StatusForbidden, Message: "该令牌无权访问任何模型"}
+	}
+	if _, ok := tokenModelLimit[modelName]; !ok {
+		return &ChannelSelectError{StatusCode: http.StatusForbidden, Message: "该令牌无权访问模型 " + modelName}
+	}
+	return nil
+}
+
+// SelectRequestChannel 按分组的渠道选择方式为请求选择渠道：渠道全部饱和时排队等待，
+// 没有可用渠道时按降级链选择其他模型，降级后 modelRequest.Model 为实际使用的模型
+func SelectRequestChannel(c *gin.Context, group string, modelRequest *ModelRequest) (*model.Channel, *ChannelSelectError) {
+	stickyKey := ""
+	if setting.GetGroupChannelSelect(group) == setting.ChannelSelectSticky {
+		var user string
+		if err := json.Unmarshal(modelRequest.User, &user); err != nil {
+			user = string(modelRequest.User)
+		}
+		stickyKey = service.GetStickyRoutingKey(c, user)
+	}
+	channel, err := model.CacheGetStickySatisfiedChannel(group, modelRequest.Model, 0, stickyKey)
+	fallback := func() {
+		// 请求的模型没有可用渠道时，按降级链选择其他模型
+		if fallbackChannel, fallbackModel := selectFallbackChannel(c, group, modelRequest.Model); fallbackChannel != nil {
+			c.Set("requested_model", modelRequest.Model)
+			channel, err = fallbackChannel, nil
+			modelRequest.Model = fallbackModel
+		}
+	}
+	queued := errors.Is(err, model.ErrChannelsSaturated) && setting.RelayQueueEnabled
+	if err != nil && !queued && service.ShouldModelFallback(c) {
+		fallback()
+	}
+	if queued {
+		// 渠道全部饱和时先排队等待空闲的渠道，排队超时或队列已满时再降级到其他模型
+		queueErr := service.WaitRelayQueue(c, group, modelRequest.Model, service.RelayQueueDeadline(), 0, func() (bool, error) {
+			channel, err = model.CacheGetStickySatisfiedChannel(group, modelRequest.Model, 0, stickyKey)
+			if errors.Is(err, model.ErrChannelsSaturated) {
+				return false, nil
+			}
+			return err == nil, err
+		})
+		if (errors.Is(queueErr, service.ErrRelayQueueTimeout) || errors.Is(queueErr, service.ErrRelayQueueFull)) && service.ShouldModelFallback(c) {
+			fallback()
+		}
+		// 渠道选择的其他错误由下面统一处理
+		if err != nil && queueErr != nil && queueErr != err {
+			return nil, &ChannelSelectError{StatusCode: http.StatusTooManyRequests, Message: service.RelayQueueErrorMessage(queueErr)}
+		}
+	}
+	if errors.Is(err, model.ErrChannelsSaturated) {
+		return nil, &ChannelSelectError{StatusCode: http.StatusTooManyRequests, Message: "当前分组上游负载已饱和，请稍后再试"}
+	}
+	if err != nil {
+		message := fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", group, modelRequest.Model)
+		// 如果错误，但是渠道不为空，说明是数据库一致性问题
+		if channel != nil {
+			logging.SysError(fmt.Sprintf("渠道不存在：%d", channel.Id))
+			message = "数据库一致性已被破坏，请联系管理员"
+		}
+		// 如果错误，而且渠道为空，说明是没有可用渠道
+		return nil, &ChannelSelectError{StatusCode: http.StatusServiceUnavailable, Message: message}
+	}
+	if channel == nil {
+		return nil, &ChannelSelectError{StatusCode: http.StatusServiceUnavailable, Message: fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道（数据库一致性已被破坏）", group, modelRequest.Model)}
+	}
+	return channel, nil
+}
+
+// selectFallbackChannel 返回降级链中第一个有可用渠道的模型，并将请求中的模型替换为该模型
+func selectFallbackChannel(c *gin.Context, group string, modelName string) (*model.Channel, string) {
+	for _, fallbackModel := range service.GetModelFallbackChain(c, modelName) {
+		channel, err := model.CacheGetRandomSatisfiedChannel(group, fallbackModel, 0)
+		if err != nil || channel == nil {
+			continue
+		}
+		if err = service.ReplaceRequestModel(c, fallbackModel); err != nil {
+			logging.LogError(c, "replace request model failed: "+err.Error())
+			return nil, ""
+		}
+		logging.LogInfo(c, fmt.Sprintf("模型降级：%s -> %s", modelName, fallbackModel))
+		return channel, fallbackModel
+	}
+	return nil, ""
+}
+
 func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	var modelRequest ModelRequest
 	shouldSelectChannel := true
@@ -178,6 +277,14 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 		//wss://api.openai.com/v1/realtime?model=gpt-4o-realtime-preview-2024-10-01
 		modelRequest.Model = c.Query("model")
 	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1beta/models/") {
+		// /v1beta/models/gemini-pro:generateContent
+		modelName := c.Param("model")
+		if index := strings.LastIndex(modelName, ":"); index >= 0 {
+			modelName = modelName[:index]
+		}
+		modelRequest.Model = modelName
+	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/moderations") {
 		if modelRequest.Model == "" {
 			modelRequest.Model = "text-moderation-stable"
@@ -191,6 +298,10 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/images/generations") {
 		modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "dall-e")
 	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/images/edits") || strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
+		modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, c.PostForm("model"))
+		modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "dall-e-2")
+	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/audio") {
 		relayMode := relayconstant.RelayModeAudioSpeech
 		if strings.HasPrefix(c.Request.URL.Path, "/v1/audio/speech") {
@@ -224,7 +335,13 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	c.Set("auto_ban", channel.GetAutoBan())
 	c.Set("model_mapping", channel.GetModelMapping())
 	c.Set("status_code_mapping", channel.GetStatusCodeMapping())
-	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
+	// 多密钥渠道在每次请求时选择一个密钥
+	key, keyId, err := channel.SelectKey()
+	if err != nil {
+		logging.LogError(c, fmt.Sprintf("select key of channel #%d failed: %s", channel.Id, err.Error()))
+	}
+	c.Set("channel_key_id", keyId)
+	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 	c.Set("base_url", channel.GetBaseURL())
 	// TODO: api_version统一
 	switch channel.Type {
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"one-api/logging")
+
+// RelayRateLimit 按令牌与用户限制中继请求的每分钟请求数、每分钟 token 数与流式并发数，
+// 需要在 Distribute 之后使用，提示 token 数在计算后由中继流程检查
+func RelayRateLimit() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		scopes := service.GetRelayRateLimitScopes(c)
+		if len(scopes) == 0 {
+			c.Next()
+			return
+		}
+		release, limitErr := service.AcquireRelayRateLimit(c, scopes, isStreamRequest(c))
+		service.SetRelayRateLimitHeaders(c, scopes)
+		if limitErr != nil {
+			abortWithRateLimitMessage(c, limitErr)
+			return
+		}
+		defer release()
+		c.Next()
+	}
+}
+
+func isStreamRequest(c *gin.Context) bool {
+	path := c.Request.URL.Path
+	if strings.HasPrefix(path, "/v1/realtime") || strings.Contains(path, "streamGenerateContent") {
+		return true
+	}
+	var streamRequest struct {
+		Stream bool `json:"stream"`
+	}
+	if err := common.UnmarshalBodyReusable(c, &streamRequest); err != nil {
+		return false
+	}
+	return streamRequest.Stream
+}
+
+func abortWithRateLimitMessage(c *gin.Context, limitErr *service.RelayRateLimitError) {
+	message := common.MessageWithRequestId(limitErr.Message, c.GetString(common.RequestIdKey))
+	if !abortWithNativeError(c, http.StatusTooManyRequests, message) {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": gin.H{
+				"message": message,
+				"type":    limitErr.Type,
+				"param":   nil,
+				"code":    "rate_limit_exceeded",
+			},
+		})
+		c.Abort()
+	}
+	logging.LogWarn(c.Request.Context(), fmt.Sprintf("user %d | %s", c.GetInt("id"), limitErr.Message))
+}
@@ -4,19 +4,47 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"one-api/common"
+	"one-api/dto"
 	"one-api/logging"
+	"one-api/relay/channel/claude"
+	"one-api/relay/channel/gemini"
+	relayconstant "one-api/relay/constant"
 )
 
 func abortWithOpenAiMessage(c *gin.Context, statusCode int, message string) {
 	userId := c.GetInt("id")
-	c.JSON(statusCode, gin.H{
-		"error": gin.H{
-			"message": common.MessageWithRequestId(message, c.GetString(common.RequestIdKey)),
-			"type":    "new_api_error",
+	message = common.MessageWithRequestId(message, c.GetString(common.RequestIdKey))
+	if !abortWithNativeError(c, statusCode, message) {
+		c.JSON(statusCode, gin.H{
+			"error": gin.H{
+				"message": message,
+				"type":    "new_api_error",
+			},
+		})
+		c.Abort()
+	}
+	logging.LogError(c.Request.Context(), fmt.Sprintf("user %d | %s", userId, message))
+}
+
+// abortWithNativeError Claude 与 Gemini 原生接口按各自的错误格式返回，与 Relay 的错误输出保持一致
+func abortWithNativeError(c *gin.Context, statusCode int, message string) bool {
+	openaiErr := &dto.OpenAIErrorWithStatusCode{
+		Error: dto.OpenAIError{
+			Message: message,
+			Type:    "new_api_error",
 		},
-	})
+		StatusCode: statusCode,
+	}
+	switch relayconstant.Path2RelayMode(c.Request.URL.Path) {
+	case relayconstant.RelayModeClaudeMessages:
+		c.JSON(statusCode, claude.ErrorOpenAI2Claude(openaiErr))
+	case relayconstant.RelayModeGeminiGenerateContent:
+		c.JSON(statusCode, gemini.ErrorOpenAI2Gemini(openaiErr))
+	default:
+		return false
+	}
 	c.Abort()
-	logging.LogError(c.Request.Context(), fmt.Sprintf("user %d | %s", userId, message))
+	return true
 }
 
 func abortWithMidjourneyMessage(c *gin.Context, statusCode int, code int, description string) {
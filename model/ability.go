@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"one-api/common"
 	"one-api/logging"
+	"one-api/setting"
 	"strings"
 
 	"github.com/samber/lo"
@@ -25,7 +26,8 @@ func GetGroupModels(group string) []string {
 	var models []string
 	// Find distinct models
 	DB.Table("abilities").Where(groupCol+" = ? and enabled = ?", group, true).Distinct("model").Pluck("model", &models)
-	return models
+	// 以模式声明的模型展开为匹配的已知模型
+	return common.ExpandModelPatterns(models)
 }
 
 func GetEnabledModels() []string {
@@ -94,21 +96,50 @@ func getChannelQuery(group string, model string, retry int) *gorm.DB {
 	return channelQuery
 }
 
-func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
+func GetRandomSatisfiedChannel(group string, model string, retry int, stickyKey string) (*Channel, error) {
 	var abilities []Ability
 
 	var err error = nil
-	channelQuery := getChannelQuery(group, model, retry)
-	if common.UsingSQLite || common.UsingPostgreSQL {
-		err = channelQuery.Order("weight DESC").Find(&abilities).Error
-	} else {
-		err = channelQuery.Order("weight DESC").Find(&abilities).Error
-	}
+	abilities, matched, err := getPatternAbilities(group, model, retry)
 	if err != nil {
 		return nil, err
 	}
-	channel := Channel{}
+	if !matched {
+		channelQuery := getChannelQuery(group, model, retry)
+		if common.UsingSQLite || common.UsingPostgreSQL {
+			err = channelQuery.Order("weight DESC").Find(&abilities).Error
+		} else {
+			err = channelQuery.Order("weight DESC").Find(&abilities).Error
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	abilities = filterAbilitiesByBreaker(abilities, model)
 	if len(abilities) > 0 {
+		abilities = filterAbilitiesByLimit(abilities, model)
+		if len(abilities) == 0 {
+			return nil, ErrChannelsSaturated
+		}
+	}
+	channel := Channel{}
+	if len(abilities) > 0 && stickyKey != "" && setting.GetGroupChannelSelect(group) == setting.ChannelSelectSticky {
+		channelIds := make([]int, len(abilities))
+		weights := make([]int, len(abilities))
+		for i, ability_ := range abilities {
+			channelIds[i] = ability_.ChannelId
+			weights[i] = int(ability_.Weight) + 10
+		}
+		channel.Id = channelIds[stickyChannelIndex(stickyKey, channelIds, weights)]
+	} else if len(abilities) > 0 && setting.GetGroupChannelSelect(group) == setting.ChannelSelectHealth {
+		channelIds := make([]int, len(abilities))
+		weights := make([]int, len(abilities))
+		for i, ability_ := range abilities {
+			channelIds[i] = ability_.ChannelId
+			weights[i] = int(ability_.Weight) + 10
+		}
+		channel.Id = channelIds[selectChannelByHealth(channelIds, weights)]
+	} else if len(abilities) > 0 {
 		// Randomly choose one
 		weightSum := uint(0)
 		for _, ability_ := range abilities {
@@ -127,6 +158,7 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	} else {
 		return nil, errors.New("channel not found")
 	}
+	acquireChannelBreakerProbe(channel.Id, model)
 	err = DB.First(&channel, "id = ?", channel.Id).Error
 	return &channel, err
 }
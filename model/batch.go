@@ -0,0 +1,275 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/dto"
+	"time"
+)
+
+type BatchStatus string
+
+const (
+	BatchStatusValidating BatchStatus = "validating"
+	BatchStatusFailed     BatchStatus = "failed"
+	BatchStatusInProgress BatchStatus = "in_progress"
+	BatchStatusFinalizing BatchStatus = "finalizing"
+	BatchStatusCompleted  BatchStatus = "completed"
+	BatchStatusExpired    BatchStatus = "expired"
+	BatchStatusCancelling BatchStatus = "cancelling"
+	BatchStatusCancelled  BatchStatus = "cancelled"
+)
+
+type Batch struct {
+	ID               int64           `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	CreatedAt        int64           `json:"created_at" gorm:"index"`
+	UpdatedAt        int64           `json:"updated_at"`
+	BatchId          string          `json:"batch_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId           int             `json:"user_id" gorm:"index"`
+	TokenId          int             `json:"token_id" gorm:"index"`
+	Endpoint         string          `json:"endpoint" gorm:"type:varchar(64)"`
+	CompletionWindow string          `json:"completion_window" gorm:"type:varchar(20)"`
+	InputFileId      string          `json:"input_file_id" gorm:"type:varchar(64)"`
+	OutputFileId     string          `json:"output_file_id" gorm:"type:varchar(64)"`
+	ErrorFileId      string          `json:"error_file_id" gorm:"type:varchar(64)"`
+	Status           BatchStatus     `json:"status" gorm:"type:varchar(20);index"`
+	FailReason       string          `json:"fail_reason"`
+	Progress         string          `json:"progress" gorm:"type:varchar(20);index"`
+	TotalCount       int             `json:"total_count"`
+	CompletedCount   int             `json:"completed_count"`
+	FailedCount      int             `json:"failed_count"`
+	InProgressAt     int64           `json:"in_progress_at"`
+	FinalizingAt     int64           `json:"finalizing_at"`
+	FinishTime       int64           `json:"finish_time" gorm:"index"`
+	CancellingAt     int64           `json:"cancelling_at"`
+	ExpiresAt        int64           `json:"expires_at"`
+	Metadata         json.RawMessage `json:"metadata" gorm:"type:json"`
+}
+
+func (b *Batch) IsFinished() bool {
+	switch b.Status {
+	case BatchStatusCompleted, BatchStatusFailed, BatchStatusExpired, BatchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (b *Batch) UpdateProgress() {
+	if b.TotalCount == 0 {
+		b.Progress = "0%"
+		return
+	}
+	b.Progress = fmt.Sprintf("%d%%", (b.CompletedCount+b.FailedCount)*100/b.TotalCount)
+}
+
+func optionalInt64(v int64) *int64 {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
+
+func optionalString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+func (b *Batch) ToOpenAIBatch() dto.OpenAIBatch {
+	batch := dto.OpenAIBatch{
+		Id:               b.BatchId,
+		Object:           "batch",
+		Endpoint:         b.Endpoint,
+		InputFileId:      b.InputFileId,
+		CompletionWindow: b.CompletionWindow,
+		Status:           string(b.Status),
+		OutputFileId:     optionalString(b.OutputFileId),
+		ErrorFileId:      optionalString(b.ErrorFileId),
+		CreatedAt:        b.CreatedAt,
+		InProgressAt:     optionalInt64(b.InProgressAt),
+		ExpiresAt:        optionalInt64(b.ExpiresAt),
+		FinalizingAt:     optionalInt64(b.FinalizingAt),
+		CancellingAt:     optionalInt64(b.CancellingAt),
+		RequestCounts: dto.BatchRequestCounts{
+			Total:     b.TotalCount,
+			Completed: b.CompletedCount,
+			Failed:    b.FailedCount,
+		},
+	}
+	switch b.Status {
+	case BatchStatusCompleted:
+		batch.CompletedAt = optionalInt64(b.FinishTime)
+	case BatchStatusFailed:
+		batch.FailedAt = optionalInt64(b.FinishTime)
+	case BatchStatusExpired:
+		batch.ExpiredAt = optionalInt64(b.FinishTime)
+	case BatchStatusCancelled:
+		batch.CancelledAt = optionalInt64(b.FinishTime)
+	}
+	if b.FailReason != "" {
+		batch.Errors = &dto.BatchErrors{
+			Object: "list",
+			Data: []dto.BatchError{
+				{Code: "batch_failed", Message: b.FailReason},
+			},
+		}
+	}
+	if len(b.Metadata) > 0 {
+		_ = json.Unmarshal(b.Metadata, &batch.Metadata)
+	}
+	return batch
+}
+
+func (b *Batch) Insert() error {
+	now := time.Now().Unix()
+	b.CreatedAt = now
+	b.UpdatedAt = now
+	return DB.Create(b).Error
+}
+
+func (b *Batch) Update() error {
+	b.UpdatedAt = time.Now().Unix()
+	return DB.Save(b).Error
+}
+
+func GetUserBatchByBatchId(userId int, batchId string) (*Batch, bool, error) {
+	var batch *Batch
+	err := DB.Where("user_id = ? and batch_id = ?", userId, batchId).First(&batch).Error
+	exist, err := RecordExist(err)
+	if err != nil {
+		return nil, false, err
+	}
+	return batch, exist, nil
+}
+
+func GetBatchById(id int64) (*Batch, error) {
+	var batch Batch
+	err := DB.First(&batch, "id = ?", id).Error
+	return &batch, err
+}
+
+// GetUserBatches 按 id 倒序分页，after 为上一页最后一个 batch 的 batch_id
+func GetUserBatches(userId int, after string, limit int) ([]*Batch, error) {
+	var batches []*Batch
+	query := DB.Where("user_id = ?", userId)
+	if after != "" {
+		var afterBatch Batch
+		if err := DB.Select("id").Where("user_id = ? and batch_id = ?", userId, after).First(&afterBatch).Error; err == nil {
+			query = query.Where("id < ?", afterBatch.ID)
+		}
+	}
+	err := query.Order("id desc").Limit(limit).Find(&batches).Error
+	return batches, err
+}
+
+// ClaimPendingBatch 取出最早提交的待执行 batch 并标记为 in_progress，多个 worker 并发调用时只有一个能成功
+func ClaimPendingBatch() (*Batch, error) {
+	var batches []*Batch
+	err := DB.Where("status = ?", BatchStatusValidating).Order("id").Limit(1).Find(&batches).Error
+	if err != nil || len(batches) == 0 {
+		return nil, err
+	}
+	batch := batches[0]
+	now := time.Now().Unix()
+	result := DB.Model(&Batch{}).Where("id = ? and status = ?", batch.ID, BatchStatusValidating).Updates(map[string]any{
+		"status":         BatchStatusInProgress,
+		"in_progress_at": now,
+		"updated_at":     now,
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	batch.Status = BatchStatusInProgress
+	batch.InProgressAt = now
+	batch.UpdatedAt = now
+	return batch, nil
+}
+
+// RecoverInterruptedBatches 服务重启后，执行中的 batch 无法继续，直接标记为结束
+func RecoverInterruptedBatches() error {
+	now := time.Now().Unix()
+	err := DB.Model(&Batch{}).Where("status in (?)", []BatchStatus{BatchStatusInProgress, BatchStatusFinalizing}).Updates(map[string]any{
+		"status":      BatchStatusFailed,
+		"fail_reason": "batch interrupted by server restart",
+		"progress":    "100%",
+		"finish_time": now,
+		"updated_at":  now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return DB.Model(&Batch{}).Where("status = ?", BatchStatusCancelling).Updates(map[string]any{
+		"status":      BatchStatusCancelled,
+		"progress":    "100%",
+		"finish_time": now,
+		"updated_at":  now,
+	}).Error
+}
+
+// CancelBatch 尚未开始的 batch 直接取消，执行中的 batch 标记为 cancelling，由 worker 完成取消
+func CancelBatch(b *Batch) error {
+	now := time.Now().Unix()
+	result := DB.Model(&Batch{}).Where("id = ? and status = ?", b.ID, BatchStatusValidating).Updates(map[string]any{
+		"status":        BatchStatusCancelled,
+		"progress":      "100%",
+		"cancelling_at": now,
+		"finish_time":   now,
+		"updated_at":    now,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		err := DB.Model(&Batch{}).Where("id = ? and status = ?", b.ID, BatchStatusInProgress).Updates(map[string]any{
+			"status":        BatchStatusCancelling,
+			"cancelling_at": now,
+			"updated_at":    now,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return DB.First(b, "id = ?", b.ID).Error
+}
+
+// UpdateBatchProgress 只更新计数和进度，避免覆盖并发写入的状态
+func UpdateBatchProgress(b *Batch) error {
+	b.UpdatedAt = time.Now().Unix()
+	return DB.Model(&Batch{}).Where("id = ?", b.ID).Updates(map[string]any{
+		"completed_count": b.CompletedCount,
+		"failed_count":    b.FailedCount,
+		"progress":        b.Progress,
+		"updated_at":      b.UpdatedAt,
+	}).Error
+}
+
+// UpdateBatchUnlessCancelled 只更新指定字段，batch 已被取消（cancelling/cancelled）时不更新，返回是否更新成功
+func UpdateBatchUnlessCancelled(b *Batch, fields map[string]any) (bool, error) {
+	b.UpdatedAt = time.Now().Unix()
+	fields["updated_at"] = b.UpdatedAt
+	result := DB.Model(&Batch{}).Where("id = ? and status not in (?)", b.ID, []BatchStatus{BatchStatusCancelling, BatchStatusCancelled}).Updates(fields)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FinishCancellingBatch 将 cancelling 状态的 batch 标记为 cancelled，保留已经生成的结果文件
+func FinishCancellingBatch(b *Batch) error {
+	now := time.Now().Unix()
+	err := DB.Model(&Batch{}).Where("id = ? and status = ?", b.ID, BatchStatusCancelling).Updates(map[string]any{
+		"status":          BatchStatusCancelled,
+		"output_file_id":  b.OutputFileId,
+		"error_file_id":   b.ErrorFileId,
+		"completed_count": b.CompletedCount,
+		"failed_count":    b.FailedCount,
+		"progress":        "100%",
+		"finish_time":     now,
+		"updated_at":      now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return DB.First(b, "id = ?", b.ID).Error
+}
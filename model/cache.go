@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/rand"
 	"one-api/common"
+	"one-api/setting"
 	"sort"
 	"strings"
 	"sync"
@@ -157,10 +158,16 @@ func InitChannelCache() {
 		}
 	}
 
+	newGroup2modelPatterns := buildGroupModelPatterns(newGroup2model2channels)
+
 	channelSyncLock.Lock()
 	group2model2channels = newGroup2model2channels
+	group2modelPatterns = newGroup2modelPatterns
 	channelsIDM = newChannelsIDM
+	resetChannelPatternMatchCache()
 	channelSyncLock.Unlock()
+	initChannelKeyCache()
+	initChannelLimits(channels)
 	common.SysLog("channels synced from database")
 }
 
@@ -173,6 +180,12 @@ func SyncChannelCache(frequency int) {
 }
 
 func CacheGetRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
+	return CacheGetStickySatisfiedChannel(group, model, retry, "")
+}
+
+// CacheGetStickySatisfiedChannel 分组使用 sticky 策略时，相同 stickyKey 的请求在同一优先级内固定选择同一个渠道，
+// 以便命中上游的提示词缓存；stickyKey 为空或粘性渠道不可用时按常规方式选择
+func CacheGetStickySatisfiedChannel(group string, model string, retry int, stickyKey string) (*Channel, error) {
 	if strings.HasPrefix(model, "gpt-4-gizmo") {
 		model = "gpt-4-gizmo-*"
 	}
@@ -182,12 +195,18 @@ func CacheGetRandomSatisfiedChannel(group string, model string, retry int) (*Cha
 
 	// if memory cache is disabled, get channel directly from database
 	if !common.MemoryCacheEnabled {
-		return GetRandomSatisfiedChannel(group, model, retry)
+		return GetRandomSatisfiedChannel(group, model, retry, stickyKey)
 	}
 	channelSyncLock.RLock()
 	defer channelSyncLock.RUnlock()
-	channels := group2model2channels[group][model]
+	// 跳过已熔断或已达到上游限制的渠道，整个优先级都不可用时由低优先级的渠道处理
+	modelChannels := getGroupModelChannels(group, model)
+	candidates := filterChannelsByBreaker(modelChannels, model)
+	channels := filterChannelsByLimit(candidates, model)
 	if len(channels) == 0 {
+		if len(candidates) > 0 {
+			return nil, ErrChannelsSaturated
+		}
 		return nil, errors.New("channel not found")
 	}
 
@@ -216,6 +235,29 @@ func CacheGetRandomSatisfiedChannel(group string, model string, retry int) (*Cha
 
 	// 平滑系数
 	smoothingFactor := 10
+	if stickyKey != "" && setting.GetGroupChannelSelect(group) == setting.ChannelSelectSticky {
+		var tier []*Channel
+		for _, channel := range modelChannels {
+			if channel.GetPriority() == targetPriority {
+				tier = append(tier, channel)
+			}
+		}
+		if channel := selectStickyChannel(stickyKey, tier, targetChannels, smoothingFactor); channel != nil {
+			acquireChannelBreakerProbe(channel.Id, model)
+			return channel, nil
+		}
+	}
+	if setting.GetGroupChannelSelect(group) == setting.ChannelSelectHealth {
+		channelIds := make([]int, len(targetChannels))
+		weights := make([]int, len(targetChannels))
+		for i, channel := range targetChannels {
+			channelIds[i] = channel.Id
+			weights[i] = channel.GetWeight() + smoothingFactor
+		}
+		channel := targetChannels[selectChannelByHealth(channelIds, weights)]
+		acquireChannelBreakerProbe(channel.Id, model)
+		return channel, nil
+	}
 	// Calculate the total weight of all channels up to endIdx
 	totalWeight := 0
 	for _, channel := range targetChannels {
@@ -228,6 +270,7 @@ func CacheGetRandomSatisfiedChannel(group string, model string, retry int) (*Cha
 	for _, channel := range targetChannels {
 		randomWeight -= channel.GetWeight() + smoothingFactor
 		if randomWeight < 0 {
+			acquireChannelBreakerProbe(channel.Id, model)
 			return channel, nil
 		}
 	}
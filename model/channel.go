@@ -44,6 +44,7 @@ type Channel struct {
 	OtherInfo          string  `json:"other_info"`
 	Tag                *string `json:"tag" gorm:"index:idx_tag"`
 	Setting            string  `json:"setting" gorm:"type:text"`
+	MultiKeyMode       string  `json:"multi_key_mode" gorm:"type:varchar(16);default:''"`
 }
 
 // Cache keys
@@ -226,6 +227,11 @@ func BatchInsertChannels(channels []Channel) error {
 		if err != nil {
 			return err
 		}
+		if channel_.IsMultiKey() {
+			if err = SyncChannelKeys(&channel_); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -247,6 +253,9 @@ func BatchDeleteChannels(ids []int) error {
 	}
 	// 提交事务
 	tx.Commit()
+	if err = DeleteChannelKeys(ids); err != nil {
+		logging.SysError("failed to delete channel keys: " + err.Error())
+	}
 	return err
 }
 
@@ -292,6 +301,12 @@ func (channel *Channel) Insert() error {
 		return err
 	}
 	err = channel.AddAbilities()
+	if err != nil {
+		return err
+	}
+	if channel.IsMultiKey() {
+		err = SyncChannelKeys(channel)
+	}
 	return err
 }
 
@@ -303,6 +318,10 @@ func (channel *Channel) Update() error {
 	}
 	DB.Model(channel).First(channel, "id = ?", channel.Id)
 	err = channel.UpdateAbilities(nil)
+	if err != nil {
+		return err
+	}
+	err = SyncChannelKeys(channel)
 	return err
 }
 
@@ -333,6 +352,10 @@ func (channel *Channel) Delete() error {
 		return err
 	}
 	err = channel.DeleteAbilities()
+	if err != nil {
+		return err
+	}
+	err = DeleteChannelKeys([]int{channel.Id})
 	return err
 }
 
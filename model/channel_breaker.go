@@ -0,0 +1,308 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"one-api/logging"
+	"one-api/setting"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	ChannelBreakerStateClosed   = "closed"
+	ChannelBreakerStateOpen     = "open"
+	ChannelBreakerStateHalfOpen = "half_open"
+
+	channelBreakerRedisKey = "channel_breaker"
+)
+
+// ChannelBreaker 渠道熔断器，Model 为空时作用于整个渠道，否则只作用于渠道下的该模型
+type ChannelBreaker struct {
+	ChannelId   int    `json:"channel_id"`
+	Model       string `json:"model,omitempty"`
+	State       string `json:"state"`
+	Failures    int    `json:"failures"`
+	WindowStart int64  `json:"window_start"`
+	OpenedAt    int64  `json:"opened_at"`
+	LastProbeAt int64  `json:"last_probe_at"`
+	UpdatedAt   int64  `json:"updated_at"`
+}
+
+var (
+	channelBreakers    = make(map[string]*ChannelBreaker)
+	channelBreakerLock sync.Mutex
+)
+
+func channelBreakerKey(channelId int, modelName string) string {
+	if modelName == "" {
+		return strconv.Itoa(channelId)
+	}
+	return fmt.Sprintf("%d:%s", channelId, modelName)
+}
+
+// currentState 冷却时间结束的熔断器视为半开
+func (breaker *ChannelBreaker) currentState(now int64) string {
+	if breaker.State == ChannelBreakerStateOpen && now-breaker.OpenedAt >= int64(setting.CircuitBreakerOpenDuration) {
+		return ChannelBreakerStateHalfOpen
+	}
+	return breaker.State
+}
+
+func (breaker *ChannelBreaker) available(now int64) bool {
+	switch breaker.currentState(now) {
+	case ChannelBreakerStateOpen:
+		return false
+	case ChannelBreakerStateHalfOpen:
+		return now-breaker.LastProbeAt >= int64(setting.CircuitBreakerProbeInterval)
+	}
+	return true
+}
+
+// record 记录一次请求结果，状态发生变化时返回 true
+func (breaker *ChannelBreaker) record(success bool, now int64) bool {
+	state := breaker.currentState(now)
+	if success {
+		if state != ChannelBreakerStateHalfOpen {
+			// 熔断前已发出的请求成功不代表渠道已恢复
+			return false
+		}
+		breaker.State = ChannelBreakerStateClosed
+		breaker.Failures = 0
+		breaker.WindowStart = now
+		breaker.UpdatedAt = now
+		return true
+	}
+	switch state {
+	case ChannelBreakerStateOpen:
+		return false
+	case ChannelBreakerStateHalfOpen:
+		breaker.State = ChannelBreakerStateOpen
+		breaker.OpenedAt = now
+		breaker.UpdatedAt = now
+		return true
+	}
+	if now-breaker.WindowStart > int64(setting.CircuitBreakerWindow) {
+		breaker.WindowStart = now
+		breaker.Failures = 0
+	}
+	breaker.Failures++
+	if breaker.Failures < setting.CircuitBreakerFailureThreshold {
+		return false
+	}
+	breaker.State = ChannelBreakerStateOpen
+	breaker.OpenedAt = now
+	breaker.Failures = 0
+	breaker.UpdatedAt = now
+	return true
+}
+
+func getOrCreateChannelBreaker(channelId int, modelName string) *ChannelBreaker {
+	key := channelBreakerKey(channelId, modelName)
+	breaker, ok := channelBreakers[key]
+	if !ok {
+		breaker = &ChannelBreaker{
+			ChannelId: channelId,
+			Model:     modelName,
+			State:     ChannelBreakerStateClosed,
+		}
+		channelBreakers[key] = breaker
+	}
+	return breaker
+}
+
+// channelBreakerAvailable 渠道与渠道下的模型都没有熔断时可用，调用方需持有锁
+func channelBreakerAvailable(channelId int, modelName string, now int64) bool {
+	if breaker, ok := channelBreakers[channelBreakerKey(channelId, "")]; ok && !breaker.available(now) {
+		return false
+	}
+	if breaker, ok := channelBreakers[channelBreakerKey(channelId, modelName)]; ok && !breaker.available(now) {
+		return false
+	}
+	return true
+}
+
+// filterChannelsByBreaker 过滤掉已熔断的渠道
+func filterChannelsByBreaker(channels []*Channel, modelName string) []*Channel {
+	if !setting.CircuitBreakerEnabled {
+		return channels
+	}
+	now := time.Now().Unix()
+	channelBreakerLock.Lock()
+	defer channelBreakerLock.Unlock()
+	available := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if channelBreakerAvailable(channel.Id, modelName, now) {
+			available = append(available, channel)
+		}
+	}
+	return available
+}
+
+// filterAbilitiesByBreaker 过滤掉已熔断的渠道，用于未开启内存缓存时从数据库选择渠道
+func filterAbilitiesByBreaker(abilities []Ability, modelName string) []Ability {
+	if !setting.CircuitBreakerEnabled {
+		return abilities
+	}
+	now := time.Now().Unix()
+	channelBreakerLock.Lock()
+	defer channelBreakerLock.Unlock()
+	available := make([]Ability, 0, len(abilities))
+	for _, ability := range abilities {
+		if channelBreakerAvailable(ability.ChannelId, modelName, now) {
+			available = append(available, ability)
+		}
+	}
+	return available
+}
+
+// acquireChannelBreakerProbe 选中半开状态的渠道时记录探测时间，限制探测流量
+func acquireChannelBreakerProbe(channelId int, modelName string) {
+	if !setting.CircuitBreakerEnabled {
+		return
+	}
+	now := time.Now().Unix()
+	channelBreakerLock.Lock()
+	var probes []ChannelBreaker
+	for _, key := range []string{channelBreakerKey(channelId, ""), channelBreakerKey(channelId, modelName)} {
+		breaker, ok := channelBreakers[key]
+		if !ok || breaker.currentState(now) != ChannelBreakerStateHalfOpen {
+			continue
+		}
+		breaker.LastProbeAt = now
+		breaker.UpdatedAt = now
+		probes = append(probes, *breaker)
+	}
+	channelBreakerLock.Unlock()
+	for _, breaker := range probes {
+		publishChannelBreaker(breaker)
+	}
+}
+
+// RecordChannelBreaker 记录渠道请求结果，同时更新渠道与渠道下模型的熔断器
+func RecordChannelBreaker(channelId int, modelName string, success bool) {
+	if !setting.CircuitBreakerEnabled {
+		return
+	}
+	now := time.Now().Unix()
+	channelBreakerLock.Lock()
+	var changed []ChannelBreaker
+	for _, name := range []string{"", modelName} {
+		breaker := getOrCreateChannelBreaker(channelId, name)
+		if breaker.record(success, now) {
+			changed = append(changed, *breaker)
+		}
+		if modelName == "" {
+			break
+		}
+	}
+	channelBreakerLock.Unlock()
+	for _, breaker := range changed {
+		if breaker.State == ChannelBreakerStateOpen {
+			common.SysLog(fmt.Sprintf("channel #%d %s circuit breaker opened", breaker.ChannelId, breaker.Model))
+		} else {
+			common.SysLog(fmt.Sprintf("channel #%d %s circuit breaker closed", breaker.ChannelId, breaker.Model))
+		}
+		publishChannelBreaker(breaker)
+	}
+}
+
+// publishChannelBreaker 将熔断器状态同步到 Redis，失败次数只在本节点统计
+func publishChannelBreaker(breaker ChannelBreaker) {
+	if !common.RedisEnabled {
+		return
+	}
+	gopool.Go(func() {
+		data, err := json.Marshal(breaker)
+		if err != nil {
+			return
+		}
+		key := channelBreakerKey(breaker.ChannelId, breaker.Model)
+		err = common.RDB.HSet(context.Background(), channelBreakerRedisKey, key, string(data)).Err()
+		if err != nil {
+			logging.SysError("failed to update channel breaker: " + err.Error())
+		}
+	})
+}
+
+// SyncChannelBreaker 定期从 Redis 同步其他节点的熔断器状态
+func SyncChannelBreaker(frequency int) {
+	for {
+		time.Sleep(time.Duration(frequency) * time.Second)
+		values, err := common.RDB.HGetAll(context.Background(), channelBreakerRedisKey).Result()
+		if err != nil {
+			logging.SysError("failed to sync channel breaker: " + err.Error())
+			continue
+		}
+		channelBreakerLock.Lock()
+		for key, value := range values {
+			var remote ChannelBreaker
+			if err = json.Unmarshal([]byte(value), &remote); err != nil {
+				continue
+			}
+			local, ok := channelBreakers[key]
+			if !ok {
+				remote.Failures = 0
+				channelBreakers[key] = &remote
+				continue
+			}
+			if local.UpdatedAt < remote.UpdatedAt {
+				local.State = remote.State
+				local.OpenedAt = remote.OpenedAt
+				local.LastProbeAt = remote.LastProbeAt
+				local.UpdatedAt = remote.UpdatedAt
+			}
+		}
+		channelBreakerLock.Unlock()
+	}
+}
+
+// GetChannelBreakers 返回熔断器的当前状态，channelId 为 0 时返回所有渠道
+func GetChannelBreakers(channelId int) []ChannelBreaker {
+	now := time.Now().Unix()
+	channelBreakerLock.Lock()
+	breakers := make([]ChannelBreaker, 0)
+	for _, breaker := range channelBreakers {
+		if channelId != 0 && breaker.ChannelId != channelId {
+			continue
+		}
+		snapshot := *breaker
+		snapshot.State = breaker.currentState(now)
+		breakers = append(breakers, snapshot)
+	}
+	channelBreakerLock.Unlock()
+	sort.Slice(breakers, func(i, j int) bool {
+		if breakers[i].ChannelId != breakers[j].ChannelId {
+			return breakers[i].ChannelId < breakers[j].ChannelId
+		}
+		return breakers[i].Model < breakers[j].Model
+	})
+	return breakers
+}
+
+// ResetChannelBreaker 手动关闭渠道的所有熔断器
+func ResetChannelBreaker(channelId int) {
+	now := time.Now().Unix()
+	channelBreakerLock.Lock()
+	var reset []ChannelBreaker
+	for _, breaker := range channelBreakers {
+		if breaker.ChannelId != channelId {
+			continue
+		}
+		breaker.State = ChannelBreakerStateClosed
+		breaker.Failures = 0
+		breaker.WindowStart = now
+		breaker.UpdatedAt = now
+		reset = append(reset, *breaker)
+	}
+	channelBreakerLock.Unlock()
+	for _, breaker := range reset {
+		publishChannelBreaker(breaker)
+	}
+}
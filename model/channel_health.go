@@ -0,0 +1,183 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"one-api/common"
+	"one-api/logging"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	channelHealthRedisKey = "channel_health"
+	// EWMA 平滑系数，越大越偏向最近的请求
+	channelHealthAlpha = 0.2
+	// 超过该时间没有请求的渠道视为健康，避免被降权的渠道一直没有流量而无法恢复
+	channelHealthStaleDuration = 10 * time.Minute
+	// 降权的下限，保证异常渠道仍有少量流量用于探测恢复
+	channelHealthMinFactor = 0.05
+)
+
+// ChannelHealth 渠道最近请求的成功率、首字时间与耗时，均为 EWMA
+type ChannelHealth struct {
+	SuccessRate float64 `json:"success_rate"`
+	LatencyMs   float64 `json:"latency_ms"`
+	TTFTMs      float64 `json:"ttft_ms"`
+	Samples     int64   `json:"samples"`
+	UpdatedAt   int64   `json:"updated_at"`
+}
+
+var (
+	channelHealthM    = make(map[int]*ChannelHealth)
+	channelHealthLock sync.RWMutex
+)
+
+func ewma(old float64, sample float64) float64 {
+	if old == 0 {
+		return sample
+	}
+	return old*(1-channelHealthAlpha) + sample*channelHealthAlpha
+}
+
+// RecordChannelHealth 记录一次渠道请求的结果，ttft 为 0 表示非流式请求
+func RecordChannelHealth(channelId int, success bool, latency time.Duration, ttft time.Duration) {
+	successSample := 0.0
+	if success {
+		successSample = 1
+	}
+	channelHealthLock.Lock()
+	health, ok := channelHealthM[channelId]
+	if !ok {
+		health = &ChannelHealth{SuccessRate: 1}
+		channelHealthM[channelId] = health
+	}
+	health.SuccessRate = health.SuccessRate*(1-channelHealthAlpha) + successSample*channelHealthAlpha
+	// 失败请求的耗时不能反映渠道的速度
+	if success {
+		health.LatencyMs = ewma(health.LatencyMs, float64(latency.Milliseconds()))
+		if ttft > 0 {
+			health.TTFTMs = ewma(health.TTFTMs, float64(ttft.Milliseconds()))
+		}
+	}
+	health.Samples++
+	health.UpdatedAt = time.Now().Unix()
+	snapshot := *health
+	channelHealthLock.Unlock()
+
+	if common.RedisEnabled {
+		gopool.Go(func() {
+			data, err := json.Marshal(snapshot)
+			if err != nil {
+				return
+			}
+			err = common.RDB.HSet(context.Background(), channelHealthRedisKey, strconv.Itoa(channelId), string(data)).Err()
+			if err != nil {
+				logging.SysError("failed to update channel health: " + err.Error())
+			}
+		})
+	}
+}
+
+func GetChannelHealth(channelId int) (ChannelHealth, bool) {
+	channelHealthLock.RLock()
+	defer channelHealthLock.RUnlock()
+	health, ok := channelHealthM[channelId]
+	if !ok {
+		return ChannelHealth{}, false
+	}
+	return *health, true
+}
+
+// SyncChannelHealth 定期从 Redis 同步其他节点记录的渠道健康状况
+func SyncChannelHealth(frequency int) {
+	for {
+		time.Sleep(time.Duration(frequency) * time.Second)
+		values, err := common.RDB.HGetAll(context.Background(), channelHealthRedisKey).Result()
+		if err != nil {
+			logging.SysError("failed to sync channel health: " + err.Error())
+			continue
+		}
+		channelHealthLock.Lock()
+		for key, value := range values {
+			channelId, err := strconv.Atoi(key)
+			if err != nil {
+				continue
+			}
+			var health ChannelHealth
+			if err = json.Unmarshal([]byte(value), &health); err != nil {
+				continue
+			}
+			if local, ok := channelHealthM[channelId]; !ok || local.UpdatedAt <= health.UpdatedAt {
+				channelHealthM[channelId] = &health
+			}
+		}
+		channelHealthLock.Unlock()
+	}
+}
+
+// channelHealthFactors 计算同一优先级内各渠道的权重系数：成功率的平方乘以最快渠道与该渠道的耗时之比
+func channelHealthFactors(channelIds []int) []float64 {
+	now := time.Now().Unix()
+	healths := make([]*ChannelHealth, len(channelIds))
+	minLatency := 0.0
+	channelHealthLock.RLock()
+	for i, channelId := range channelIds {
+		health, ok := channelHealthM[channelId]
+		if !ok || now-health.UpdatedAt > int64(channelHealthStaleDuration.Seconds()) {
+			continue
+		}
+		snapshot := *health
+		healths[i] = &snapshot
+		if latency := snapshot.scoreLatency(); latency > 0 && (minLatency == 0 || latency < minLatency) {
+			minLatency = latency
+		}
+	}
+	channelHealthLock.RUnlock()
+
+	factors := make([]float64, len(channelIds))
+	for i, health := range healths {
+		if health == nil {
+			factors[i] = 1
+			continue
+		}
+		factor := health.SuccessRate * health.SuccessRate
+		if latency := health.scoreLatency(); latency > 0 && minLatency > 0 {
+			factor *= minLatency / latency
+		}
+		if factor < channelHealthMinFactor {
+			factor = channelHealthMinFactor
+		}
+		factors[i] = factor
+	}
+	return factors
+}
+
+// scoreLatency 优先使用首字时间，没有流式请求时使用总耗时
+func (health *ChannelHealth) scoreLatency() float64 {
+	if health.TTFTMs > 0 {
+		return health.TTFTMs
+	}
+	return health.LatencyMs
+}
+
+// selectChannelByHealth 按静态权重与健康系数加权随机选择，返回选中的下标
+func selectChannelByHealth(channelIds []int, weights []int) int {
+	factors := channelHealthFactors(channelIds)
+	totalWeight := 0.0
+	for i := range channelIds {
+		totalWeight += float64(weights[i]) * factors[i]
+	}
+	randomWeight := rand.Float64() * totalWeight
+	for i := range channelIds {
+		randomWeight -= float64(weights[i]) * factors[i]
+		if randomWeight < 0 {
+			return i
+		}
+	}
+	return len(channelIds) - 1
+}
@@ -0,0 +1,354 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"one-api/common"
+	"one-api/logging"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// 多密钥渠道的密钥选择方式，为空时渠道只使用一个密钥
+const (
+	ChannelMultiKeyModeRoundRobin = "round_robin"
+	ChannelMultiKeyModeRandom     = "random"
+	ChannelMultiKeyModeLeastUsed  = "least_used"
+)
+
+// ChannelKey 多密钥渠道中的单个密钥，由渠道的 Key（每行一个密钥）同步生成
+type ChannelKey struct {
+	Id             int    `json:"id"`
+	ChannelId      int    `json:"channel_id" gorm:"index"`
+	Key            string `json:"key" gorm:"type:text"`
+	Status         int    `json:"status" gorm:"default:1"`
+	UsedQuota      int64  `json:"used_quota" gorm:"bigint;default:0"`
+	RequestCount   int    `json:"request_count" gorm:"default:0"`
+	DisabledReason string `json:"disabled_reason"`
+	DisabledTime   int64  `json:"disabled_time" gorm:"bigint"`
+}
+
+var (
+	channelKeyCache     = make(map[int][]*ChannelKey)
+	channelKeyCacheLock sync.RWMutex
+	// 轮询游标，以及本节点自上次从数据库加载密钥后每个密钥被选中的次数，
+	// 最少使用按持久化的 RequestCount 加上该次数比较，加载密钥时清零以免与已持久化的请求数重复计算
+	channelKeyCursor    = make(map[int]int)
+	channelKeyUseCount  = make(map[int]int64)
+	channelKeySelectMux sync.Mutex
+)
+
+func IsValidChannelMultiKeyMode(mode string) bool {
+	switch mode {
+	case "", ChannelMultiKeyModeRoundRobin, ChannelMultiKeyModeRandom, ChannelMultiKeyModeLeastUsed:
+		return true
+	}
+	return false
+}
+
+func (channel *Channel) IsMultiKey() bool {
+	return channel.MultiKeyMode != ""
+}
+
+// GetKeys 返回渠道配置的所有密钥，单密钥渠道只返回 Key 本身
+func (channel *Channel) GetKeys() []string {
+	if !channel.IsMultiKey() {
+		return []string{channel.Key}
+	}
+	keys := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, key := range strings.Split(channel.Key, "\n") {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// GetFirstKey 返回渠道配置的第一个密钥，不区分密钥是否启用
+func (channel *Channel) GetFirstKey() string {
+	keys := channel.GetKeys()
+	if len(keys) == 0 {
+		return ""
+	}
+	return keys[0]
+}
+
+// SelectKey 选择本次请求使用的密钥及其 id（单密钥渠道 id 为 0），多密钥渠道没有可用密钥时退回到第一个密钥并返回错误
+func (channel *Channel) SelectKey() (string, int, error) {
+	if !channel.IsMultiKey() {
+		return channel.Key, 0, nil
+	}
+	channelKey, err := SelectChannelKey(channel)
+	if err != nil {
+		return channel.GetFirstKey(), 0, err
+	}
+	return channelKey.Key, channelKey.Id, nil
+}
+
+// GetPinnedKey 不经过密钥选择的场景（如文件、微调接口）使用的密钥，返回密钥及其 id（单密钥渠道 id 为 0）。
+// keyId 为 0 时取第一个启用的密钥，否则使用之前固定的密钥，上游的文件与任务只在创建它们的密钥下可见
+func (channel *Channel) GetPinnedKey(keyId int) (string, int) {
+	if !channel.IsMultiKey() {
+		return channel.Key, 0
+	}
+	keys, err := GetChannelKeys(channel.Id)
+	if err != nil {
+		logging.SysError(fmt.Sprintf("failed to get keys of channel #%d: %s", channel.Id, err.Error()))
+		return channel.GetFirstKey(), 0
+	}
+	if keyId != 0 {
+		for _, key := range keys {
+			if key.Id == keyId {
+				return key.Key, key.Id
+			}
+		}
+	}
+	for _, key := range keys {
+		if key.Status == common.ChannelStatusEnabled {
+			return key.Key, key.Id
+		}
+	}
+	return channel.GetFirstKey(), 0
+}
+
+// SyncChannelKeys 根据渠道的 Key 同步密钥表，保留未变化密钥的状态与用量
+func SyncChannelKeys(channel *Channel) error {
+	if !channel.IsMultiKey() {
+		err := DB.Where("channel_id = ?", channel.Id).Delete(&ChannelKey{}).Error
+		if err == nil {
+			refreshChannelKeyCache(channel.Id)
+		}
+		return err
+	}
+	var existing []*ChannelKey
+	if err := DB.Where("channel_id = ?", channel.Id).Find(&existing).Error; err != nil {
+		return err
+	}
+	existingKeys := make(map[string]*ChannelKey)
+	for _, channelKey := range existing {
+		existingKeys[channelKey.Key] = channelKey
+	}
+	keys := channel.GetKeys()
+	keep := make(map[string]bool)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, key := range keys {
+			keep[key] = true
+			if _, ok := existingKeys[key]; ok {
+				continue
+			}
+			if err := tx.Create(&ChannelKey{
+				ChannelId: channel.Id,
+				Key:       key,
+				Status:    common.ChannelStatusEnabled,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		for key, channelKey := range existingKeys {
+			if keep[key] {
+				continue
+			}
+			if err := tx.Delete(channelKey).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	refreshChannelKeyCache(channel.Id)
+	return nil
+}
+
+func DeleteChannelKeys(channelIds []int) error {
+	err := DB.Where("channel_id in (?)", channelIds).Delete(&ChannelKey{}).Error
+	for _, channelId := range channelIds {
+		refreshChannelKeyCache(channelId)
+	}
+	return err
+}
+
+func GetChannelKeys(channelId int) ([]*ChannelKey, error) {
+	var keys []*ChannelKey
+	err := DB.Where("channel_id = ?", channelId).Order("id").Find(&keys).Error
+	return keys, err
+}
+
+func initChannelKeyCache() {
+	var keys []*ChannelKey
+	DB.Order("id").Find(&keys)
+	newChannelKeyCache := make(map[int][]*ChannelKey)
+	for _, key := range keys {
+		newChannelKeyCache[key.ChannelId] = append(newChannelKeyCache[key.ChannelId], key)
+	}
+	channelKeyCacheLock.Lock()
+	channelKeyCache = newChannelKeyCache
+	channelKeyCacheLock.Unlock()
+	channelKeySelectMux.Lock()
+	channelKeyUseCount = make(map[int]int64)
+	channelKeySelectMux.Unlock()
+}
+
+func resetChannelKeyUseCount(keys []*ChannelKey) {
+	channelKeySelectMux.Lock()
+	for _, key := range keys {
+		delete(channelKeyUseCount, key.Id)
+	}
+	channelKeySelectMux.Unlock()
+}
+
+func refreshChannelKeyCache(channelId int) {
+	if !common.MemoryCacheEnabled {
+		return
+	}
+	keys, err := GetChannelKeys(channelId)
+	if err != nil {
+		logging.SysError("failed to refresh channel key cache: " + err.Error())
+		return
+	}
+	channelKeyCacheLock.Lock()
+	if len(keys) == 0 {
+		delete(channelKeyCache, channelId)
+	} else {
+		channelKeyCache[channelId] = keys
+	}
+	channelKeyCacheLock.Unlock()
+	resetChannelKeyUseCount(keys)
+}
+
+func getEnabledChannelKeys(channelId int) ([]*ChannelKey, error) {
+	var keys []*ChannelKey
+	if common.MemoryCacheEnabled {
+		channelKeyCacheLock.RLock()
+		keys = channelKeyCache[channelId]
+		channelKeyCacheLock.RUnlock()
+	} else {
+		var err error
+		keys, err = GetChannelKeys(channelId)
+		if err != nil {
+			return nil, err
+		}
+		resetChannelKeyUseCount(keys)
+	}
+	enabled := make([]*ChannelKey, 0, len(keys))
+	for _, key := range keys {
+		if key.Status == common.ChannelStatusEnabled {
+			enabled = append(enabled, key)
+		}
+	}
+	return enabled, nil
+}
+
+// SelectChannelKey 按渠道设置的方式选择一个启用的密钥
+func SelectChannelKey(channel *Channel) (*ChannelKey, error) {
+	keys, err := getEnabledChannelKeys(channel.Id)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no enabled key in channel")
+	}
+	channelKeySelectMux.Lock()
+	var selected *ChannelKey
+	switch channel.MultiKeyMode {
+	case ChannelMultiKeyModeRandom:
+		selected = keys[rand.Intn(len(keys))]
+	case ChannelMultiKeyModeLeastUsed:
+		selected = keys[0]
+		for _, key := range keys[1:] {
+			if int64(key.RequestCount)+channelKeyUseCount[key.Id] < int64(selected.RequestCount)+channelKeyUseCount[selected.Id] {
+				selected = key
+			}
+		}
+	default:
+		cursor := channelKeyCursor[channel.Id] % len(keys)
+		channelKeyCursor[channel.Id] = cursor + 1
+		selected = keys[cursor]
+	}
+	channelKeyUseCount[selected.Id]++
+	channelKeySelectMux.Unlock()
+
+	if common.BatchUpdateEnabled {
+		addNewRecord(BatchUpdateTypeChannelKeyRequestCount, selected.Id, 1)
+	} else {
+		updateChannelKeyRequestCount(selected.Id, 1)
+	}
+	return selected, nil
+}
+
+// UpdateChannelKeyStatus 启用或禁用单个密钥，返回渠道剩余的启用密钥数量
+func UpdateChannelKeyStatus(channelId int, keyId int, status int, reason string) (int, error) {
+	updates := map[string]interface{}{
+		"status":          status,
+		"disabled_reason": reason,
+		"disabled_time":   int64(0),
+	}
+	if status != common.ChannelStatusEnabled {
+		updates["disabled_time"] = common.GetTimestamp()
+	}
+	err := DB.Model(&ChannelKey{}).Where("id = ? and channel_id = ?", keyId, channelId).Updates(updates).Error
+	if err != nil {
+		return 0, err
+	}
+	refreshChannelKeyCache(channelId)
+	var count int64
+	err = DB.Model(&ChannelKey{}).Where("channel_id = ? and status = ?", channelId, common.ChannelStatusEnabled).Count(&count).Error
+	return int(count), err
+}
+
+// EnableAutoDisabledChannelKeys 重新启用渠道中被自动禁用的密钥，返回渠道的密钥总数和启用的密钥数量，单密钥渠道均为 0
+func EnableAutoDisabledChannelKeys(channelId int) (int, int, error) {
+	err := DB.Model(&ChannelKey{}).Where("channel_id = ? and status = ?", channelId, common.ChannelStatusAutoDisabled).Updates(map[string]interface{}{
+		"status":          common.ChannelStatusEnabled,
+		"disabled_reason": "",
+		"disabled_time":   int64(0),
+	}).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	refreshChannelKeyCache(channelId)
+	keys, err := GetChannelKeys(channelId)
+	if err != nil {
+		return 0, 0, err
+	}
+	enabled := 0
+	for _, key := range keys {
+		if key.Status == common.ChannelStatusEnabled {
+			enabled++
+		}
+	}
+	return len(keys), enabled, nil
+}
+
+func UpdateChannelKeyUsedQuota(keyId int, quota int) {
+	if keyId == 0 {
+		return
+	}
+	if common.BatchUpdateEnabled {
+		addNewRecord(BatchUpdateTypeChannelKeyUsedQuota, keyId, quota)
+		return
+	}
+	updateChannelKeyUsedQuota(keyId, quota)
+}
+
+func updateChannelKeyUsedQuota(id int, quota int) {
+	err := DB.Model(&ChannelKey{}).Where("id = ?", id).Update("used_quota", gorm.Expr("used_quota + ?", quota)).Error
+	if err != nil {
+		logging.SysError("failed to update channel key used quota: " + err.Error())
+	}
+}
+
+func updateChannelKeyRequestCount(id int, count int) {
+	err := DB.Model(&ChannelKey{}).Where("id = ?", id).Update("request_count", gorm.Expr("request_count + ?", count)).Error
+	if err != nil {
+		logging.SysError("failed to update channel key request count: " + err.Error())
+	}
+}
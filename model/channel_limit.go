@@ -0,0 +1,260 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/logging"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrChannelsSaturated 存在满足条件的渠道，但都已达到上游限制
+var ErrChannelsSaturated = errors.New("all channels are saturated")
+
+const (
+	channelLimitKeyPrefix = "channel_limit"
+	// 并发计数在异常退出时无法释放，超过该时间没有新请求后自动清零
+	channelConcurrencyExpiration = 10 * time.Minute
+)
+
+// ChannelLimit 渠道或渠道下单个模型的上游限制，配置在渠道的 Setting 中，0 表示不限制，例如
+// {"rpm": 500, "model_limits": {"gpt-4o": {"max_concurrency": 10, "tpm": 30000}}}
+// 开启内存缓存时限制随渠道缓存加载，否则每次从数据库读取渠道的 Setting
+type ChannelLimit struct {
+	MaxConcurrency int `json:"max_concurrency"`
+	RPM            int `json:"rpm"`
+	TPM            int `json:"tpm"`
+}
+
+func (limit ChannelLimit) isEmpty() bool {
+	return limit.MaxConcurrency <= 0 && limit.RPM <= 0 && limit.TPM <= 0
+}
+
+type channelLimits struct {
+	channel ChannelLimit
+	models  map[string]ChannelLimit
+}
+
+var (
+	channelLimitsM    = make(map[int]*channelLimits)
+	channelLimitsLock sync.RWMutex
+)
+
+// GetLimits 解析渠道 Setting 中的上游限制
+func (channel *Channel) GetLimits() (ChannelLimit, map[string]ChannelLimit) {
+	var setting struct {
+		ChannelLimit
+		ModelLimits map[string]ChannelLimit `json:"model_limits"`
+	}
+	if channel.Setting != "" {
+		if err := json.Unmarshal([]byte(channel.Setting), &setting); err != nil {
+			logging.SysError(fmt.Sprintf("failed to unmarshal limits of channel #%d: %s", channel.Id, err.Error()))
+		}
+	}
+	return setting.ChannelLimit, setting.ModelLimits
+}
+
+// parseChannelLimits 没有配置任何限制时返回 nil
+func parseChannelLimits(channel *Channel) *channelLimits {
+	limit, modelLimits := channel.GetLimits()
+	for name, modelLimit := range modelLimits {
+		if modelLimit.isEmpty() {
+			delete(modelLimits, name)
+		}
+	}
+	if limit.isEmpty() && len(modelLimits) == 0 {
+		return nil
+	}
+	return &channelLimits{channel: limit, models: modelLimits}
+}
+
+func initChannelLimits(channels []*Channel) {
+	newChannelLimitsM := make(map[int]*channelLimits)
+	for _, channel := range channels {
+		if limits := parseChannelLimits(channel); limits != nil {
+			newChannelLimitsM[channel.Id] = limits
+		}
+	}
+	channelLimitsLock.Lock()
+	channelLimitsM = newChannelLimitsM
+	channelLimitsLock.Unlock()
+}
+
+// loadChannelLimits 未开启内存缓存时从数据库读取渠道的限制
+func loadChannelLimits(channelIds []int) map[int]*channelLimits {
+	limitsM := make(map[int]*channelLimits)
+	if len(channelIds) == 0 {
+		return limitsM
+	}
+	var channels []*Channel
+	if err := DB.Select("id", "setting").Where("id in (?)", channelIds).Find(&channels).Error; err != nil {
+		logging.SysError("failed to load channel limits: " + err.Error())
+		return limitsM
+	}
+	for _, channel := range channels {
+		if limits := parseChannelLimits(channel); limits != nil {
+			limitsM[channel.Id] = limits
+		}
+	}
+	return limitsM
+}
+
+func getChannelLimits(channelId int) *channelLimits {
+	if !common.MemoryCacheEnabled {
+		return loadChannelLimits([]int{channelId})[channelId]
+	}
+	channelLimitsLock.RLock()
+	defer channelLimitsLock.RUnlock()
+	return channelLimitsM[channelId]
+}
+
+func channelLimitScope(channelId int, modelName string) string {
+	if modelName == "" {
+		return strconv.Itoa(channelId)
+	}
+	return fmt.Sprintf("%d:%s", channelId, modelName)
+}
+
+func channelConcurrencyKey(scope string) string {
+	return fmt.Sprintf("%s:concurrency:%s", channelLimitKeyPrefix, scope)
+}
+
+func channelMinuteKey(kind string, scope string, now time.Time) string {
+	return fmt.Sprintf("%s:%s:%s:%d", channelLimitKeyPrefix, kind, scope, now.Unix()/60)
+}
+
+func channelLimitSaturated(limit ChannelLimit, scope string, now time.Time) bool {
+	if limit.MaxConcurrency > 0 && common.GetLimitCounter(channelConcurrencyKey(scope)) >= int64(limit.MaxConcurrency) {
+		return true
+	}
+	if limit.RPM > 0 && common.GetLimitCounter(channelMinuteKey("rpm", scope, now)) >= int64(limit.RPM) {
+		return true
+	}
+	if limit.TPM > 0 && common.GetLimitCounter(channelMinuteKey("tpm", scope, now)) >= int64(limit.TPM) {
+		return true
+	}
+	return false
+}
+
+// IsChannelSaturated 判断渠道或渠道下的模型是否已达到上游限制
+func IsChannelSaturated(channelId int, modelName string) bool {
+	return channelLimitsSaturated(getChannelLimits(channelId), channelId, modelName)
+}
+
+func channelLimitsSaturated(limits *channelLimits, channelId int, modelName string) bool {
+	if limits == nil {
+		return false
+	}
+	now := time.Now()
+	if !limits.channel.isEmpty() && channelLimitSaturated(limits.channel, channelLimitScope(channelId, ""), now) {
+		return true
+	}
+	if modelLimit, ok := limits.models[modelName]; ok && channelLimitSaturated(modelLimit, channelLimitScope(channelId, modelName), now) {
+		return true
+	}
+	return false
+}
+
+// filterChannelsByLimit 过滤掉已达到上游限制的渠道
+func filterChannelsByLimit(channels []*Channel, modelName string) []*Channel {
+	available := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if !IsChannelSaturated(channel.Id, modelName) {
+			available = append(available, channel)
+		}
+	}
+	return available
+}
+
+// filterAbilitiesByLimit 未开启内存缓存时过滤掉已达到上游限制的渠道，一次读取所有候选渠道的限制
+func filterAbilitiesByLimit(abilities []Ability, modelName string) []Ability {
+	channelIds := make([]int, len(abilities))
+	for i, ability := range abilities {
+		channelIds[i] = ability.ChannelId
+	}
+	limitsM := loadChannelLimits(channelIds)
+	if len(limitsM) == 0 {
+		return abilities
+	}
+	available := make([]Ability, 0, len(abilities))
+	for _, ability := range abilities {
+		if !channelLimitsSaturated(limitsM[ability.ChannelId], ability.ChannelId, modelName) {
+			available = append(available, ability)
+		}
+	}
+	return available
+}
+
+// channelLimitScopes 返回请求需要计数的范围：配置了限制的渠道与渠道下的模型
+func channelLimitScopes(limits *channelLimits, channelId int, modelName string) []string {
+	if limits == nil {
+		return nil
+	}
+	var scopes []string
+	if !limits.channel.isEmpty() {
+		scopes = append(scopes, channelLimitScope(channelId, ""))
+	}
+	if _, ok := limits.models[modelName]; ok {
+		scopes = append(scopes, channelLimitScope(channelId, modelName))
+	}
+	return scopes
+}
+
+// AcquireChannelLimit 请求开始时先增加并发与每分钟请求数再与限制比较，超过限制时回滚计数并返回 false，
+// 渠道选择时的饱和过滤只是提示，并发请求同时选中同一渠道时以这里的计数为准。
+// 返回的函数在请求结束时释放并发，countRequest 为 false 时（如命中响应缓存）同时撤销本次请求的每分钟请求数
+func AcquireChannelLimit(channelId int, modelName string) (func(countRequest bool), bool) {
+	limits := getChannelLimits(channelId)
+	scopes := channelLimitScopes(limits, channelId, modelName)
+	if len(scopes) == 0 {
+		return func(bool) {}, true
+	}
+	scopeLimits := make([]ChannelLimit, len(scopes))
+	for i, scope := range scopes {
+		if scope == channelLimitScope(channelId, "") {
+			scopeLimits[i] = limits.channel
+		} else {
+			scopeLimits[i] = limits.models[modelName]
+		}
+	}
+	now := time.Now()
+	rollback := func(counted []string, countRequest bool) {
+		for _, scope := range counted {
+			common.IncrLimitCounter(channelConcurrencyKey(scope), -1, channelConcurrencyExpiration)
+			if !countRequest {
+				common.IncrLimitCounter(channelMinuteKey("rpm", scope, now), -1, 2*time.Minute)
+			}
+		}
+	}
+	for i, scope := range scopes {
+		limit := scopeLimits[i]
+		// token 数在请求结束后才知道，只能检查已经累计的值
+		if limit.TPM > 0 && common.GetLimitCounter(channelMinuteKey("tpm", scope, now)) >= int64(limit.TPM) {
+			rollback(scopes[:i], false)
+			return nil, false
+		}
+		concurrency := common.IncrLimitCounter(channelConcurrencyKey(scope), 1, channelConcurrencyExpiration)
+		rpm := common.IncrLimitCounter(channelMinuteKey("rpm", scope, now), 1, 2*time.Minute)
+		if (limit.MaxConcurrency > 0 && concurrency > int64(limit.MaxConcurrency)) || (limit.RPM > 0 && rpm > int64(limit.RPM)) {
+			rollback(scopes[:i+1], false)
+			return nil, false
+		}
+	}
+	return func(countRequest bool) {
+		rollback(scopes, countRequest)
+	}, true
+}
+
+// RecordChannelTokens 请求结束后累计每分钟 token 数
+func RecordChannelTokens(channelId int, modelName string, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	now := time.Now()
+	for _, scope := range channelLimitScopes(getChannelLimits(channelId), channelId, modelName) {
+		common.IncrLimitCounter(channelMinuteKey("tpm", scope, now), int64(tokens), 2*time.Minute)
+	}
+}
@@ -0,0 +1,187 @@
+package model
+
+import (
+	"one-api/common"
+	"sort"
+	"sync"
+)
+
+// 模式匹配结果缓存的最大数量，客户端可以请求任意的模型名称，超过后清空
+const channelPatternMatchCacheSize = 10000
+
+// channelModelPattern 分组内使用模式声明模型的渠道
+type channelModelPattern struct {
+	pattern  string
+	channels []*Channel
+}
+
+var (
+	// 与 group2model2channels 一起在 channelSyncLock 下更新
+	group2modelPatterns map[string][]*channelModelPattern
+
+	channelPatternMatchCache = make(map[string][]*Channel)
+	channelPatternMatchLock  sync.Mutex
+)
+
+// buildGroupModelPatterns 从渠道缓存中找出以模式声明的模型
+func buildGroupModelPatterns(group2model2channels map[string]map[string][]*Channel) map[string][]*channelModelPattern {
+	newGroup2modelPatterns := make(map[string][]*channelModelPattern)
+	for group, model2channels := range group2model2channels {
+		for name, channels := range model2channels {
+			if !common.IsModelPattern(name) {
+				continue
+			}
+			newGroup2modelPatterns[group] = append(newGroup2modelPatterns[group], &channelModelPattern{
+				pattern:  name,
+				channels: channels,
+			})
+		}
+	}
+	return newGroup2modelPatterns
+}
+
+func resetChannelPatternMatchCache() {
+	channelPatternMatchLock.Lock()
+	channelPatternMatchCache = make(map[string][]*Channel)
+	channelPatternMatchLock.Unlock()
+}
+
+// getGroupModelChannels 返回分组内可以处理该模型的渠道（精确匹配与模式匹配），按优先级降序排列，调用方需持有 channelSyncLock
+func getGroupModelChannels(group string, modelName string) []*Channel {
+	channels := group2model2channels[group][modelName]
+	patterns := group2modelPatterns[group]
+	if len(patterns) == 0 || common.IsModelPattern(modelName) {
+		return channels
+	}
+	key := group + "\x00" + modelName
+	channelPatternMatchLock.Lock()
+	matched, ok := channelPatternMatchCache[key]
+	channelPatternMatchLock.Unlock()
+	if ok {
+		return matched
+	}
+	matched = append([]*Channel{}, channels...)
+	seen := make(map[int]bool, len(channels))
+	for _, channel := range channels {
+		seen[channel.Id] = true
+	}
+	for _, pattern := range patterns {
+		if !common.MatchModelPattern(pattern.pattern, modelName) {
+			continue
+		}
+		for _, channel := range pattern.channels {
+			if !seen[channel.Id] {
+				seen[channel.Id] = true
+				matched = append(matched, channel)
+			}
+		}
+	}
+	if len(matched) > len(channels) {
+		sort.SliceStable(matched, func(i, j int) bool {
+			return matched[i].GetPriority() > matched[j].GetPriority()
+		})
+	}
+	channelPatternMatchLock.Lock()
+	if len(channelPatternMatchCache) >= channelPatternMatchCacheSize {
+		channelPatternMatchCache = make(map[string][]*Channel)
+	}
+	channelPatternMatchCache[key] = matched
+	channelPatternMatchLock.Unlock()
+	return matched
+}
+
+// getPatternAbilities 未开启内存缓存时，如果分组内有以模式声明且匹配该模型的能力，
+// 返回与精确匹配的能力合并后、retry 对应优先级的能力；没有匹配的模式时 ok 为 false，按原有方式查询
+func getPatternAbilities(group string, modelName string, retry int) (abilities []Ability, ok bool, err error) {
+	if common.IsModelPattern(modelName) {
+		return nil, false, nil
+	}
+	var patternAbilities []Ability
+	err = DB.Where(groupCol+" = ? and enabled = ? and (model like ? or model like ?)", group, true, "%*%", common.ModelPatternRegexPrefix+"%").
+		Find(&patternAbilities).Error
+	if err != nil {
+		return nil, false, err
+	}
+	var matched []Ability
+	for _, ability := range patternAbilities {
+		if common.MatchModelPattern(ability.Model, modelName) {
+			matched = append(matched, ability)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, false, nil
+	}
+	var exact []Ability
+	err = DB.Where(groupCol+" = ? and model = ? and enabled = ?", group, modelName, true).Find(&exact).Error
+	if err != nil {
+		return nil, false, err
+	}
+	merged := make([]Ability, 0, len(exact)+len(matched))
+	seen := make(map[int]bool)
+	for _, ability := range append(exact, matched...) {
+		if !seen[ability.ChannelId] {
+			seen[ability.ChannelId] = true
+			merged = append(merged, ability)
+		}
+	}
+	uniquePriorities := make(map[int64]bool)
+	for _, ability := range merged {
+		uniquePriorities[abilityPriority(ability)] = true
+	}
+	priorities := make([]int64, 0, len(uniquePriorities))
+	for priority := range uniquePriorities {
+		priorities = append(priorities, priority)
+	}
+	sort.Slice(priorities, func(i, j int) bool {
+		return priorities[i] > priorities[j]
+	})
+	if retry >= len(priorities) {
+		retry = len(priorities) - 1
+	}
+	for _, ability := range merged {
+		if abilityPriority(ability) == priorities[retry] {
+			abilities = append(abilities, ability)
+		}
+	}
+	sort.SliceStable(abilities, func(i, j int) bool {
+		return abilities[i].Weight > abilities[j].Weight
+	})
+	return abilities, true, nil
+}
+
+func abilityPriority(ability Ability) int64 {
+	if ability.Priority == nil {
+		return 0
+	}
+	return *ability.Priority
+}
+
+// expandPatternModelGroups 将定价中以模式声明的模型展开为匹配的已知模型
+func expandPatternModelGroups(modelGroupsMap map[string][]string) {
+	models := make([]string, 0, len(modelGroupsMap))
+	for name := range modelGroupsMap {
+		models = append(models, name)
+	}
+	for name, groups := range modelGroupsMap {
+		if !common.IsModelPattern(name) {
+			continue
+		}
+		kept := false
+		for _, expanded := range common.ExpandModelPatterns(append(models, name)) {
+			if expanded == name {
+				kept = true
+			}
+			if expanded == name || !common.MatchModelPattern(name, expanded) {
+				continue
+			}
+			for _, group := range groups {
+				if !common.StringsContains(modelGroupsMap[expanded], group) {
+					modelGroupsMap[expanded] = append(modelGroupsMap[expanded], group)
+				}
+			}
+		}
+		if !kept {
+			delete(modelGroupsMap, name)
+		}
+	}
+}
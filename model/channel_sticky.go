@@ -0,0 +1,49 @@
+package model
+
+import (
+	"hash/fnv"
+	"math"
+	"strconv"
+)
+
+// stickyChannelIndex 使用加权的 rendezvous 哈希为 key 选择渠道，
+// 同一 key 总是落在同一个渠道上，渠道增减时只有落在该渠道上的 key 会改变
+func stickyChannelIndex(key string, channelIds []int, weights []int) int {
+	best := -1
+	bestScore := 0.0
+	for i, channelId := range channelIds {
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(key))
+		_, _ = hash.Write([]byte{':'})
+		_, _ = hash.Write([]byte(strconv.Itoa(channelId)))
+		// 映射到 (0, 1) 区间
+		u := (float64(hash.Sum64()>>11) + 0.5) / float64(uint64(1)<<53)
+		score := float64(weights[i]) / -math.Log(u)
+		if best < 0 || score > bestScore {
+			best = i
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// selectStickyChannel 在同一优先级的全部渠道中为 key 选择粘性渠道，
+// 该渠道被熔断或已达到上游限制（不在 available 中）时返回 nil，由调用方按常规方式选择
+func selectStickyChannel(key string, tier []*Channel, available []*Channel, smoothingFactor int) *Channel {
+	if len(tier) == 0 {
+		return nil
+	}
+	channelIds := make([]int, len(tier))
+	weights := make([]int, len(tier))
+	for i, channel := range tier {
+		channelIds[i] = channel.Id
+		weights[i] = channel.GetWeight() + smoothingFactor
+	}
+	target := tier[stickyChannelIndex(key, channelIds, weights)]
+	for _, channel := range available {
+		if channel.Id == target.Id {
+			return channel
+		}
+	}
+	return nil
+}
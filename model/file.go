@@ -0,0 +1,89 @@
+package model
+
+import (
+	"one-api/common"
+	"one-api/dto"
+)
+
+const (
+	FileStatusUploaded  = "uploaded"
+	FileStatusProcessed = "processed"
+	FileStatusError     = "error"
+)
+
+type File struct {
+	Id             int    `json:"-"`
+	FileId         string `json:"id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId         int    `json:"user_id" gorm:"index"`
+	TokenId        int    `json:"token_id" gorm:"index"`
+	Filename       string `json:"filename"`
+	Purpose        string `json:"purpose" gorm:"type:varchar(32);index"`
+	Bytes          int64  `json:"bytes" gorm:"bigint"`
+	Status         string `json:"status" gorm:"type:varchar(20)"`
+	Storage        string `json:"-" gorm:"type:varchar(20)"`      // 存储后端，如 local
+	StoragePath    string `json:"-"`                              // 存储后端内的路径
+	ChannelId      int    `json:"-" gorm:"index"`                 // 已透传到的上游渠道
+	KeyId          int    `json:"-"`                              // 多密钥渠道中上传所用的密钥
+	UpstreamFileId string `json:"-" gorm:"type:varchar(128)"`     // 上游渠道返回的文件id
+	CreatedAt      int64  `json:"created_at" gorm:"bigint;index"` // unix seconds
+}
+
+func (file *File) ToOpenAIFile() dto.OpenAIFile {
+	return dto.OpenAIFile{
+		Id:        file.FileId,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+		Status:    file.Status,
+	}
+}
+
+func (file *File) Insert() error {
+	if file.CreatedAt == 0 {
+		file.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(file).Error
+}
+
+func (file *File) Update() error {
+	return DB.Save(file).Error
+}
+
+func (file *File) Delete() error {
+	return DB.Delete(file).Error
+}
+
+func GetUserFileByFileId(userId int, fileId string) (*File, bool, error) {
+	var file *File
+	err := DB.Where("user_id = ? and file_id = ?", userId, fileId).First(&file).Error
+	exist, err := RecordExist(err)
+	if err != nil {
+		return nil, false, err
+	}
+	return file, exist, nil
+}
+
+// GetUserFiles 按 id 倒序分页，after 为上一页最后一个文件的 file_id
+func GetUserFiles(userId int, purpose string, after string, limit int) ([]*File, error) {
+	var files []*File
+	query := DB.Where("user_id = ?", userId)
+	if purpose != "" {
+		query = query.Where("purpose = ?", purpose)
+	}
+	if after != "" {
+		var afterFile File
+		if err := DB.Select("id").Where("user_id = ? and file_id = ?", userId, after).First(&afterFile).Error; err == nil {
+			query = query.Where("id < ?", afterFile.Id)
+		}
+	}
+	err := query.Order("id desc").Limit(limit).Find(&files).Error
+	return files, err
+}
+
+func SumUserFileBytes(userId int) (int64, error) {
+	var total int64
+	err := DB.Model(&File{}).Where("user_id = ?", userId).Select("COALESCE(SUM(bytes), 0)").Scan(&total).Error
+	return total, err
+}
@@ -216,6 +216,18 @@ func migrateDB() error {
 	if err != nil {
 		return err
 	}
+	err = DB.AutoMigrate(&File{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&Batch{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&ChannelKey{})
+	if err != nil {
+		return err
+	}
 	common.SysLog("database migrated")
 	err = createRootAccountIfNeed()
 	return err
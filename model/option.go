@@ -89,8 +89,14 @@ func InitOptionMap() {
 	common.OptionMap["ModelRatio"] = common.ModelRatio2JSONString()
 	common.OptionMap["ModelPrice"] = common.ModelPrice2JSONString()
 	common.OptionMap["GroupRatio"] = setting.GroupRatio2JSONString()
+	common.OptionMap["ModelFallback"] = setting.ModelFallback2JSONString()
+	common.OptionMap["GroupModelAlias"] = setting.GroupModelAlias2JSONString()
+	common.OptionMap["GroupChannelSelect"] = setting.GroupChannelSelect2JSONString()
+	common.OptionMap["GroupRelayRateLimit"] = setting.GroupRelayRateLimit2JSONString()
 	common.OptionMap["UserUsableGroups"] = setting.UserUsableGroups2JSONString()
 	common.OptionMap["CompletionRatio"] = common.CompletionRatio2JSONString()
+	common.OptionMap["CacheRatio"] = common.CacheRatio2JSONString()
+	common.OptionMap["CreateCacheRatio"] = common.CreateCacheRatio2JSONString()
 	common.OptionMap["TopUpLink"] = common.TopUpLink
 	common.OptionMap["ChatLink"] = common.ChatLink
 	common.OptionMap["ChatLink2"] = common.ChatLink2
@@ -110,6 +116,25 @@ func InitOptionMap() {
 	common.OptionMap["StopOnSensitiveEnabled"] = strconv.FormatBool(setting.StopOnSensitiveEnabled)
 	common.OptionMap["SensitiveWords"] = setting.SensitiveWordsToString()
 	common.OptionMap["StreamCacheQueueLength"] = strconv.Itoa(setting.StreamCacheQueueLength)
+	common.OptionMap["BatchDiscountRatio"] = strconv.FormatFloat(setting.BatchDiscountRatio, 'f', -1, 64)
+	common.OptionMap["ResponseCacheEnabled"] = strconv.FormatBool(setting.ResponseCacheEnabled)
+	common.OptionMap["ResponseCacheTTL"] = strconv.Itoa(setting.ResponseCacheTTL)
+	common.OptionMap["ResponseCacheRatio"] = strconv.FormatFloat(setting.ResponseCacheRatio, 'f', -1, 64)
+	common.OptionMap["CircuitBreakerEnabled"] = strconv.FormatBool(setting.CircuitBreakerEnabled)
+	common.OptionMap["CircuitBreakerFailureThreshold"] = strconv.Itoa(setting.CircuitBreakerFailureThreshold)
+	common.OptionMap["CircuitBreakerWindow"] = strconv.Itoa(setting.CircuitBreakerWindow)
+	common.OptionMap["CircuitBreakerOpenDuration"] = strconv.Itoa(setting.CircuitBreakerOpenDuration)
+	common.OptionMap["CircuitBreakerProbeInterval"] = strconv.Itoa(setting.CircuitBreakerProbeInterval)
+	common.OptionMap["RelayQueueEnabled"] = strconv.FormatBool(setting.RelayQueueEnabled)
+	common.OptionMap["StreamHoldFirstChunkEnabled"] = strconv.FormatBool(setting.StreamHoldFirstChunkEnabled)
+	common.OptionMap["StructuredOutputValidationEnabled"] = strconv.FormatBool(setting.StructuredOutputValidationEnabled)
+	common.OptionMap["RelayQueueMaxSize"] = strconv.Itoa(setting.RelayQueueMaxSize)
+	common.OptionMap["RelayQueueMaxWait"] = strconv.Itoa(setting.RelayQueueMaxWait)
+	common.OptionMap["GroupQueuePriority"] = setting.GroupQueuePriority2JSONString()
+	common.OptionMap["RelayConnectTimeout"] = strconv.Itoa(setting.RelayConnectTimeout)
+	common.OptionMap["RelayFirstByteTimeout"] = strconv.Itoa(setting.RelayFirstByteTimeout)
+	common.OptionMap["RelayStreamIdleTimeout"] = strconv.Itoa(setting.RelayStreamIdleTimeout)
+	common.OptionMap["StreamKeepaliveInterval"] = strconv.Itoa(setting.StreamKeepaliveInterval)
 
 	common.OptionMapRWMutex.Unlock()
 	loadOptionsFromDatabase()
@@ -229,6 +254,16 @@ func updateOptionMap(key string, value string) (err error) {
 			setting.StopOnSensitiveEnabled = boolValue
 		case "SMTPSSLEnabled":
 			common.SMTPSSLEnabled = boolValue
+		case "ResponseCacheEnabled":
+			setting.ResponseCacheEnabled = boolValue
+		case "CircuitBreakerEnabled":
+			setting.CircuitBreakerEnabled = boolValue
+		case "RelayQueueEnabled":
+			setting.RelayQueueEnabled = boolValue
+		case "StreamHoldFirstChunkEnabled":
+			setting.StreamHoldFirstChunkEnabled = boolValue
+		case "StructuredOutputValidationEnabled":
+			setting.StructuredOutputValidationEnabled = boolValue
 		}
 	}
 	switch key {
@@ -263,6 +298,34 @@ func updateOptionMap(key string, value string) (err error) {
 		setting.EpayKey = value
 	case "Price":
 		setting.Price, _ = strconv.ParseFloat(value, 64)
+	case "BatchDiscountRatio":
+		setting.BatchDiscountRatio, _ = strconv.ParseFloat(value, 64)
+	case "ResponseCacheTTL":
+		setting.ResponseCacheTTL, _ = strconv.Atoi(value)
+	case "ResponseCacheRatio":
+		setting.ResponseCacheRatio, _ = strconv.ParseFloat(value, 64)
+	case "CircuitBreakerFailureThreshold":
+		setting.CircuitBreakerFailureThreshold, _ = strconv.Atoi(value)
+	case "CircuitBreakerWindow":
+		setting.CircuitBreakerWindow, _ = strconv.Atoi(value)
+	case "CircuitBreakerOpenDuration":
+		setting.CircuitBreakerOpenDuration, _ = strconv.Atoi(value)
+	case "CircuitBreakerProbeInterval":
+		setting.CircuitBreakerProbeInterval, _ = strconv.Atoi(value)
+	case "RelayQueueMaxSize":
+		setting.RelayQueueMaxSize, _ = strconv.Atoi(value)
+	case "RelayQueueMaxWait":
+		setting.RelayQueueMaxWait, _ = strconv.Atoi(value)
+	case "GroupQueuePriority":
+		err = setting.UpdateGroupQueuePriorityByJSONString(value)
+	case "RelayConnectTimeout":
+		setting.RelayConnectTimeout, _ = strconv.Atoi(value)
+	case "RelayFirstByteTimeout":
+		setting.RelayFirstByteTimeout, _ = strconv.Atoi(value)
+	case "RelayStreamIdleTimeout":
+		setting.RelayStreamIdleTimeout, _ = strconv.Atoi(value)
+	case "StreamKeepaliveInterval":
+		setting.StreamKeepaliveInterval, _ = strconv.Atoi(value)
 	case "MinTopUp":
 		setting.MinTopUp, _ = strconv.Atoi(value)
 	case "TopupGroupRatio":
@@ -315,10 +378,22 @@ func updateOptionMap(key string, value string) (err error) {
 		err = common.UpdateModelRatioByJSONString(value)
 	case "GroupRatio":
 		err = setting.UpdateGroupRatioByJSONString(value)
+	case "ModelFallback":
+		err = setting.UpdateModelFallbackByJSONString(value)
+	case "GroupModelAlias":
+		err = setting.UpdateGroupModelAliasByJSONString(value)
+	case "GroupChannelSelect":
+		err = setting.UpdateGroupChannelSelectByJSONString(value)
+	case "GroupRelayRateLimit":
+		err = setting.UpdateGroupRelayRateLimitByJSONString(value)
 	case "UserUsableGroups":
 		err = setting.UpdateUserUsableGroupsByJSONString(value)
 	case "CompletionRatio":
 		err = common.UpdateCompletionRatioByJSONString(value)
+	case "CacheRatio":
+		err = common.UpdateCacheRatioByJSONString(value)
+	case "CreateCacheRatio":
+		err = common.UpdateCreateCacheRatioByJSONString(value)
 	case "ModelPrice":
 		err = common.UpdateModelPriceByJSONString(value)
 	case "TopUpLink":
@@ -13,6 +13,7 @@ type Pricing struct {
 	ModelPrice      float64  `json:"model_price"`
 	OwnerBy         string   `json:"owner_by"`
 	CompletionRatio float64  `json:"completion_ratio"`
+	CacheRatio      float64  `json:"cache_ratio"`
 	EnableGroup     []string `json:"enable_groups,omitempty"`
 }
 
@@ -57,6 +58,7 @@ func updatePricing() {
 		}
 		modelGroupsMap[ability.Model] = groups
 	}
+	expandPatternModelGroups(modelGroupsMap)
 
 	pricingMap = make([]Pricing, 0)
 	for model, groups := range modelGroupsMap {
@@ -71,6 +73,7 @@ func updatePricing() {
 		} else {
 			pricing.ModelRatio = common.GetModelRatio(model)
 			pricing.CompletionRatio = common.GetCompletionRatio(model)
+			pricing.CacheRatio = common.GetCacheRatio(model)
 			pricing.QuotaType = 0
 		}
 		pricingMap = append(pricingMap, pricing)
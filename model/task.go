@@ -28,6 +28,7 @@ type Task struct {
 	Platform   constant.TaskPlatform `json:"platform" gorm:"type:varchar(30);index"` // 平台
 	UserId     int                   `json:"user_id" gorm:"index"`
 	ChannelId  int                   `json:"channel_id" gorm:"index"`
+	KeyId      int                   `json:"-"` // 多密钥渠道中创建任务所用的密钥，后续查询固定使用该密钥
 	Quota      int                   `json:"quota"`
 	Action     string                `json:"action" gorm:"type:varchar(40);index"` // 任务类型, song, lyrics, description-mode
 	Status     TaskStatus            `json:"status" gorm:"type:varchar(20);index"` // 任务状态
@@ -53,6 +54,10 @@ func (t *Task) GetData(v any) error {
 
 type Properties struct {
 	Input string `json:"input"`
+	// 以下字段用于任务完成后按用量计费
+	Model   string `json:"model,omitempty"`
+	TokenId int    `json:"token_id,omitempty"`
+	Group   string `json:"group,omitempty"`
 }
 
 func (m *Properties) Scan(val interface{}) error {
@@ -224,6 +229,29 @@ func GetByTaskIds(userId int, taskIds []any) ([]*Task, error) {
 	return task, nil
 }
 
+// GetUserTasksByPlatform 按 id 倒序分页，after 为上一页最后一个任务的 task_id
+func GetUserTasksByPlatform(userId int, platform constant.TaskPlatform, after string, limit int) ([]*Task, error) {
+	var tasks []*Task
+	query := DB.Where("user_id = ? and platform = ?", userId, platform)
+	if after != "" {
+		var afterTask Task
+		if err := DB.Select("id").Where("user_id = ? and task_id = ?", userId, after).First(&afterTask).Error; err == nil {
+			query = query.Where("id < ?", afterTask.ID)
+		}
+	}
+	err := query.Order("id desc").Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
+
+// TaskUpdateUnfinished 只更新未完成的任务，返回是否更新成功，避免已完成的任务被覆盖或重复结算
+func TaskUpdateUnfinished(id int64, params map[string]any) (bool, error) {
+	result := DB.Model(&Task{}).Where("id = ? and progress != ?", id, "100%").Updates(params)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
 func TaskUpdateProgress(id int64, progress string) error {
 	return DB.Model(&Task{}).Where("id = ?", id).Update("progress", progress).Error
 }
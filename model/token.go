@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/bytedance/gopkg/util/gopool"
@@ -26,6 +27,9 @@ type Token struct {
 	UnlimitedQuota     bool           `json:"unlimited_quota" gorm:"default:false"`
 	ModelLimitsEnabled bool           `json:"model_limits_enabled" gorm:"default:false"`
 	ModelLimits        string         `json:"model_limits" gorm:"type:varchar(1024);default:''"`
+	ModelFallback      string         `json:"model_fallback" gorm:"type:text"`
+	ModelAlias         string         `json:"model_alias" gorm:"type:text"`
+	RateLimit          string         `json:"rate_limit" gorm:"type:varchar(255);default:''"`
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
@@ -187,7 +191,7 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group").Updates(token).Error
+		"model_limits_enabled", "model_limits", "model_fallback", "model_alias", "rate_limit", "allow_ips", "group").Updates(token).Error
 	return err
 }
 
@@ -241,6 +245,35 @@ func (token *Token) GetModelLimitsMap() map[string]bool {
 	return limitsMap
 }
 
+// GetModelFallbackMap 令牌自定义的模型降级链，格式与系统设置 ModelFallback 相同
+func (token *Token) GetModelFallbackMap() map[string][]string {
+	fallbackMap := make(map[string][]string)
+	if token.ModelFallback == "" {
+		return fallbackMap
+	}
+	if err := json.Unmarshal([]byte(token.ModelFallback), &fallbackMap); err != nil {
+		logging.SysError("failed to unmarshal token model fallback: " + err.Error())
+	}
+	return fallbackMap
+}
+
+// GetModelAliasMap 令牌自定义的模型别名，格式为 {"别名": "实际模型"}
+func (token *Token) GetModelAliasMap() map[string]string {
+	aliasMap := make(map[string]string)
+	if token.ModelAlias == "" {
+		return aliasMap
+	}
+	if err := json.Unmarshal([]byte(token.ModelAlias), &aliasMap); err != nil {
+		logging.SysError("failed to unmarshal token model alias: " + err.Error())
+	}
+	return aliasMap
+}
+
+// GetRateLimit 令牌自身的请求限制，与用户的限制同时生效
+func (token *Token) GetRateLimit() setting.RelayRateLimit {
+	return setting.ParseRelayRateLimit(token.RateLimit)
+}
+
 func DisableModelLimits(tokenId int) error {
 	token, err := GetTokenById(tokenId)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"one-api/common"
 	"one-api/logging"
+	"one-api/setting"
 	"strconv"
 	"strings"
 
@@ -39,6 +40,7 @@ type User struct {
 	InviterId        int            `json:"inviter_id" gorm:"type:int;column:inviter_id;index"`
 	DeletedAt        gorm.DeletedAt `gorm:"index"`
 	LinuxDOId        string         `json:"linux_do_id" gorm:"column:linux_do_id;index"`
+	RateLimit        string         `json:"rate_limit" gorm:"type:varchar(255);default:''"` // 覆盖分组默认的请求限制
 }
 
 func (user *User) GetAccessToken() string {
@@ -335,6 +337,7 @@ func (user *User) Edit(updatePassword bool) error {
 		"display_name": newUser.DisplayName,
 		"group":        newUser.Group,
 		"quota":        newUser.Quota,
+		"rate_limit":   newUser.RateLimit,
 	}
 	if updatePassword {
 		updates["password"] = newUser.Password
@@ -345,6 +348,9 @@ func (user *User) Edit(updatePassword bool) error {
 		return err
 	}
 
+	if err = updateUserRateLimitCache(user.Id, newUser.RateLimit); err != nil {
+		return err
+	}
 	// 更新缓存
 	return updateUserCache(user.Id, user.Username, user.Group, user.Quota, user.Status)
 }
@@ -581,6 +587,35 @@ func GetUserGroup(id int, fromDB bool) (group string, err error) {
 	return group, nil
 }
 
+// GetUserRateLimit 返回用户的请求限制：分组默认限制被用户单独设置的非 0 字段覆盖
+func GetUserRateLimit(id int, group string, fromDB bool) (limit setting.RelayRateLimit, err error) {
+	var rateLimit string
+	defer func() {
+		// Update Redis cache asynchronously on successful DB read
+		if shouldUpdateRedis(fromDB, err) {
+			gopool.Go(func() {
+				if err := updateUserRateLimitCache(id, rateLimit); err != nil {
+					logging.SysError("failed to update user rate limit cache: " + err.Error())
+				}
+			})
+		}
+	}()
+	limit = setting.GetGroupRelayRateLimit(group)
+	if !fromDB && common.RedisEnabled {
+		rateLimit, err := getUserRateLimitCache(id)
+		if err == nil {
+			return limit.Merge(setting.ParseRelayRateLimit(rateLimit)), nil
+		}
+		// Don't return error - fall through to DB
+	}
+	fromDB = true
+	err = DB.Model(&User{}).Where("id = ?", id).Select("rate_limit").Find(&rateLimit).Error
+	if err != nil {
+		return limit, err
+	}
+	return limit.Merge(setting.ParseRelayRateLimit(rateLimit)), nil
+}
+
 func IncreaseUserQuota(id int, quota int) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
@@ -30,6 +30,7 @@ func invalidateUserCache(userId int) error {
 		fmt.Sprintf(constant.UserQuotaKeyFmt, userId),
 		fmt.Sprintf(constant.UserEnabledKeyFmt, userId),
 		fmt.Sprintf(constant.UserUsernameKeyFmt, userId),
+		fmt.Sprintf(constant.UserRateLimitKeyFmt, userId),
 	}
 
 	for _, key := range keys {
@@ -92,6 +93,18 @@ func updateUserNameCache(userId int, username string) error {
 	)
 }
 
+// updateUserRateLimitCache updates user rate limit cache
+func updateUserRateLimitCache(userId int, rateLimit string) error {
+	if !common.RedisEnabled {
+		return nil
+	}
+	return common.RedisSet(
+		fmt.Sprintf(constant.UserRateLimitKeyFmt, userId),
+		rateLimit,
+		time.Duration(constant.UserId2QuotaCacheSeconds)*time.Second,
+	)
+}
+
 // updateUserCache updates all user cache fields
 func updateUserCache(userId int, username string, userGroup string, quota int, status int) error {
 	if !common.RedisEnabled {
@@ -125,6 +138,14 @@ func getUserGroupCache(userId int) (string, error) {
 	return common.RedisGet(fmt.Sprintf(constant.UserGroupKeyFmt, userId))
 }
 
+// getUserRateLimitCache gets user rate limit from cache
+func getUserRateLimitCache(userId int) (string, error) {
+	if !common.RedisEnabled {
+		return "", nil
+	}
+	return common.RedisGet(fmt.Sprintf(constant.UserRateLimitKeyFmt, userId))
+}
+
 // getUserQuotaCache gets user quota from cache
 func getUserQuotaCache(userId int) (int, error) {
 	if !common.RedisEnabled {
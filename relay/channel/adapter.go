@@ -1,6 +1,7 @@
 package channel
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"io"
 	"net/http"
@@ -8,6 +9,9 @@ import (
 	relaycommon "one-api/relay/common"
 )
 
+// ErrRequestNotSupported 适配器不支持该类请求时返回（可包装），调用方按客户端请求错误处理
+var ErrRequestNotSupported = errors.New("request is not supported by this channel")
+
 type Adaptor interface {
 	// Init IsStream bool
 	Init(info *relaycommon.RelayInfo)
@@ -39,5 +43,5 @@ type TaskAdaptor interface {
 	GetChannelName() string
 
 	// FetchTask
-	FetchTask(baseUrl, key string, body map[string]any) (*http.Response, error)
+	FetchTask(client *http.Client, baseUrl, key string, body map[string]any) (*http.Response, error)
 }
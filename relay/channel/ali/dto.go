@@ -88,6 +88,9 @@ type AliImageRequest struct {
 	Input struct {
 		Prompt         string `json:"prompt"`
 		NegativePrompt string `json:"negative_prompt,omitempty"`
+		Function       string `json:"function,omitempty"`
+		BaseImageUrl   string `json:"base_image_url,omitempty"`
+		MaskImageUrl   string `json:"mask_image_url,omitempty"`
 	} `json:"input"`
 	Parameters struct {
 		Size  string `json:"size,omitempty"`
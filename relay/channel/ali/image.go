@@ -1,6 +1,7 @@
 package ali
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -26,8 +27,46 @@ func oaiImage2Ali(request dto.ImageRequest) *AliImageRequest {
 	return &imageRequest
 }
 
+// oaiImageEdit2Ali 将 OpenAI 的 edits 请求转换为万相图像编辑，有 mask 时使用局部重绘
+func oaiImageEdit2Ali(c *gin.Context, request dto.ImageRequest) (*AliImageRequest, error) {
+	imageRequest := oaiImage2Ali(request)
+	imageRequest.Parameters.Size = ""
+	baseImage, err := formFileToDataUrl(c, "image")
+	if err != nil {
+		return nil, err
+	}
+	imageRequest.Input.BaseImageUrl = baseImage
+	imageRequest.Input.Function = "description_edit"
+	if _, _, err = c.Request.FormFile("mask"); err == nil {
+		maskImage, err := formFileToDataUrl(c, "mask")
+		if err != nil {
+			return nil, err
+		}
+		imageRequest.Input.MaskImageUrl = maskImage
+		imageRequest.Input.Function = "description_edit_with_mask"
+	}
+	return imageRequest, nil
+}
+
+func formFileToDataUrl(c *gin.Context, key string) (string, error) {
+	file, header, err := c.Request.FormFile(key)
+	if err != nil {
+		return "", fmt.Errorf("%s is required", key)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(data)
+	}
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)), nil
+}
+
 func updateTask(info *relaycommon.RelayInfo, taskID string, key string) (*AliResponse, error, []byte) {
-	url := fmt.Sprintf("/api/v1/tasks/%s", taskID)
+	url := fmt.Sprintf("%s/api/v1/tasks/%s", info.BaseUrl, taskID)
 
 	var aliResponse AliResponse
 
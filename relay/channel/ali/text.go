@@ -23,6 +23,11 @@ func requestOpenAI2Ali(request dto.GeneralOpenAIRequest) *dto.GeneralOpenAIReque
 	} else if request.TopP <= 0 {
 		request.TopP = 0.001
 	}
+	if service.StructuredOutputSchema(request.ResponseFormat) != nil {
+		// 兼容模式只支持 json_object，schema 通过系统提示词传递
+		service.ApplyStructuredOutputPrompt(&request)
+		request.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+	}
 	return &request
 }
 
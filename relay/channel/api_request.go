@@ -10,6 +10,7 @@ import (
 	"one-api/relay/common"
 	"one-api/relay/constant"
 	"one-api/service"
+	"strings"
 )
 
 func SetupApiRequestHeader(info *common.RelayInfo, c *gin.Context, req *http.Header) {
@@ -43,6 +44,14 @@ func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 	if err != nil {
 		return nil, fmt.Errorf("do request failed: %w", err)
 	}
+	if info.IsStream || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		timeouts := service.GetRelayTimeouts(c.GetStringMap("channel_setting"))
+		if strings.HasPrefix(info.UpstreamModelName, "o1") || strings.HasPrefix(info.UpstreamModelName, "o3") {
+			// twice timeout for o1 model
+			timeouts.StreamIdle *= 2
+		}
+		resp.Body = service.NewStreamIdleReader(c, resp.Body, timeouts)
+	}
 	return resp, nil
 }
 
@@ -80,7 +89,14 @@ func DoWssRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 		return nil, fmt.Errorf("setup request header failed: %w", err)
 	}
 	targetHeader.Set("Content-Type", c.Request.Header.Get("Content-Type"))
-	targetConn, _, err := websocket.DefaultDialer.Dial(fullRequestURL, targetHeader)
+	dialer, headers, err := service.GetChannelWebsocketDialer(c.GetStringMap("channel_setting"))
+	if err != nil {
+		return nil, fmt.Errorf("get channel dialer failed: %w", err)
+	}
+	for key, value := range headers {
+		targetHeader.Set(key, value)
+	}
+	targetConn, _, err := dialer.Dial(fullRequestURL, targetHeader)
 	if err != nil {
 		return nil, fmt.Errorf("dial failed to %s: %w", fullRequestURL, err)
 	}
@@ -91,7 +107,13 @@ func DoWssRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 }
 
 func doRequest(c *gin.Context, req *http.Request) (*http.Response, error) {
-	resp, err := service.GetHttpClient().Do(req)
+	// 渠道可以设置独立的代理、TLS 与自定义请求头
+	channelSetting := c.GetStringMap("channel_setting")
+	client, err := service.GetChannelHttpClient(c.GetInt("channel_id"), channelSetting)
+	if err != nil {
+		return nil, fmt.Errorf("get channel http client failed: %w", err)
+	}
+	resp, err := service.DoRequestWithFirstByteTimeout(client, req, service.GetRelayTimeouts(channelSetting).FirstByte)
 	if err != nil {
 		return nil, err
 	}
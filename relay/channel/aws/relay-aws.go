@@ -97,10 +97,10 @@ func awsHandler(c *gin.Context, info *relaycommon.RelayInfo, requestMode int) (*
 
 	openaiResp := claude.ResponseClaude2OpenAI(requestMode, claudeResponse)
 	usage := relaymodel.Usage{
-		PromptTokens:     claudeResponse.Usage.InputTokens,
 		CompletionTokens: claudeResponse.Usage.OutputTokens,
-		TotalTokens:      claudeResponse.Usage.InputTokens + claudeResponse.Usage.OutputTokens,
 	}
+	claudeResponse.Usage.SetPromptUsage(&usage)
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 	openaiResp.Usage = usage
 
 	c.JSON(http.StatusOK, openaiResp)
@@ -149,6 +149,7 @@ func awsStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 	var model string
 	isFirst := true
 	createdTime := common.GetTimestamp()
+	structuredOutput := &claude.StructuredOutputStream{}
 	c.Stream(func(w io.Writer) bool {
 		event, ok := <-stream.Events()
 		if !ok {
@@ -170,11 +171,13 @@ func awsStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 
 			response, claudeUsage := claude.StreamResponseClaude2OpenAI(requestMode, claudeResp)
 			if claudeUsage != nil {
-				usage.PromptTokens += claudeUsage.InputTokens
+				usage.PromptTokens += claudeUsage.InputTokens + claudeUsage.CacheReadInputTokens + claudeUsage.CacheCreationInputTokens
+				usage.PromptTokensDetails.CachedTokens += claudeUsage.CacheReadInputTokens
+				usage.PromptTokensDetails.CachedCreationTokens += claudeUsage.CacheCreationInputTokens
 				usage.CompletionTokens += claudeUsage.OutputTokens
 			}
 
-			if response == nil {
+			if response == nil || !structuredOutput.Convert(claudeResp, response) {
 				return true
 			}
 
@@ -219,3 +222,82 @@ func awsStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 	}
 	return nil, &usage
 }
+
+// ClaudeNativeHandler 以 Anthropic Messages 格式调用 Bedrock，并将 Anthropic 格式的响应原样返回给客户端
+func ClaudeNativeHandler(c *gin.Context, info *relaycommon.RelayInfo, requestBody map[string]json.RawMessage) (*relaymodel.OpenAIErrorWithStatusCode, *relaymodel.Usage) {
+	awsCli, err := newAwsClient(c, info)
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "newAwsClient")), nil
+	}
+
+	awsModelId, err := awsModelID(info.UpstreamModelName)
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "awsModelID")), nil
+	}
+
+	// bedrock 通过 model id 和接口区分模型与流式，请求体中不能包含这两个字段
+	delete(requestBody, "model")
+	delete(requestBody, "stream")
+	requestBody["anthropic_version"] = json.RawMessage(`"bedrock-2023-05-31"`)
+	body, err := json.Marshal(requestBody)
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "marshal request")), nil
+	}
+
+	usage := &relaymodel.Usage{}
+	if !info.IsStream {
+		awsResp, err := awsCli.InvokeModel(c.Request.Context(), &bedrockruntime.InvokeModelInput{
+			ModelId:     aws.String(awsModelId),
+			Accept:      aws.String("application/json"),
+			ContentType: aws.String("application/json"),
+			Body:        body,
+		})
+		if err != nil {
+			return wrapErr(errors.Wrap(err, "InvokeModel")), nil
+		}
+		claudeResponse := new(claude.ClaudeResponse)
+		if err = json.Unmarshal(awsResp.Body, claudeResponse); err != nil {
+			return wrapErr(errors.Wrap(err, "unmarshal response")), nil
+		}
+		claudeResponse.Usage.SetPromptUsage(usage)
+		usage.CompletionTokens = claudeResponse.Usage.OutputTokens
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		c.Data(http.StatusOK, "application/json", awsResp.Body)
+		return nil, usage
+	}
+
+	awsResp, err := awsCli.InvokeModelWithResponseStream(c.Request.Context(), &bedrockruntime.InvokeModelWithResponseStreamInput{
+		ModelId:     aws.String(awsModelId),
+		Accept:      aws.String("application/json"),
+		ContentType: aws.String("application/json"),
+		Body:        body,
+	})
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "InvokeModelWithResponseStream")), nil
+	}
+	stream := awsResp.GetStream()
+	defer stream.Close()
+
+	service.SetEventStreamHeaders(c)
+	var responseText strings.Builder
+	for event := range stream.Events() {
+		chunk, ok := event.(*types.ResponseStreamMemberChunk)
+		if !ok {
+			logging.SysError(fmt.Sprintf("unknown bedrock stream event: %T", event))
+			break
+		}
+		info.SetFirstResponseTime()
+		eventType, text := claude.ParseClaudeNativeStreamEvent(chunk.Value.Bytes, usage)
+		responseText.WriteString(text)
+		_, err = c.Writer.WriteString(fmt.Sprintf("event: %s\ndata: %s\n\n", eventType, string(chunk.Value.Bytes)))
+		if err != nil {
+			logging.LogError(c, "send_stream_response_failed: "+err.Error())
+			break
+		}
+		c.Writer.Flush()
+	}
+	if err = stream.Err(); err != nil {
+		logging.LogError(c, "bedrock stream error: "+err.Error())
+	}
+	return nil, claude.CompleteClaudeNativeUsage(usage, responseText.String(), info)
+}
@@ -23,6 +23,8 @@ import (
 var baiduTokenStore sync.Map
 
 func requestOpenAI2Baidu(request dto.GeneralOpenAIRequest) *BaiduChatRequest {
+	// 上游不支持 response_format，通过系统提示词模拟
+	service.ApplyStructuredOutputPrompt(&request)
 	baiduRequest := BaiduChatRequest{
 		Temperature:    request.Temperature,
 		TopP:           request.TopP,
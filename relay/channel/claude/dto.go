@@ -1,5 +1,7 @@
 package claude
 
+import "encoding/json"
+
 type ClaudeMetadata struct {
 	UserId string `json:"user_id"`
 }
@@ -15,14 +17,17 @@ type ClaudeMediaMessage struct {
 	Id        string `json:"id,omitempty"`
 	Name      string `json:"name,omitempty"`
 	Input     any    `json:"input,omitempty"`
-	Content   string `json:"content,omitempty"`
+	Content   any    `json:"content,omitempty"`
 	ToolUseId string `json:"tool_use_id,omitempty"`
+	// prompt caching
+	CacheControl any `json:"cache_control,omitempty"`
 }
 
 type ClaudeMessageSource struct {
 	Type      string `json:"type"`
 	MediaType string `json:"media_type"`
 	Data      string `json:"data"`
+	Url       string `json:"url,omitempty"`
 }
 
 type ClaudeMessage struct {
@@ -45,7 +50,7 @@ type InputSchema struct {
 type ClaudeRequest struct {
 	Model             string          `json:"model"`
 	Prompt            string          `json:"prompt,omitempty"`
-	System            string          `json:"system,omitempty"`
+	System            any             `json:"system,omitempty"` // string, or content blocks when cache_control is set
 	Messages          []ClaudeMessage `json:"messages,omitempty"`
 	MaxTokens         uint            `json:"max_tokens,omitempty"`
 	MaxTokensToSample uint            `json:"max_tokens_to_sample,omitempty"`
@@ -59,6 +64,71 @@ type ClaudeRequest struct {
 	ToolChoice any    `json:"tool_choice,omitempty"`
 }
 
+// ClaudeNativeRequest 客户端以 Anthropic Messages 格式直接发送的请求，system 可以是字符串或 content block 数组
+type ClaudeNativeRequest struct {
+	Model         string          `json:"model"`
+	System        any             `json:"system,omitempty"`
+	Messages      []ClaudeMessage `json:"messages"`
+	MaxTokens     uint            `json:"max_tokens"`
+	StopSequences []string        `json:"stop_sequences,omitempty"`
+	Temperature   float64         `json:"temperature,omitempty"`
+	TopP          float64         `json:"top_p,omitempty"`
+	TopK          int             `json:"top_k,omitempty"`
+	Stream        bool            `json:"stream,omitempty"`
+	Tools         []Tool          `json:"tools,omitempty"`
+	ToolChoice    any             `json:"tool_choice,omitempty"`
+	Metadata      *ClaudeMetadata `json:"metadata,omitempty"`
+}
+
+// ParseContent 将消息内容统一解析为 content block 数组
+func (m *ClaudeMessage) ParseContent() ([]ClaudeMediaMessage, error) {
+	if content, ok := m.Content.(string); ok {
+		return []ClaudeMediaMessage{{Type: "text", Text: content}}, nil
+	}
+	var contents []ClaudeMediaMessage
+	data, err := json.Marshal(m.Content)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &contents)
+	return contents, err
+}
+
+// ClaudeNativeResponse /v1/messages 接口返回给客户端的非流式响应
+type ClaudeNativeResponse struct {
+	Id           string               `json:"id"`
+	Type         string               `json:"type"`
+	Role         string               `json:"role"`
+	Content      []ClaudeMediaMessage `json:"content"`
+	Model        string               `json:"model"`
+	StopReason   *string              `json:"stop_reason"`
+	StopSequence *string              `json:"stop_sequence"`
+	Usage        ClaudeUsage          `json:"usage"`
+}
+
+type ClaudeNativeDelta struct {
+	Type         string  `json:"type,omitempty"`
+	Text         string  `json:"text,omitempty"`
+	PartialJson  string  `json:"partial_json,omitempty"`
+	StopReason   *string `json:"stop_reason,omitempty"`
+	StopSequence *string `json:"stop_sequence,omitempty"`
+}
+
+// ClaudeNativeStreamEvent /v1/messages 接口返回给客户端的流式事件
+type ClaudeNativeStreamEvent struct {
+	Type         string                `json:"type"`
+	Message      *ClaudeNativeResponse `json:"message,omitempty"`
+	Index        *int                  `json:"index,omitempty"`
+	ContentBlock any                   `json:"content_block,omitempty"`
+	Delta        *ClaudeNativeDelta    `json:"delta,omitempty"`
+	Usage        *ClaudeUsage          `json:"usage,omitempty"`
+}
+
+type ClaudeNativeErrorResponse struct {
+	Type  string      `json:"type"`
+	Error ClaudeError `json:"error"`
+}
+
 type ClaudeError struct {
 	Type    string `json:"type"`
 	Message string `json:"message"`
@@ -80,6 +150,8 @@ type ClaudeResponse struct {
 }
 
 type ClaudeUsage struct {
-	InputTokens  int `json:"input_tokens"`
-	OutputTokens int `json:"output_tokens"`
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
 }
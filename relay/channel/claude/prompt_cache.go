@@ -0,0 +1,22 @@
+package claude
+
+import "one-api/dto"
+
+// SetPromptUsage 按 OpenAI 的口径设置输入用量。Claude 的 input_tokens 不包含缓存部分，
+// prompt_tokens 为三者之和，其中缓存读取计入 cached_tokens，缓存写入计入 cached_creation_tokens
+func (u *ClaudeUsage) SetPromptUsage(usage *dto.Usage) {
+	usage.PromptTokens = u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	usage.PromptTokensDetails.CachedTokens = u.CacheReadInputTokens
+	usage.PromptTokensDetails.CachedCreationTokens = u.CacheCreationInputTokens
+}
+
+// claudeUsageFromOpenAI 将 OpenAI 口径的用量转换为 Claude 的用量，input_tokens 不包含缓存读取与写入
+func claudeUsageFromOpenAI(usage *dto.Usage) ClaudeUsage {
+	cacheTokens := usage.PromptTokensDetails.CachedTokens + usage.PromptTokensDetails.CachedCreationTokens
+	return ClaudeUsage{
+		InputTokens:              max(usage.PromptTokens-cacheTokens, 0),
+		OutputTokens:             usage.CompletionTokens,
+		CacheReadInputTokens:     usage.PromptTokensDetails.CachedTokens,
+		CacheCreationInputTokens: usage.PromptTokensDetails.CachedCreationTokens,
+	}
+}
@@ -0,0 +1,505 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"one-api/logging")
+
+func stopReasonOpenAI2Claude(reason string) string {
+	switch reason {
+	case "stop":
+		return "end_turn"
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "":
+		return "end_turn"
+	default:
+		return reason
+	}
+}
+
+func claudeContentText(content any) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []any:
+		texts := make([]string, 0, len(v))
+		for _, item := range v {
+			if block, ok := item.(map[string]any); ok && block["type"] == "text" {
+				if text, ok := block["text"].(string); ok {
+					texts = append(texts, text)
+				}
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
+
+func claudeImageUrl(source *ClaudeMessageSource) string {
+	if source == nil {
+		return ""
+	}
+	if source.Type == "url" {
+		return source.Url
+	}
+	return fmt.Sprintf("data:%s;base64,%s", source.MediaType, source.Data)
+}
+
+// RequestClaude2OpenAI 将 Anthropic Messages 格式的请求转换为 OpenAI 格式，用于非 Claude 渠道
+func RequestClaude2OpenAI(claudeRequest *ClaudeNativeRequest) (*dto.GeneralOpenAIRequest, error) {
+	openAIRequest := dto.GeneralOpenAIRequest{
+		Model:       claudeRequest.Model,
+		MaxTokens:   claudeRequest.MaxTokens,
+		Temperature: claudeRequest.Temperature,
+		TopP:        claudeRequest.TopP,
+		TopK:        claudeRequest.TopK,
+		Stream:      claudeRequest.Stream,
+	}
+	if len(claudeRequest.StopSequences) > 0 {
+		openAIRequest.Stop = claudeRequest.StopSequences
+	}
+	if claudeRequest.Metadata != nil {
+		openAIRequest.User = claudeRequest.Metadata.UserId
+	}
+	for _, tool := range claudeRequest.Tools {
+		openAIRequest.Tools = append(openAIRequest.Tools, dto.ToolCall{
+			Type: "function",
+			Function: dto.FunctionCall{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			},
+		})
+	}
+	if toolChoice, ok := claudeRequest.ToolChoice.(map[string]any); ok {
+		switch toolChoice["type"] {
+		case "auto":
+			openAIRequest.ToolChoice = "auto"
+		case "any":
+			openAIRequest.ToolChoice = "required"
+		case "none":
+			openAIRequest.ToolChoice = "none"
+		case "tool":
+			openAIRequest.ToolChoice = map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name": toolChoice["name"],
+				},
+			}
+		}
+	}
+
+	messages := make([]dto.Message, 0, len(claudeRequest.Messages)+1)
+	if system := claudeContentText(claudeRequest.System); system != "" {
+		systemMessage := dto.Message{Role: "system"}
+		systemMessage.SetStringContent(system)
+		messages = append(messages, systemMessage)
+	}
+	for i, claudeMessage := range claudeRequest.Messages {
+		contents, err := claudeMessage.ParseContent()
+		if err != nil {
+			return nil, fmt.Errorf("invalid content of messages[%d]: %s", i, err.Error())
+		}
+		mediaContents := make([]dto.MediaContent, 0, len(contents))
+		toolCalls := make([]dto.ToolCall, 0)
+		for _, content := range contents {
+			switch content.Type {
+			case "text":
+				mediaContents = append(mediaContents, dto.MediaContent{
+					Type: dto.ContentTypeText,
+					Text: content.Text,
+				})
+			case "image":
+				mediaContents = append(mediaContents, dto.MediaContent{
+					Type: dto.ContentTypeImageURL,
+					ImageUrl: dto.MessageImageUrl{
+						Url:    claudeImageUrl(content.Source),
+						Detail: "auto",
+					},
+				})
+			case "tool_use":
+				arguments, _ := json.Marshal(content.Input)
+				toolCalls = append(toolCalls, dto.ToolCall{
+					ID:   content.Id,
+					Type: "function",
+					Function: dto.FunctionCall{
+						Name:      content.Name,
+						Arguments: string(arguments),
+					},
+				})
+			case "tool_result":
+				// tool 消息必须紧跟在 assistant 的 tool_calls 之后
+				toolMessage := dto.Message{
+					Role:       "tool",
+					ToolCallId: content.ToolUseId,
+				}
+				toolMessage.SetStringContent(claudeContentText(content.Content))
+				messages = append(messages, toolMessage)
+			}
+		}
+		if len(mediaContents) == 0 && len(toolCalls) == 0 {
+			continue
+		}
+		message := dto.Message{Role: claudeMessage.Role}
+		if len(mediaContents) == 1 && mediaContents[0].Type == dto.ContentTypeText {
+			message.SetStringContent(mediaContents[0].Text)
+		} else if len(mediaContents) > 0 {
+			message.Content, _ = json.Marshal(mediaContents)
+		} else {
+			message.SetStringContent("")
+		}
+		if len(toolCalls) > 0 {
+			message.SetToolCalls(toolCalls)
+		}
+		messages = append(messages, message)
+	}
+	openAIRequest.Messages = messages
+	return &openAIRequest, nil
+}
+
+// ResponseOpenAI2Claude 将 OpenAI 格式的非流式响应转换为 Anthropic Messages 格式
+func ResponseOpenAI2Claude(response *dto.OpenAITextResponse, usage *dto.Usage) *ClaudeNativeResponse {
+	claudeResponse := &ClaudeNativeResponse{
+		Id:      fmt.Sprintf("msg_%s", common.GetUUID()),
+		Type:    "message",
+		Role:    "assistant",
+		Content: make([]ClaudeMediaMessage, 0),
+		Model:   response.Model,
+	}
+	finishReason := ""
+	if len(response.Choices) > 0 {
+		choice := response.Choices[0]
+		finishReason = choice.FinishReason
+		if text := choice.Message.StringContent(); text != "" {
+			claudeResponse.Content = append(claudeResponse.Content, ClaudeMediaMessage{
+				Type: "text",
+				Text: text,
+			})
+		}
+		for _, toolCall := range choice.Message.ParseToolCalls() {
+			input := make(map[string]any)
+			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &input); err != nil {
+				logging.SysError("tool call function arguments is not a map[string]any: " + toolCall.Function.Arguments)
+			}
+			claudeResponse.Content = append(claudeResponse.Content, ClaudeMediaMessage{
+				Type:  "tool_use",
+				Id:    toolCall.ID,
+				Name:  toolCall.Function.Name,
+				Input: input,
+			})
+		}
+	}
+	stopReason := stopReasonOpenAI2Claude(finishReason)
+	claudeResponse.StopReason = &stopReason
+	if usage != nil {
+		claudeResponse.Usage = claudeUsageFromOpenAI(usage)
+	}
+	return claudeResponse
+}
+
+// OpenAI2ClaudeStreamConverter 将 OpenAI 格式的流式响应逐块转换为 Anthropic 流式事件
+type OpenAI2ClaudeStreamConverter struct {
+	Id           string
+	Model        string
+	PromptTokens int
+
+	started    bool
+	finished   bool
+	blockIndex int
+	blockType  string // 当前未关闭的 content block 类型，为空表示没有
+	toolIndex  int
+	toolId     string
+	stopReason string
+}
+
+func (s *OpenAI2ClaudeStreamConverter) currentIndex() *int {
+	index := s.blockIndex
+	return &index
+}
+
+func (s *OpenAI2ClaudeStreamConverter) start() []ClaudeNativeStreamEvent {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	if s.Id == "" {
+		s.Id = fmt.Sprintf("msg_%s", common.GetUUID())
+	}
+	return []ClaudeNativeStreamEvent{{
+		Type: "message_start",
+		Message: &ClaudeNativeResponse{
+			Id:      s.Id,
+			Type:    "message",
+			Role:    "assistant",
+			Content: make([]ClaudeMediaMessage, 0),
+			Model:   s.Model,
+			Usage: ClaudeUsage{
+				InputTokens: s.PromptTokens,
+			},
+		},
+	}}
+}
+
+func (s *OpenAI2ClaudeStreamConverter) closeBlock() []ClaudeNativeStreamEvent {
+	if s.blockType == "" {
+		return nil
+	}
+	event := ClaudeNativeStreamEvent{
+		Type:  "content_block_stop",
+		Index: s.currentIndex(),
+	}
+	s.blockType = ""
+	s.blockIndex++
+	return []ClaudeNativeStreamEvent{event}
+}
+
+func (s *OpenAI2ClaudeStreamConverter) Convert(response *dto.ChatCompletionsStreamResponse) []ClaudeNativeStreamEvent {
+	if s.Model == "" {
+		s.Model = response.Model
+	}
+	events := s.start()
+	if len(response.Choices) == 0 {
+		return events
+	}
+	choice := response.Choices[0]
+	if text := choice.Delta.GetContentString(); text != "" {
+		if s.blockType != "text" {
+			events = append(events, s.closeBlock()...)
+			s.blockType = "text"
+			events = append(events, ClaudeNativeStreamEvent{
+				Type:  "content_block_start",
+				Index: s.currentIndex(),
+				ContentBlock: map[string]any{
+					"type": "text",
+					"text": "",
+				},
+			})
+		}
+		events = append(events, ClaudeNativeStreamEvent{
+			Type:  "content_block_delta",
+			Index: s.currentIndex(),
+			Delta: &ClaudeNativeDelta{
+				Type: "text_delta",
+				Text: text,
+			},
+		})
+	}
+	for _, toolCall := range choice.Delta.ToolCalls {
+		toolIndex := 0
+		if toolCall.Index != nil {
+			toolIndex = *toolCall.Index
+		}
+		if s.blockType != "tool_use" || toolIndex != s.toolIndex || (toolCall.ID != "" && toolCall.ID != s.toolId) {
+			events = append(events, s.closeBlock()...)
+			s.blockType = "tool_use"
+			s.toolIndex = toolIndex
+			s.toolId = toolCall.ID
+			toolId := toolCall.ID
+			if toolId == "" {
+				toolId = fmt.Sprintf("toolu_%s", common.GetUUID())
+			}
+			events = append(events, ClaudeNativeStreamEvent{
+				Type:  "content_block_start",
+				Index: s.currentIndex(),
+				ContentBlock: map[string]any{
+					"type":  "tool_use",
+					"id":    toolId,
+					"name":  toolCall.Function.Name,
+					"input": map[string]any{},
+				},
+			})
+		}
+		if toolCall.Function.Arguments != "" {
+			events = append(events, ClaudeNativeStreamEvent{
+				Type:  "content_block_delta",
+				Index: s.currentIndex(),
+				Delta: &ClaudeNativeDelta{
+					Type:        "input_json_delta",
+					PartialJson: toolCall.Function.Arguments,
+				},
+			})
+		}
+	}
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		s.stopReason = *choice.FinishReason
+	}
+	return events
+}
+
+// Finish 关闭未结束的 content block，并发送带有最终用量的 message_delta 和 message_stop
+func (s *OpenAI2ClaudeStreamConverter) Finish(usage *dto.Usage) []ClaudeNativeStreamEvent {
+	if s.finished {
+		return nil
+	}
+	s.finished = true
+	events := s.start()
+	events = append(events, s.closeBlock()...)
+	stopReason := stopReasonOpenAI2Claude(s.stopReason)
+	messageDelta := ClaudeNativeStreamEvent{
+		Type: "message_delta",
+		Delta: &ClaudeNativeDelta{
+			StopReason: &stopReason,
+		},
+		Usage: &ClaudeUsage{},
+	}
+	if usage != nil {
+		claudeUsage := claudeUsageFromOpenAI(usage)
+		messageDelta.Usage = &claudeUsage
+	}
+	events = append(events, messageDelta, ClaudeNativeStreamEvent{Type: "message_stop"})
+	return events
+}
+
+// ParseClaudeNativeStreamEvent 解析上游的 Anthropic 流式事件，更新用量并返回事件类型和文本增量
+func ParseClaudeNativeStreamEvent(data []byte, usage *dto.Usage) (string, string) {
+	var claudeResponse ClaudeResponse
+	if err := json.Unmarshal(data, &claudeResponse); err != nil {
+		logging.SysError("error unmarshalling stream response: " + err.Error())
+		return "", ""
+	}
+	text := ""
+	switch claudeResponse.Type {
+	case "message_start":
+		if claudeResponse.Message != nil {
+			claudeResponse.Message.Usage.SetPromptUsage(usage)
+		}
+	case "content_block_delta":
+		if claudeResponse.Delta != nil {
+			text = claudeResponse.Delta.Text + claudeResponse.Delta.PartialJson
+		}
+	case "message_delta":
+		usage.CompletionTokens = claudeResponse.Usage.OutputTokens
+	}
+	return claudeResponse.Type, text
+}
+
+// CompleteClaudeNativeUsage 上游未返回用量时按文本估算
+func CompleteClaudeNativeUsage(usage *dto.Usage, responseText string, info *relaycommon.RelayInfo) *dto.Usage {
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = info.PromptTokens
+	}
+	if usage.CompletionTokens == 0 {
+		promptTokensDetails := usage.PromptTokensDetails
+		usage, _ = service.ResponseText2Usage(responseText, info.UpstreamModelName, usage.PromptTokens)
+		usage.PromptTokensDetails = promptTokensDetails
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// ClaudeNativeStreamHandler 将上游 Anthropic 格式的流式响应原样转发给客户端
+func ClaudeNativeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	usage := &dto.Usage{}
+	var responseText strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Split(bufio.ScanLines)
+	service.SetEventStreamHeaders(c)
+
+	for scanner.Scan() {
+		data := scanner.Text()
+		info.SetFirstResponseTime()
+		if _, err := c.Writer.WriteString(data + "\n"); err != nil {
+			logging.LogError(c, "send_stream_response_failed: "+err.Error())
+			break
+		}
+		if data == "" {
+			c.Writer.Flush()
+			continue
+		}
+		if !strings.HasPrefix(data, "data:") {
+			continue
+		}
+		_, text := ParseClaudeNativeStreamEvent([]byte(strings.TrimSpace(strings.TrimPrefix(data, "data:"))), usage)
+		responseText.WriteString(text)
+	}
+	c.Writer.Flush()
+	resp.Body.Close()
+	return nil, CompleteClaudeNativeUsage(usage, responseText.String(), info)
+}
+
+// ClaudeNativeHandler 将上游 Anthropic 格式的非流式响应原样转发给客户端
+func ClaudeNativeHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
+	}
+	var claudeResponse ClaudeResponse
+	err = json.Unmarshal(responseBody, &claudeResponse)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	if claudeResponse.Error.Type != "" {
+		return &dto.OpenAIErrorWithStatusCode{
+			Error: dto.OpenAIError{
+				Message: claudeResponse.Error.Message,
+				Type:    claudeResponse.Error.Type,
+				Param:   "",
+				Code:    claudeResponse.Error.Type,
+			},
+			StatusCode: resp.StatusCode,
+		}, nil
+	}
+	usage := &dto.Usage{
+		CompletionTokens: claudeResponse.Usage.OutputTokens,
+	}
+	claudeResponse.Usage.SetPromptUsage(usage)
+	responseText := ""
+	for _, content := range claudeResponse.Content {
+		responseText += content.Text
+	}
+	usage = CompleteClaudeNativeUsage(usage, responseText, info)
+
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, err = c.Writer.Write(responseBody)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "write_response_body_failed", http.StatusInternalServerError), nil
+	}
+	return nil, usage
+}
+
+// ErrorOpenAI2Claude 将错误转换为 Anthropic 格式
+func ErrorOpenAI2Claude(openaiErr *dto.OpenAIErrorWithStatusCode) *ClaudeNativeErrorResponse {
+	errorType := "api_error"
+	switch openaiErr.StatusCode {
+	case http.StatusBadRequest:
+		errorType = "invalid_request_error"
+	case http.StatusUnauthorized:
+		errorType = "authentication_error"
+	case http.StatusForbidden:
+		errorType = "permission_error"
+	case http.StatusNotFound:
+		errorType = "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		errorType = "request_too_large"
+	case http.StatusTooManyRequests:
+		errorType = "rate_limit_error"
+	case 529:
+		errorType = "overloaded_error"
+	}
+	return &ClaudeNativeErrorResponse{
+		Type: "error",
+		Error: ClaudeError{
+			Type:    errorType,
+			Message: openaiErr.Error.Message,
+		},
+	}
+}
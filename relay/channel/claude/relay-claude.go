@@ -152,12 +152,25 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*ClaudeR
 			} else {
 				contents := message.ParseContent()
 				content := ""
+				systemBlocks := make([]ClaudeMediaMessage, 0, len(contents))
+				hasCacheControl := false
 				for _, ctx := range contents {
 					if ctx.Type == "text" {
 						content += ctx.Text
+						systemBlocks = append(systemBlocks, ClaudeMediaMessage{
+							Type:         "text",
+							Text:         ctx.Text,
+							CacheControl: ctx.CacheControl,
+						})
+						hasCacheControl = hasCacheControl || ctx.CacheControl != nil
 					}
 				}
-				claudeRequest.System = content
+				if hasCacheControl {
+					// 保留 content block 及其 cache_control，上游才能缓存系统提示词
+					claudeRequest.System = systemBlocks
+				} else {
+					claudeRequest.System = content
+				}
 			}
 		} else {
 			if isFirstMessage {
@@ -213,7 +226,8 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*ClaudeR
 				claudeMediaMessages := make([]ClaudeMediaMessage, 0)
 				for _, mediaMessage := range message.ParseContent() {
 					claudeMediaMessage := ClaudeMediaMessage{
-						Type: mediaMessage.Type,
+						Type:         mediaMessage.Type,
+						CacheControl: mediaMessage.CacheControl,
 					}
 					if mediaMessage.Type == "text" {
 						claudeMediaMessage.Text = mediaMessage.Text
@@ -265,6 +279,7 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*ClaudeR
 	}
 	claudeRequest.Prompt = ""
 	claudeRequest.Messages = claudeMessages
+	applyStructuredOutput(&claudeRequest, textRequest.ResponseFormat)
 	return &claudeRequest, nil
 }
 
@@ -353,6 +368,7 @@ func ResponseClaude2OpenAI(reqMode int, claudeResponse *ClaudeResponse) *dto.Ope
 		responseText = claudeResponse.Content[0].Text
 	}
 	tools := make([]dto.ToolCall, 0)
+	structuredOutput := false
 	if reqMode == RequestModeCompletion {
 		content, _ := json.Marshal(strings.TrimPrefix(claudeResponse.Completion, " "))
 		choice := dto.OpenAITextResponseChoice{
@@ -370,6 +386,12 @@ func ResponseClaude2OpenAI(reqMode int, claudeResponse *ClaudeResponse) *dto.Ope
 		for _, message := range claudeResponse.Content {
 			if message.Type == "tool_use" {
 				args, _ := json.Marshal(message.Input)
+				if message.Name == StructuredOutputToolName {
+					// 模拟的结构化输出，工具参数即为 JSON 结果
+					responseText = string(args)
+					structuredOutput = true
+					continue
+				}
 				tools = append(tools, dto.ToolCall{
 					ID:   message.Id,
 					Type: "function", // compatible with other OpenAI derivative applications
@@ -388,6 +410,9 @@ func ResponseClaude2OpenAI(reqMode int, claudeResponse *ClaudeResponse) *dto.Ope
 		},
 		FinishReason: stopReasonClaude2OpenAI(claudeResponse.StopReason),
 	}
+	if structuredOutput && choice.FinishReason == "tool_use" {
+		choice.FinishReason = "stop"
+	}
 	choice.SetStringContent(responseText)
 	if len(tools) > 0 {
 		choice.Message.SetToolCalls(tools)
@@ -407,6 +432,8 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 	scanner := bufio.NewScanner(resp.Body)
 	scanner.Split(bufio.ScanLines)
 	service.SetEventStreamHeaders(c)
+	service.EnableStreamKeepalive(resp.Body)
+	structuredOutput := &StructuredOutputStream{}
 
 	for scanner.Scan() {
 		data := scanner.Text()
@@ -427,6 +454,9 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 		if response == nil {
 			continue
 		}
+		if !structuredOutput.Convert(&claudeResponse, response) {
+			continue
+		}
 		if requestMode == RequestModeCompletion {
 			responseText += claudeResponse.Completion
 			responseId = response.Id
@@ -435,12 +465,12 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 				// message_start, 获取usage
 				responseId = claudeResponse.Message.Id
 				info.UpstreamModelName = claudeResponse.Message.Model
-				usage.PromptTokens = claudeUsage.InputTokens
+				claudeUsage.SetPromptUsage(usage)
 			} else if claudeResponse.Type == "content_block_delta" {
-				responseText += claudeResponse.Delta.Text
+				responseText += response.Choices[0].Delta.GetContentString()
 			} else if claudeResponse.Type == "message_delta" {
 				usage.CompletionTokens = claudeUsage.OutputTokens
-				usage.TotalTokens = claudeUsage.InputTokens + claudeUsage.OutputTokens
+				usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 			} else if claudeResponse.Type == "content_block_start" {
 
 			} else {
@@ -458,6 +488,12 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 		}
 	}
 
+	if service.IsStreamIdleTimeout(resp.Body) && !c.Writer.Written() {
+		// 尚未向客户端输出任何内容，交给重试逻辑处理，不发送用量与结束标记
+		resp.Body.Close()
+		return service.OpenAIErrorWrapper(service.ErrStreamIdleTimeout, service.StreamTimeoutErrorCode, http.StatusGatewayTimeout), nil
+	}
+
 	if requestMode == RequestModeCompletion {
 		usage, _ = service.ResponseText2Usage(responseText, info.UpstreamModelName, info.PromptTokens)
 	} else {
@@ -465,7 +501,9 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 			usage.PromptTokens = info.PromptTokens
 		}
 		if usage.CompletionTokens == 0 {
+			promptTokensDetails := usage.PromptTokensDetails
 			usage, _ = service.ResponseText2Usage(responseText, info.UpstreamModelName, usage.PromptTokens)
+			usage.PromptTokensDetails = promptTokensDetails
 		}
 	}
 	if info.ShouldIncludeUsage {
@@ -516,9 +554,9 @@ func ClaudeHandler(c *gin.Context, resp *http.Response, requestMode int, info *r
 		usage.CompletionTokens = completionTokens
 		usage.TotalTokens = info.PromptTokens + completionTokens
 	} else {
-		usage.PromptTokens = claudeResponse.Usage.InputTokens
+		claudeResponse.Usage.SetPromptUsage(&usage)
 		usage.CompletionTokens = claudeResponse.Usage.OutputTokens
-		usage.TotalTokens = claudeResponse.Usage.InputTokens + claudeResponse.Usage.OutputTokens
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 	}
 	fullTextResponse.Usage = usage
 	jsonResponse, err := json.Marshal(fullTextResponse)
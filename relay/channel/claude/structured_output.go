@@ -0,0 +1,82 @@
+package claude
+
+import (
+	"one-api/dto"
+	"one-api/service"
+)
+
+// StructuredOutputToolName 模拟 json_schema 结构化输出时强制调用的工具，响应中该工具的参数转换回文本内容
+const StructuredOutputToolName = "structured_output"
+
+// applyStructuredOutput 将 response_format 转换为 Claude 请求：json_schema 的根类型为 object 且请求没有其他工具时
+// 通过强制工具调用实现，否则退化为系统提示词
+func applyStructuredOutput(claudeRequest *ClaudeRequest, format *dto.ResponseFormat) {
+	schema := service.StructuredOutputSchema(format)
+	if schema != nil && schema["type"] == "object" && len(claudeRequest.Tools) == 0 {
+		description := format.JsonSchema.Description
+		if description == "" {
+			description = "Respond with structured output that conforms to the input schema."
+		}
+		claudeRequest.Tools = []Tool{
+			{
+				Name:        StructuredOutputToolName,
+				Description: description,
+				InputSchema: schema,
+			},
+		}
+		claudeRequest.ToolChoice = map[string]string{
+			"type": "tool",
+			"name": StructuredOutputToolName,
+		}
+		return
+	}
+	prompt := service.StructuredOutputPrompt(format)
+	if prompt == "" {
+		return
+	}
+	switch system := claudeRequest.System.(type) {
+	case []ClaudeMediaMessage:
+		claudeRequest.System = append(system, ClaudeMediaMessage{Type: "text", Text: prompt})
+	case string:
+		if system != "" {
+			prompt = system + "\n\n" + prompt
+		}
+		claudeRequest.System = prompt
+	default:
+		claudeRequest.System = prompt
+	}
+}
+
+// StructuredOutputStream 流式响应中把模拟结构化输出的工具调用转换为文本内容
+type StructuredOutputStream struct {
+	active bool
+}
+
+// Convert 修改转换后的流式响应，返回 false 表示该事件不需要发送给客户端
+func (s *StructuredOutputStream) Convert(claudeResponse *ClaudeResponse, response *dto.ChatCompletionsStreamResponse) bool {
+	switch claudeResponse.Type {
+	case "content_block_start":
+		if claudeResponse.ContentBlock != nil && claudeResponse.ContentBlock.Type == "tool_use" &&
+			claudeResponse.ContentBlock.Name == StructuredOutputToolName {
+			s.active = true
+			return false
+		}
+	case "content_block_delta":
+		if s.active && claudeResponse.Delta != nil && claudeResponse.Delta.Type == "input_json_delta" {
+			for i := range response.Choices {
+				response.Choices[i].Delta.ToolCalls = nil
+				response.Choices[i].Delta.SetContentString(claudeResponse.Delta.PartialJson)
+			}
+		}
+	case "message_delta":
+		if s.active {
+			for i := range response.Choices {
+				if reason := response.Choices[i].FinishReason; reason != nil && *reason == "tool_use" {
+					stop := "stop"
+					response.Choices[i].FinishReason = &stop
+				}
+			}
+		}
+	}
+	return true
+}
@@ -9,6 +9,13 @@ type CohereRequest struct {
 	Stream      bool          `json:"stream"`
 	MaxTokens   int           `json:"max_tokens"`
 	SafetyMode  string        `json:"safety_mode,omitempty"`
+	// ResponseFormat 对应 OpenAI 的 response_format，json_schema 的 schema 放在 json_object 的 schema 中
+	ResponseFormat *CohereResponseFormat `json:"response_format,omitempty"`
+}
+
+type CohereResponseFormat struct {
+	Type   string `json:"type"`
+	Schema any    `json:"schema,omitempty"`
 }
 
 type ChatHistory struct {
@@ -30,6 +30,14 @@ func requestOpenAI2Cohere(textRequest dto.GeneralOpenAIRequest) *CohereRequest {
 	if cohereReq.MaxTokens == 0 {
 		cohereReq.MaxTokens = 4000
 	}
+	if textRequest.ResponseFormat != nil && (textRequest.ResponseFormat.Type == "json_object" || textRequest.ResponseFormat.Type == "json_schema") {
+		cohereReq.ResponseFormat = &CohereResponseFormat{
+			Type: "json_object",
+		}
+		if schema := service.StructuredOutputSchema(textRequest.ResponseFormat); schema != nil {
+			cohereReq.ResponseFormat.Schema = schema
+		}
+	}
 	for _, msg := range textRequest.Messages {
 		if msg.Role == "user" {
 			cohereReq.Message = msg.StringContent()
@@ -16,6 +16,8 @@ import (
 	"one-api/logging")
 
 func requestOpenAI2Dify(request dto.GeneralOpenAIRequest) *DifyChatRequest {
+	// 上游不支持 response_format，通过系统提示词模拟
+	service.ApplyStructuredOutputPrompt(&request)
 	content := ""
 	for _, message := range request.Messages {
 		if message.Role == "system" {
@@ -8,6 +8,35 @@ type GeminiChatRequest struct {
 	SystemInstructions *GeminiChatContent         `json:"system_instruction,omitempty"`
 }
 
+// GeminiNativeRequest 客户端以 Gemini 原生格式发送的请求，字段使用 camelCase
+type GeminiNativeRequest struct {
+	Contents          []GeminiChatContent        `json:"contents"`
+	SafetySettings    []GeminiChatSafetySettings `json:"safetySettings,omitempty"`
+	GenerationConfig  GeminiChatGenerationConfig `json:"generationConfig,omitempty"`
+	Tools             []GeminiChatTool           `json:"tools,omitempty"`
+	ToolConfig        *GeminiToolConfig          `json:"toolConfig,omitempty"`
+	SystemInstruction *GeminiChatContent         `json:"systemInstruction,omitempty"`
+}
+
+type GeminiToolConfig struct {
+	FunctionCallingConfig *GeminiFunctionCallingConfig `json:"functionCallingConfig,omitempty"`
+}
+
+type GeminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode,omitempty"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+type GeminiErrorDetail struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+type GeminiErrorResponse struct {
+	Error GeminiErrorDetail `json:"error"`
+}
+
 type GeminiInlineData struct {
 	MimeType string `json:"mimeType"`
 	Data     string `json:"data"`
@@ -24,8 +53,8 @@ type GeminiFunctionResponseContent struct {
 }
 
 type FunctionResponse struct {
-	Name     string                        `json:"name"`
-	Response GeminiFunctionResponseContent `json:"response"`
+	Name     string `json:"name"`
+	Response any    `json:"response"`
 }
 
 type GeminiPartExecutableCode struct {
@@ -108,4 +137,6 @@ type GeminiUsageMetadata struct {
 	PromptTokenCount     int `json:"promptTokenCount"`
 	CandidatesTokenCount int `json:"candidatesTokenCount"`
 	TotalTokenCount      int `json:"totalTokenCount"`
+	// CachedContentTokenCount 命中缓存的输入 token，包含在 promptTokenCount 中
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"`
 }
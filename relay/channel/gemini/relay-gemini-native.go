@@ -0,0 +1,432 @@
+package gemini
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"one-api/logging")
+
+func geminiPartsText(content *GeminiChatContent) string {
+	if content == nil {
+		return ""
+	}
+	texts := make([]string, 0, len(content.Parts))
+	for _, part := range content.Parts {
+		if part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// RequestGemini2OpenAI 将 Gemini 原生格式的请求转换为 OpenAI 格式，用于非 Gemini 渠道
+func RequestGemini2OpenAI(geminiRequest *GeminiNativeRequest, model string) (*dto.GeneralOpenAIRequest, error) {
+	config := geminiRequest.GenerationConfig
+	openAIRequest := dto.GeneralOpenAIRequest{
+		Model:       model,
+		MaxTokens:   config.MaxOutputTokens,
+		Temperature: config.Temperature,
+		TopP:        config.TopP,
+		TopK:        int(config.TopK),
+		N:           config.CandidateCount,
+		Seed:        float64(config.Seed),
+	}
+	if len(config.StopSequences) > 0 {
+		openAIRequest.Stop = config.StopSequences
+	}
+	if config.ResponseMimeType == "application/json" {
+		if config.ResponseSchema != nil {
+			openAIRequest.ResponseFormat = &dto.ResponseFormat{
+				Type: "json_schema",
+				JsonSchema: &dto.FormatJsonSchema{
+					Name:   "response",
+					Schema: config.ResponseSchema,
+				},
+			}
+		} else {
+			openAIRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+		}
+	}
+
+	for _, tool := range geminiRequest.Tools {
+		if tool.FunctionDeclarations == nil {
+			continue
+		}
+		var functions []dto.FunctionCall
+		data, err := json.Marshal(tool.FunctionDeclarations)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(data, &functions); err != nil {
+			return nil, fmt.Errorf("invalid functionDeclarations: %s", err.Error())
+		}
+		for _, function := range functions {
+			openAIRequest.Tools = append(openAIRequest.Tools, dto.ToolCall{
+				Type:     "function",
+				Function: function,
+			})
+		}
+	}
+	if geminiRequest.ToolConfig != nil && geminiRequest.ToolConfig.FunctionCallingConfig != nil {
+		callingConfig := geminiRequest.ToolConfig.FunctionCallingConfig
+		switch callingConfig.Mode {
+		case "AUTO":
+			openAIRequest.ToolChoice = "auto"
+		case "NONE":
+			openAIRequest.ToolChoice = "none"
+		case "ANY":
+			if len(callingConfig.AllowedFunctionNames) == 1 {
+				openAIRequest.ToolChoice = map[string]any{
+					"type": "function",
+					"function": map[string]any{
+						"name": callingConfig.AllowedFunctionNames[0],
+					},
+				}
+			} else {
+				openAIRequest.ToolChoice = "required"
+			}
+		}
+	}
+
+	messages := make([]dto.Message, 0, len(geminiRequest.Contents)+1)
+	if system := geminiPartsText(geminiRequest.SystemInstruction); system != "" {
+		systemMessage := dto.Message{Role: "system"}
+		systemMessage.SetStringContent(system)
+		messages = append(messages, systemMessage)
+	}
+	// gemini 的 functionResponse 只有函数名，按顺序对应到之前生成的 tool_call_id
+	toolCallIds := make(map[string][]string)
+	for _, content := range geminiRequest.Contents {
+		role := "user"
+		if content.Role == "model" {
+			role = "assistant"
+		}
+		mediaContents := make([]dto.MediaContent, 0, len(content.Parts))
+		toolCalls := make([]dto.ToolCall, 0)
+		for _, part := range content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				arguments, _ := json.Marshal(part.FunctionCall.Arguments)
+				toolCallId := fmt.Sprintf("call_%s", common.GetUUID())
+				toolCallIds[part.FunctionCall.FunctionName] = append(toolCallIds[part.FunctionCall.FunctionName], toolCallId)
+				toolCalls = append(toolCalls, dto.ToolCall{
+					ID:   toolCallId,
+					Type: "function",
+					Function: dto.FunctionCall{
+						Name:      part.FunctionCall.FunctionName,
+						Arguments: string(arguments),
+					},
+				})
+			case part.FunctionResponse != nil:
+				name := part.FunctionResponse.Name
+				toolMessage := dto.Message{Role: "tool"}
+				if ids := toolCallIds[name]; len(ids) > 0 {
+					toolMessage.ToolCallId = ids[0]
+					toolCallIds[name] = ids[1:]
+				}
+				response, _ := json.Marshal(part.FunctionResponse.Response)
+				toolMessage.SetStringContent(string(response))
+				messages = append(messages, toolMessage)
+			case part.InlineData != nil:
+				dataUrl := fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data)
+				if strings.HasPrefix(part.InlineData.MimeType, "audio/") {
+					mediaContents = append(mediaContents, dto.MediaContent{
+						Type: dto.ContentTypeInputAudio,
+						InputAudio: dto.MessageInputAudio{
+							Data:   part.InlineData.Data,
+							Format: strings.TrimPrefix(part.InlineData.MimeType, "audio/"),
+						},
+					})
+				} else {
+					mediaContents = append(mediaContents, dto.MediaContent{
+						Type: dto.ContentTypeImageURL,
+						ImageUrl: dto.MessageImageUrl{
+							Url:    dataUrl,
+							Detail: "auto",
+						},
+					})
+				}
+			case part.FileData != nil:
+				mediaContents = append(mediaContents, dto.MediaContent{
+					Type: dto.ContentTypeImageURL,
+					ImageUrl: dto.MessageImageUrl{
+						Url:    part.FileData.FileUri,
+						Detail: "auto",
+					},
+				})
+			case part.Text != "":
+				mediaContents = append(mediaContents, dto.MediaContent{
+					Type: dto.ContentTypeText,
+					Text: part.Text,
+				})
+			}
+		}
+		if len(mediaContents) == 0 && len(toolCalls) == 0 {
+			continue
+		}
+		message := dto.Message{Role: role}
+		if len(mediaContents) == 1 && mediaContents[0].Type == dto.ContentTypeText {
+			message.SetStringContent(mediaContents[0].Text)
+		} else if len(mediaContents) > 0 {
+			message.Content, _ = json.Marshal(mediaContents)
+		} else {
+			message.SetStringContent("")
+		}
+		if len(toolCalls) > 0 {
+			message.SetToolCalls(toolCalls)
+		}
+		messages = append(messages, message)
+	}
+	openAIRequest.Messages = messages
+	return &openAIRequest, nil
+}
+
+func finishReasonOpenAI2Gemini(reason string) string {
+	switch reason {
+	case constant.FinishReasonLength:
+		return "MAX_TOKENS"
+	case constant.FinishReasonContentFilter:
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+func usageOpenAI2Gemini(usage *dto.Usage) GeminiUsageMetadata {
+	if usage == nil {
+		return GeminiUsageMetadata{}
+	}
+	return GeminiUsageMetadata{
+		PromptTokenCount:        usage.PromptTokens,
+		CandidatesTokenCount:    usage.CompletionTokens,
+		TotalTokenCount:         usage.PromptTokens + usage.CompletionTokens,
+		CachedContentTokenCount: usage.PromptTokensDetails.CachedTokens,
+	}
+}
+
+func toolCallsOpenAI2Gemini(toolCalls []dto.ToolCall) []GeminiPart {
+	parts := make([]GeminiPart, 0, len(toolCalls))
+	for _, toolCall := range toolCalls {
+		args := make(map[string]any)
+		if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+			logging.SysError("tool call function arguments is not a map[string]any: " + toolCall.Function.Arguments)
+		}
+		parts = append(parts, GeminiPart{
+			FunctionCall: &FunctionCall{
+				FunctionName: toolCall.Function.Name,
+				Arguments:    args,
+			},
+		})
+	}
+	return parts
+}
+
+// ResponseOpenAI2Gemini 将 OpenAI 格式的非流式响应转换为 Gemini 原生格式
+func ResponseOpenAI2Gemini(response *dto.OpenAITextResponse, usage *dto.Usage) *GeminiChatResponse {
+	geminiResponse := &GeminiChatResponse{
+		Candidates:    make([]GeminiChatCandidate, 0, len(response.Choices)),
+		UsageMetadata: usageOpenAI2Gemini(usage),
+	}
+	for _, choice := range response.Choices {
+		parts := make([]GeminiPart, 0)
+		if text := choice.Message.StringContent(); text != "" {
+			parts = append(parts, GeminiPart{Text: text})
+		}
+		parts = append(parts, toolCallsOpenAI2Gemini(choice.Message.ParseToolCalls())...)
+		finishReason := finishReasonOpenAI2Gemini(choice.FinishReason)
+		geminiResponse.Candidates = append(geminiResponse.Candidates, GeminiChatCandidate{
+			Content: GeminiChatContent{
+				Role:  "model",
+				Parts: parts,
+			},
+			FinishReason: &finishReason,
+			Index:        int64(choice.Index),
+		})
+	}
+	return geminiResponse
+}
+
+// OpenAI2GeminiStreamConverter 将 OpenAI 格式的流式响应逐块转换为 Gemini 原生格式，
+// gemini 的 functionCall 不支持增量，工具调用的参数会在结束时一次性发送
+type OpenAI2GeminiStreamConverter struct {
+	toolCalls    []dto.ToolCall
+	finishReason string
+}
+
+func (s *OpenAI2GeminiStreamConverter) Convert(response *dto.ChatCompletionsStreamResponse) *GeminiChatResponse {
+	if len(response.Choices) == 0 {
+		return nil
+	}
+	choice := response.Choices[0]
+	for _, toolCall := range choice.Delta.ToolCalls {
+		index := len(s.toolCalls)
+		if toolCall.Index != nil {
+			index = *toolCall.Index
+		}
+		for len(s.toolCalls) <= index {
+			s.toolCalls = append(s.toolCalls, dto.ToolCall{})
+		}
+		if toolCall.Function.Name != "" {
+			s.toolCalls[index].Function.Name = toolCall.Function.Name
+		}
+		s.toolCalls[index].Function.Arguments += toolCall.Function.Arguments
+	}
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		s.finishReason = *choice.FinishReason
+	}
+	text := choice.Delta.GetContentString()
+	if text == "" {
+		return nil
+	}
+	return &GeminiChatResponse{
+		Candidates: []GeminiChatCandidate{{
+			Content: GeminiChatContent{
+				Role:  "model",
+				Parts: []GeminiPart{{Text: text}},
+			},
+		}},
+	}
+}
+
+// Finish 返回最后一个数据块，包含累积的工具调用、结束原因和用量
+func (s *OpenAI2GeminiStreamConverter) Finish(usage *dto.Usage) *GeminiChatResponse {
+	finishReason := finishReasonOpenAI2Gemini(s.finishReason)
+	return &GeminiChatResponse{
+		Candidates: []GeminiChatCandidate{{
+			Content: GeminiChatContent{
+				Role:  "model",
+				Parts: toolCallsOpenAI2Gemini(s.toolCalls),
+			},
+			FinishReason: &finishReason,
+		}},
+		UsageMetadata: usageOpenAI2Gemini(usage),
+	}
+}
+
+func completeGeminiNativeUsage(usage *dto.Usage, responseText string, info *relaycommon.RelayInfo) *dto.Usage {
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = info.PromptTokens
+	}
+	if usage.CompletionTokens == 0 {
+		promptTokensDetails := usage.PromptTokensDetails
+		usage, _ = service.ResponseText2Usage(responseText, info.UpstreamModelName, usage.PromptTokens)
+		usage.PromptTokensDetails = promptTokensDetails
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// GeminiNativeStreamHandler 将上游 Gemini 原生格式的流式响应原样转发给客户端
+func GeminiNativeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	usage := &dto.Usage{}
+	var responseText strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Split(bufio.ScanLines)
+	service.SetEventStreamHeaders(c)
+
+	for scanner.Scan() {
+		data := scanner.Text()
+		info.SetFirstResponseTime()
+		if _, err := c.Writer.WriteString(data + "\n"); err != nil {
+			logging.LogError(c, "send_stream_response_failed: "+err.Error())
+			break
+		}
+		data = strings.TrimSpace(data)
+		if data == "" {
+			c.Writer.Flush()
+			continue
+		}
+		if !strings.HasPrefix(data, "data:") {
+			continue
+		}
+		var geminiResponse GeminiChatResponse
+		err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(data, "data:"))), &geminiResponse)
+		if err != nil {
+			logging.LogError(c, "error unmarshalling stream response: "+err.Error())
+			continue
+		}
+		if geminiResponse.UsageMetadata.TotalTokenCount != 0 {
+			usage.PromptTokens = geminiResponse.UsageMetadata.PromptTokenCount
+			usage.CompletionTokens = geminiResponse.UsageMetadata.CandidatesTokenCount
+			usage.PromptTokensDetails.CachedTokens = geminiResponse.UsageMetadata.CachedContentTokenCount
+		}
+		for _, candidate := range geminiResponse.Candidates {
+			responseText.WriteString(geminiPartsText(&candidate.Content))
+		}
+	}
+	c.Writer.Flush()
+	resp.Body.Close()
+	return nil, completeGeminiNativeUsage(usage, responseText.String(), info)
+}
+
+// GeminiNativeHandler 将上游 Gemini 原生格式的非流式响应原样转发给客户端
+func GeminiNativeHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
+	}
+	var geminiResponse GeminiChatResponse
+	err = json.Unmarshal(responseBody, &geminiResponse)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	usage := &dto.Usage{
+		PromptTokens:     geminiResponse.UsageMetadata.PromptTokenCount,
+		CompletionTokens: geminiResponse.UsageMetadata.CandidatesTokenCount,
+	}
+	usage.PromptTokensDetails.CachedTokens = geminiResponse.UsageMetadata.CachedContentTokenCount
+	responseText := ""
+	for _, candidate := range geminiResponse.Candidates {
+		responseText += geminiPartsText(&candidate.Content)
+	}
+	usage = completeGeminiNativeUsage(usage, responseText, info)
+
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, err = c.Writer.Write(responseBody)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "write_response_body_failed", http.StatusInternalServerError), nil
+	}
+	return nil, usage
+}
+
+// ErrorOpenAI2Gemini 将错误转换为 Google API 的错误格式
+func ErrorOpenAI2Gemini(openaiErr *dto.OpenAIErrorWithStatusCode) *GeminiErrorResponse {
+	status := "INTERNAL"
+	switch openaiErr.StatusCode {
+	case http.StatusBadRequest:
+		status = "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		status = "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		status = "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		status = "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		status = "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		status = "UNAVAILABLE"
+	}
+	return &GeminiErrorResponse{
+		Error: GeminiErrorDetail{
+			Code:    openaiErr.StatusCode,
+			Message: openaiErr.Error.Message,
+			Status:  status,
+		},
+	}
+}
@@ -134,15 +134,16 @@ func CovertGemini2OpenAI(textRequest dto.GeneralOpenAIRequest) (*GeminiChatReque
 				name = val
 			}
 			content := common.StrToMap(message.StringContent())
-			functionResp := &FunctionResponse{
-				Name: name,
-				Response: GeminiFunctionResponseContent{
-					Name:    name,
-					Content: content,
-				},
+			responseContent := GeminiFunctionResponseContent{
+				Name:    name,
+				Content: content,
 			}
 			if content == nil {
-				functionResp.Response.Content = message.StringContent()
+				responseContent.Content = message.StringContent()
+			}
+			functionResp := &FunctionResponse{
+				Name:     name,
+				Response: responseContent,
 			}
 			*parts = append(*parts, GeminiPart{
 				FunctionResponse: functionResp,
@@ -508,6 +509,7 @@ func GeminiChatStreamHandler(c *gin.Context, resp *http.Response, info *relaycom
 	scanner.Split(bufio.ScanLines)
 
 	service.SetEventStreamHeaders(c)
+	service.EnableStreamKeepalive(resp.Body)
 	for scanner.Scan() {
 		data := scanner.Text()
 		info.SetFirstResponseTime()
@@ -532,6 +534,7 @@ func GeminiChatStreamHandler(c *gin.Context, resp *http.Response, info *relaycom
 		if geminiResponse.UsageMetadata.TotalTokenCount != 0 {
 			usage.PromptTokens = geminiResponse.UsageMetadata.PromptTokenCount
 			usage.CompletionTokens = geminiResponse.UsageMetadata.CandidatesTokenCount
+			usage.PromptTokensDetails.CachedTokens = geminiResponse.UsageMetadata.CachedContentTokenCount
 		}
 		err = service.ObjectData(c, response)
 		if err != nil {
@@ -543,6 +546,12 @@ func GeminiChatStreamHandler(c *gin.Context, resp *http.Response, info *relaycom
 		}
 	}
 
+	if service.IsStreamIdleTimeout(resp.Body) && !c.Writer.Written() {
+		// 尚未向客户端输出任何内容，交给重试逻辑处理，不发送用量与结束标记
+		resp.Body.Close()
+		return service.OpenAIErrorWrapper(service.ErrStreamIdleTimeout, service.StreamTimeoutErrorCode, http.StatusGatewayTimeout), nil
+	}
+
 	var response *dto.ChatCompletionsStreamResponse
 
 	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
@@ -593,6 +602,7 @@ func GeminiChatHandler(c *gin.Context, resp *http.Response, info *relaycommon.Re
 		CompletionTokens: geminiResponse.UsageMetadata.CandidatesTokenCount,
 		TotalTokens:      geminiResponse.UsageMetadata.TotalTokenCount,
 	}
+	usage.PromptTokensDetails.CachedTokens = geminiResponse.UsageMetadata.CachedContentTokenCount
 	fullTextResponse.Usage = usage
 	jsonResponse, err := json.Marshal(fullTextResponse)
 	if err != nil {
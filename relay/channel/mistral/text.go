@@ -28,13 +28,14 @@ func requestOpenAI2Mistral(request dto.GeneralOpenAIRequest) *dto.GeneralOpenAIR
 		})
 	}
 	return &dto.GeneralOpenAIRequest{
-		Model:       request.Model,
-		Stream:      request.Stream,
-		Messages:    messages,
-		Temperature: request.Temperature,
-		TopP:        request.TopP,
-		MaxTokens:   request.MaxTokens,
-		Tools:       request.Tools,
-		ToolChoice:  request.ToolChoice,
+		Model:          request.Model,
+		Stream:         request.Stream,
+		Messages:       messages,
+		Temperature:    request.Temperature,
+		TopP:           request.TopP,
+		MaxTokens:      request.MaxTokens,
+		Tools:          request.Tools,
+		ToolChoice:     request.ToolChoice,
+		ResponseFormat: request.ResponseFormat,
 	}
 }
@@ -13,6 +13,7 @@ type OllamaRequest struct {
 	Stop             any            `json:"stop,omitempty"`
 	Tools            []dto.ToolCall `json:"tools,omitempty"`
 	ResponseFormat   any            `json:"response_format,omitempty"`
+	Format           any            `json:"format,omitempty"`
 	FrequencyPenalty float64        `json:"frequency_penalty,omitempty"`
 	PresencePenalty  float64        `json:"presence_penalty,omitempty"`
 }
@@ -37,11 +37,29 @@ func requestOpenAI2Ollama(request dto.GeneralOpenAIRequest) *OllamaRequest {
 		Stop:             Stop,
 		Tools:            request.Tools,
 		ResponseFormat:   request.ResponseFormat,
+		Format:           responseFormat2Ollama(request.ResponseFormat),
 		FrequencyPenalty: request.FrequencyPenalty,
 		PresencePenalty:  request.PresencePenalty,
 	}
 }
 
+// responseFormat2Ollama 将 response_format 转换为 Ollama 的 format：json_schema 使用 schema 本身，json_object 使用 "json"
+func responseFormat2Ollama(format *dto.ResponseFormat) any {
+	if format == nil {
+		return nil
+	}
+	switch format.Type {
+	case "json_schema":
+		if schema := service.StructuredOutputSchema(format); schema != nil {
+			return schema
+		}
+		return "json"
+	case "json_object":
+		return "json"
+	}
+	return nil
+}
+
 func requestOpenAI2Embeddings(request dto.GeneralOpenAIRequest) *OllamaEmbeddingRequest {
 	return &OllamaEmbeddingRequest{
 		Model: request.Model,
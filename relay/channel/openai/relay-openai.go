@@ -4,19 +4,19 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"one-api/common"
-	"one-api/constant"
 	"one-api/dto"
 	"one-api/logging"
 	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
+	"one-api/setting"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/gin-gonic/gin"
@@ -65,25 +65,28 @@ func OaiStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 	scanner := bufio.NewScanner(resp.Body)
 	scanner.Split(bufio.ScanLines)
 
-	service.SetEventStreamHeaders(c)
-	streamingTimeout := time.Duration(constant.StreamingTimeout) * time.Second
-	if strings.HasPrefix(info.UpstreamModelName, "o1") || strings.HasPrefix(info.UpstreamModelName, "o3") {
-		// twice timeout for o1 model
-		streamingTimeout *= 2
+	// 开启后收到第一个内容块之前不输出任何内容，失败时可以重试其他渠道
+	holding := setting.StreamHoldFirstChunkEnabled
+	var heldStreamData []string
+	var holdErr *dto.OpenAIError
+	if !holding {
+		service.SetEventStreamHeaders(c)
 	}
-	ticker := time.NewTicker(streamingTimeout)
-	defer ticker.Stop()
+	// 上游空闲超时由 channel.DoApiRequest 包装的响应体处理，超时后读取返回错误，扫描随之结束；
+	// 读取与写入都在下面的协程中进行，可以在等待时发送保活注释
+	service.EnableStreamKeepalive(resp.Body)
 
 	stopChan := make(chan bool)
 	defer close(stopChan)
 	var (
 		lastStreamData string
 		mu             sync.Mutex
+		// 等待首个内容块时失败，上游后续的数据不再输出
+		abandoned bool
 	)
 	gopool.Go(func() {
 		for scanner.Scan() {
 			info.SetFirstResponseTime()
-			ticker.Reset(time.Duration(constant.StreamingTimeout) * time.Second)
 			data := scanner.Text()
 			if len(data) < 6 { // ignore blank line or wrong format
 				continue
@@ -92,9 +95,34 @@ func OaiStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 				continue
 			}
 			mu.Lock()
+			if abandoned {
+				mu.Unlock()
+				break
+			}
 			data = data[6:]
 			if !strings.HasPrefix(data, "[DONE]") {
-				if lastStreamData != "" {
+				if holding {
+					isContent, upstreamErr := checkStreamHoldChunk(data, info.RelayMode)
+					if upstreamErr != nil {
+						holdErr = upstreamErr
+						mu.Unlock()
+						break
+					}
+					if lastStreamData != "" {
+						heldStreamData = append(heldStreamData, lastStreamData)
+					}
+					if isContent {
+						holding = false
+						service.SetEventStreamHeaders(c)
+						for _, held := range heldStreamData {
+							err := sendStreamData(c, held, forceFormat)
+							if err != nil {
+								logging.LogError(c, "streaming error: "+err.Error())
+							}
+						}
+						heldStreamData = nil
+					}
+				} else if lastStreamData != "" {
 					err := sendStreamData(c, lastStreamData, forceFormat)
 					if err != nil {
 						logging.LogError(c, "streaming error: "+err.Error())
@@ -108,12 +136,25 @@ func OaiStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		common.SafeSendBool(stopChan, true)
 	})
 
-	select {
-	case <-ticker.C:
-		// 超时处理逻辑
+	<-stopChan
+	timeout := errors.Is(scanner.Err(), service.ErrStreamIdleTimeout)
+	if timeout {
 		logging.LogError(c, "streaming timeout")
-	case <-stopChan:
-		// 正常结束
+	}
+
+	mu.Lock()
+	if holding {
+		// 没有收到任何内容，客户端尚未收到响应，交给重试逻辑处理
+		abandoned = true
+		mu.Unlock()
+		resp.Body.Close()
+		return streamHoldError(holdErr, scanner.Err(), timeout), nil
+	}
+	mu.Unlock()
+	if timeout && !c.Writer.Written() {
+		// 尚未向客户端输出任何内容，交给重试逻辑处理
+		resp.Body.Close()
+		return service.OpenAIErrorWrapper(service.ErrStreamIdleTimeout, service.StreamTimeoutErrorCode, http.StatusGatewayTimeout), nil
 	}
 
 	shouldSendLastResp := true
@@ -127,6 +168,7 @@ func OaiStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		if service.ValidUsage(lastStreamResponse.Usage) {
 			containStreamUsage = true
 			usage = lastStreamResponse.Usage
+			usage.NormalizeCachedTokens()
 			if !info.ShouldIncludeUsage {
 				shouldSendLastResp = false
 			}
@@ -279,6 +321,7 @@ func OpenaiHandler(c *gin.Context, resp *http.Response, promptTokens int, model
 			TotalTokens:      promptTokens + completionTokens,
 		}
 	}
+	simpleResponse.Usage.NormalizeCachedTokens()
 	return nil, &simpleResponse.Usage
 }
 
@@ -0,0 +1,649 @@
+package openai
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/logging"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// parseResponsesInput 解析 input 字段，字符串视为一条 user 消息
+func parseResponsesInput(input json.RawMessage) ([]dto.ResponsesInputItem, error) {
+	var text string
+	if err := json.Unmarshal(input, &text); err == nil {
+		return []dto.ResponsesInputItem{{Type: "message", Role: "user", Content: input}}, nil
+	}
+	var items []dto.ResponsesInputItem
+	if err := json.Unmarshal(input, &items); err != nil {
+		return nil, fmt.Errorf("invalid input: %s", err.Error())
+	}
+	return items, nil
+}
+
+// parseResponsesContent 解析消息内容，字符串内容转换为一个 input_text
+func parseResponsesContent(content json.RawMessage) ([]dto.ResponsesInputContent, error) {
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return []dto.ResponsesInputContent{{Type: "input_text", Text: text}}, nil
+	}
+	var parts []dto.ResponsesInputContent
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return nil, fmt.Errorf("invalid message content: %s", err.Error())
+	}
+	return parts, nil
+}
+
+func responsesMessageContent(content json.RawMessage) (json.RawMessage, error) {
+	parts, err := parseResponsesContent(content)
+	if err != nil {
+		return nil, err
+	}
+	hasImage := false
+	var textBuilder strings.Builder
+	mediaContents := make([]dto.MediaContent, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case "input_text", "output_text":
+			textBuilder.WriteString(part.Text)
+			mediaContents = append(mediaContents, dto.MediaContent{
+				Type: dto.ContentTypeText,
+				Text: part.Text,
+			})
+		case "input_image":
+			// 通过 file_id 引用的图片无法转换，忽略
+			if part.ImageUrl == "" {
+				continue
+			}
+			hasImage = true
+			detail := part.Detail
+			if detail == "" {
+				detail = "auto"
+			}
+			mediaContents = append(mediaContents, dto.MediaContent{
+				Type: dto.ContentTypeImageURL,
+				ImageUrl: dto.MessageImageUrl{
+					Url:    part.ImageUrl,
+					Detail: detail,
+				},
+			})
+		}
+	}
+	if !hasImage {
+		return json.Marshal(textBuilder.String())
+	}
+	return json.Marshal(mediaContents)
+}
+
+// RequestResponses2OpenAI 将 Responses API 请求转换为 chat completions 请求，
+// 无法转换的输入项与内置工具会被忽略，是否支持模拟由 CheckResponsesEmulation 判断
+func RequestResponses2OpenAI(responsesRequest *dto.OpenAIResponsesRequest) (*dto.GeneralOpenAIRequest, error) {
+	textRequest := &dto.GeneralOpenAIRequest{
+		Model:       responsesRequest.Model,
+		Stream:      responsesRequest.Stream,
+		MaxTokens:   responsesRequest.MaxOutputTokens,
+		Temperature: responsesRequest.Temperature,
+		TopP:        responsesRequest.TopP,
+		User:        responsesRequest.User,
+	}
+	if responsesRequest.Reasoning != nil {
+		textRequest.ReasoningEffort = responsesRequest.Reasoning.Effort
+	}
+	if responsesRequest.Instructions != "" {
+		message := dto.Message{Role: "system"}
+		message.SetStringContent(responsesRequest.Instructions)
+		textRequest.Messages = append(textRequest.Messages, message)
+	}
+
+	items, err := parseResponsesInput(responsesRequest.Input)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		switch item.Type {
+		case "", "message":
+			role := item.Role
+			if role == "developer" {
+				role = "system"
+			}
+			content, err := responsesMessageContent(item.Content)
+			if err != nil {
+				return nil, err
+			}
+			textRequest.Messages = append(textRequest.Messages, dto.Message{
+				Role:    role,
+				Content: content,
+			})
+		case "function_call":
+			toolCall := dto.ToolCall{
+				ID:   item.CallId,
+				Type: "function",
+				Function: dto.FunctionCall{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			}
+			// 紧跟在 assistant 消息后的函数调用合并到同一条消息中
+			last := len(textRequest.Messages) - 1
+			if last >= 0 && textRequest.Messages[last].Role == "assistant" {
+				toolCalls := textRequest.Messages[last].ParseToolCalls()
+				textRequest.Messages[last].SetToolCalls(append(toolCalls, toolCall))
+			} else {
+				message := dto.Message{Role: "assistant"}
+				message.SetToolCalls([]dto.ToolCall{toolCall})
+				textRequest.Messages = append(textRequest.Messages, message)
+			}
+		case "function_call_output":
+			message := dto.Message{
+				Role:       "tool",
+				ToolCallId: item.CallId,
+			}
+			var output string
+			if err := json.Unmarshal(item.Output, &output); err == nil {
+				message.SetStringContent(output)
+			} else {
+				message.SetStringContent(string(item.Output))
+			}
+			textRequest.Messages = append(textRequest.Messages, message)
+		}
+	}
+
+	for _, tool := range responsesRequest.Tools {
+		if tool.Type != "function" {
+			continue
+		}
+		textRequest.Tools = append(textRequest.Tools, dto.ToolCall{
+			Type: "function",
+			Function: dto.FunctionCall{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	switch toolChoice := responsesRequest.ToolChoice.(type) {
+	case string:
+		textRequest.ToolChoice = toolChoice
+	case map[string]any:
+		if toolChoice["type"] == "function" {
+			textRequest.ToolChoice = map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name": toolChoice["name"],
+				},
+			}
+		}
+	}
+
+	if responsesRequest.Text != nil && responsesRequest.Text.Format != nil {
+		format := responsesRequest.Text.Format
+		switch format.Type {
+		case "json_object":
+			textRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+		case "json_schema":
+			textRequest.ResponseFormat = &dto.ResponseFormat{
+				Type: "json_schema",
+				JsonSchema: &dto.FormatJsonSchema{
+					Description: format.Description,
+					Name:        format.Name,
+					Schema:      format.Schema,
+					Strict:      format.Strict,
+				},
+			}
+		}
+	}
+	return textRequest, nil
+}
+
+// CheckResponsesEmulation 检查请求能否通过 chat completions 模拟，
+// previous_response_id、内置工具、文件输入等依赖 OpenAI 服务端能力的功能无法模拟
+func CheckResponsesEmulation(responsesRequest *dto.OpenAIResponsesRequest) error {
+	if responsesRequest.PreviousResponseId != "" {
+		return errors.New("previous_response_id is not supported by this channel")
+	}
+	for _, tool := range responsesRequest.Tools {
+		if tool.Type != "function" {
+			return fmt.Errorf("tool type %s is not supported by this channel", tool.Type)
+		}
+	}
+	items, err := parseResponsesInput(responsesRequest.Input)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		switch item.Type {
+		case "", "message":
+			parts, err := parseResponsesContent(item.Content)
+			if err != nil {
+				return err
+			}
+			for _, part := range parts {
+				switch part.Type {
+				case "input_text", "output_text":
+				case "input_image":
+					if part.ImageUrl == "" {
+						return errors.New("input_image without image_url is not supported by this channel")
+					}
+				default:
+					return fmt.Errorf("content type %s is not supported by this channel", part.Type)
+				}
+			}
+		case "function_call", "function_call_output":
+		default:
+			return fmt.Errorf("input item type %s is not supported by this channel", item.Type)
+		}
+	}
+	return nil
+}
+
+// responsesStatus 根据 finish_reason 返回响应状态以及未完成的原因
+func responsesStatus(finishReason string) (string, *dto.ResponsesIncompleteDetails) {
+	switch finishReason {
+	case "length":
+		return "incomplete", &dto.ResponsesIncompleteDetails{Reason: "max_output_tokens"}
+	case "content_filter":
+		return "incomplete", &dto.ResponsesIncompleteDetails{Reason: "content_filter"}
+	}
+	return "completed", nil
+}
+
+func usageOpenAI2Responses(usage *dto.Usage) *dto.ResponsesUsage {
+	if usage == nil {
+		return nil
+	}
+	return &dto.ResponsesUsage{
+		InputTokens: usage.PromptTokens,
+		InputTokensDetails: dto.ResponsesInputTokensDetails{
+			CachedTokens: usage.PromptTokensDetails.CachedTokens,
+		},
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  usage.PromptTokens + usage.CompletionTokens,
+	}
+}
+
+func ResponseOpenAI2Responses(response *dto.OpenAITextResponse, usage *dto.Usage) *dto.OpenAIResponsesResponse {
+	responsesResponse := &dto.OpenAIResponsesResponse{
+		Id:        fmt.Sprintf("resp_%s", common.GetUUID()),
+		Object:    "response",
+		CreatedAt: response.Created,
+		Model:     response.Model,
+		Output:    make([]dto.ResponsesOutputItem, 0),
+		Usage:     usageOpenAI2Responses(usage),
+	}
+	if responsesResponse.CreatedAt == 0 {
+		responsesResponse.CreatedAt = common.GetTimestamp()
+	}
+	finishReason := ""
+	if len(response.Choices) > 0 {
+		finishReason = response.Choices[0].FinishReason
+	}
+	status, incompleteDetails := responsesStatus(finishReason)
+	responsesResponse.Status = status
+	responsesResponse.IncompleteDetails = incompleteDetails
+	if len(response.Choices) > 0 {
+		message := response.Choices[0].Message
+		if text := message.StringContent(); text != "" {
+			responsesResponse.Output = append(responsesResponse.Output, dto.ResponsesOutputItem{
+				Type:   "message",
+				Id:     fmt.Sprintf("msg_%s", common.GetUUID()),
+				Status: status,
+				Role:   "assistant",
+				Content: []dto.ResponsesOutputContent{
+					{
+						Type:        "output_text",
+						Text:        text,
+						Annotations: []any{},
+					},
+				},
+			})
+		}
+		for _, toolCall := range message.ParseToolCalls() {
+			responsesResponse.Output = append(responsesResponse.Output, dto.ResponsesOutputItem{
+				Type:      "function_call",
+				Id:        fmt.Sprintf("fc_%s", common.GetUUID()),
+				Status:    status,
+				CallId:    toolCall.ID,
+				Name:      toolCall.Function.Name,
+				Arguments: toolCall.Function.Arguments,
+			})
+		}
+	}
+	return responsesResponse
+}
+
+// OpenAI2ResponsesStreamConverter 将 chat completions 流式响应转换为 Responses API 的流式事件
+type OpenAI2ResponsesStreamConverter struct {
+	Id        string
+	Model     string
+	CreatedAt int64
+
+	started      bool
+	sequence     int
+	output       []dto.ResponsesOutputItem
+	textOpen     bool
+	textIndex    int
+	text         strings.Builder
+	toolIndexes  map[int]int // chat 流中工具调用的 index -> output 中的位置
+	finishReason string
+}
+
+func (s *OpenAI2ResponsesStreamConverter) event(event dto.ResponsesStreamEvent) dto.ResponsesStreamEvent {
+	event.SequenceNumber = s.sequence
+	s.sequence++
+	return event
+}
+
+func (s *OpenAI2ResponsesStreamConverter) response(status string) *dto.OpenAIResponsesResponse {
+	return &dto.OpenAIResponsesResponse{
+		Id:        s.Id,
+		Object:    "response",
+		CreatedAt: s.CreatedAt,
+		Status:    status,
+		Model:     s.Model,
+		Output:    s.output,
+	}
+}
+
+func (s *OpenAI2ResponsesStreamConverter) start() []dto.ResponsesStreamEvent {
+	s.started = true
+	if s.Id == "" {
+		s.Id = fmt.Sprintf("resp_%s", common.GetUUID())
+	}
+	if s.CreatedAt == 0 {
+		s.CreatedAt = common.GetTimestamp()
+	}
+	s.output = make([]dto.ResponsesOutputItem, 0)
+	s.toolIndexes = make(map[int]int)
+	return []dto.ResponsesStreamEvent{
+		s.event(dto.ResponsesStreamEvent{Type: "response.created", Response: s.response("in_progress")}),
+		s.event(dto.ResponsesStreamEvent{Type: "response.in_progress", Response: s.response("in_progress")}),
+	}
+}
+
+func (s *OpenAI2ResponsesStreamConverter) openText() []dto.ResponsesStreamEvent {
+	s.textOpen = true
+	s.textIndex = len(s.output)
+	s.output = append(s.output, dto.ResponsesOutputItem{
+		Type:   "message",
+		Id:     fmt.Sprintf("msg_%s", common.GetUUID()),
+		Status: "in_progress",
+		Role:   "assistant",
+	})
+	item := s.output[s.textIndex]
+	return []dto.ResponsesStreamEvent{
+		s.event(dto.ResponsesStreamEvent{
+			Type:        "response.output_item.added",
+			OutputIndex: intPtr(s.textIndex),
+			Item:        &item,
+		}),
+		s.event(dto.ResponsesStreamEvent{
+			Type:         "response.content_part.added",
+			ItemId:       item.Id,
+			OutputIndex:  intPtr(s.textIndex),
+			ContentIndex: intPtr(0),
+			Part: &dto.ResponsesOutputContent{
+				Type:        "output_text",
+				Annotations: []any{},
+			},
+		}),
+	}
+}
+
+func (s *OpenAI2ResponsesStreamConverter) closeText(status string) []dto.ResponsesStreamEvent {
+	if !s.textOpen {
+		return nil
+	}
+	s.textOpen = false
+	part := dto.ResponsesOutputContent{
+		Type:        "output_text",
+		Text:        s.text.String(),
+		Annotations: []any{},
+	}
+	s.text.Reset()
+	item := &s.output[s.textIndex]
+	item.Status = status
+	item.Content = []dto.ResponsesOutputContent{part}
+	doneItem := *item
+	return []dto.ResponsesStreamEvent{
+		s.event(dto.ResponsesStreamEvent{
+			Type:         "response.output_text.done",
+			ItemId:       item.Id,
+			OutputIndex:  intPtr(s.textIndex),
+			ContentIndex: intPtr(0),
+			Text:         part.Text,
+		}),
+		s.event(dto.ResponsesStreamEvent{
+			Type:         "response.content_part.done",
+			ItemId:       item.Id,
+			OutputIndex:  intPtr(s.textIndex),
+			ContentIndex: intPtr(0),
+			Part:         &part,
+		}),
+		s.event(dto.ResponsesStreamEvent{
+			Type:        "response.output_item.done",
+			OutputIndex: intPtr(s.textIndex),
+			Item:        &doneItem,
+		}),
+	}
+}
+
+func (s *OpenAI2ResponsesStreamConverter) Convert(response *dto.ChatCompletionsStreamResponse) []dto.ResponsesStreamEvent {
+	var events []dto.ResponsesStreamEvent
+	if !s.started {
+		if s.Model == "" {
+			s.Model = response.Model
+		}
+		events = append(events, s.start()...)
+	}
+	for _, choice := range response.Choices {
+		// 只转换第一个 choice
+		if choice.Index != 0 {
+			continue
+		}
+		if text := choice.Delta.GetContentString(); text != "" {
+			if !s.textOpen {
+				events = append(events, s.openText()...)
+			}
+			s.text.WriteString(text)
+			events = append(events, s.event(dto.ResponsesStreamEvent{
+				Type:         "response.output_text.delta",
+				ItemId:       s.output[s.textIndex].Id,
+				OutputIndex:  intPtr(s.textIndex),
+				ContentIndex: intPtr(0),
+				Delta:        text,
+			}))
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			index := 0
+			if toolCall.Index != nil {
+				index = *toolCall.Index
+			}
+			outputIndex, ok := s.toolIndexes[index]
+			if !ok {
+				events = append(events, s.closeText("completed")...)
+				callId := toolCall.ID
+				if callId == "" {
+					callId = fmt.Sprintf("call_%s", common.GetUUID())
+				}
+				outputIndex = len(s.output)
+				s.toolIndexes[index] = outputIndex
+				s.output = append(s.output, dto.ResponsesOutputItem{
+					Type:   "function_call",
+					Id:     fmt.Sprintf("fc_%s", common.GetUUID()),
+					Status: "in_progress",
+					CallId: callId,
+					Name:   toolCall.Function.Name,
+				})
+				item := s.output[outputIndex]
+				events = append(events, s.event(dto.ResponsesStreamEvent{
+					Type:        "response.output_item.added",
+					OutputIndex: intPtr(outputIndex),
+					Item:        &item,
+				}))
+			}
+			if arguments := toolCall.Function.Arguments; arguments != "" {
+				s.output[outputIndex].Arguments += arguments
+				events = append(events, s.event(dto.ResponsesStreamEvent{
+					Type:        "response.function_call_arguments.delta",
+					ItemId:      s.output[outputIndex].Id,
+					OutputIndex: intPtr(outputIndex),
+					Delta:       arguments,
+				}))
+			}
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.finishReason = *choice.FinishReason
+		}
+	}
+	return events
+}
+
+// Finish 结束所有未完成的输出项，并发送带有用量的 response.completed 事件
+func (s *OpenAI2ResponsesStreamConverter) Finish(usage *dto.Usage) []dto.ResponsesStreamEvent {
+	var events []dto.ResponsesStreamEvent
+	if !s.started {
+		events = append(events, s.start()...)
+	}
+	status, incompleteDetails := responsesStatus(s.finishReason)
+	events = append(events, s.closeText(status)...)
+	for outputIndex := range s.output {
+		item := &s.output[outputIndex]
+		if item.Type != "function_call" || item.Status != "in_progress" {
+			continue
+		}
+		item.Status = status
+		doneItem := *item
+		events = append(events,
+			s.event(dto.ResponsesStreamEvent{
+				Type:        "response.function_call_arguments.done",
+				ItemId:      item.Id,
+				OutputIndex: intPtr(outputIndex),
+				Arguments:   item.Arguments,
+			}),
+			s.event(dto.ResponsesStreamEvent{
+				Type:        "response.output_item.done",
+				OutputIndex: intPtr(outputIndex),
+				Item:        &doneItem,
+			}),
+		)
+	}
+	response := s.response(status)
+	response.IncompleteDetails = incompleteDetails
+	response.Usage = usageOpenAI2Responses(usage)
+	eventType := "response.completed"
+	if status == "incomplete" {
+		eventType = "response.incomplete"
+	}
+	events = append(events, s.event(dto.ResponsesStreamEvent{Type: eventType, Response: response}))
+	return events
+}
+
+// ResponsesStreamHandler 将上游 Responses API 的流式事件原样转发给客户端，用量取自最终的 response 对象
+func ResponsesStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	var usage *dto.Usage
+	var responseText strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	// response.completed 事件包含完整的响应，可能超过默认的 64KB
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	scanner.Split(bufio.ScanLines)
+	service.SetEventStreamHeaders(c)
+
+	for scanner.Scan() {
+		data := scanner.Text()
+		info.SetFirstResponseTime()
+		if _, err := c.Writer.WriteString(data + "\n"); err != nil {
+			logging.LogError(c, "send_stream_response_failed: "+err.Error())
+			break
+		}
+		if data == "" {
+			c.Writer.Flush()
+			continue
+		}
+		if !strings.HasPrefix(data, "data:") {
+			continue
+		}
+		var event dto.ResponsesStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(data, "data:"))), &event); err != nil {
+			continue
+		}
+		switch event.Type {
+		case "response.output_text.delta":
+			responseText.WriteString(event.Delta)
+		case "response.completed", "response.incomplete", "response.failed":
+			if event.Response != nil && event.Response.Usage != nil {
+				usage = event.Response.Usage.ToUsage()
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logging.LogError(c, "read_stream_response_failed: "+err.Error())
+	}
+	c.Writer.Flush()
+	resp.Body.Close()
+	if usage == nil || usage.TotalTokens == 0 {
+		usage, _ = service.ResponseText2Usage(responseText.String(), info.UpstreamModelName, info.PromptTokens)
+	}
+	return nil, usage
+}
+
+// ResponsesHandler 将上游 Responses API 的非流式响应原样转发给客户端
+func ResponsesHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
+	}
+	var responsesResponse dto.OpenAIResponsesResponse
+	err = json.Unmarshal(responseBody, &responsesResponse)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	if responsesResponse.Error != nil {
+		return &dto.OpenAIErrorWithStatusCode{
+			Error: dto.OpenAIError{
+				Message: responsesResponse.Error.Message,
+				Type:    "upstream_error",
+				Code:    responsesResponse.Error.Code,
+			},
+			StatusCode: http.StatusInternalServerError,
+		}, nil
+	}
+
+	for k, v := range resp.Header {
+		c.Writer.Header().Set(k, v[0])
+	}
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, err = c.Writer.Write(responseBody)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "write_response_body_failed", http.StatusInternalServerError), nil
+	}
+
+	if responsesResponse.Usage != nil && responsesResponse.Usage.TotalTokens != 0 {
+		return nil, responsesResponse.Usage.ToUsage()
+	}
+	var responseText strings.Builder
+	for _, item := range responsesResponse.Output {
+		for _, content := range item.Content {
+			responseText.WriteString(content.Text)
+		}
+		responseText.WriteString(item.Arguments)
+	}
+	usage, _ := service.ResponseText2Usage(responseText.String(), info.UpstreamModelName, info.PromptTokens)
+	return nil, usage
+}
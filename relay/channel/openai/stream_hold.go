@@ -0,0 +1,65 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+)
+
+// streamHoldChunk 判断首个内容块时只需要的字段，兼容上游返回的推理内容
+type streamHoldChunk struct {
+	Error   *dto.OpenAIError `json:"error"`
+	Choices []struct {
+		Text  string `json:"text"`
+		Delta struct {
+			Content          string            `json:"content"`
+			ReasoningContent string            `json:"reasoning_content"`
+			Reasoning        string            `json:"reasoning"`
+			ToolCalls        []json.RawMessage `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// checkStreamHoldChunk 返回数据块是否包含内容（文本、推理、工具调用或结束原因），以及上游在流中返回的错误
+func checkStreamHoldChunk(data string, relayMode int) (bool, *dto.OpenAIError) {
+	if relayMode != relayconstant.RelayModeChatCompletions && relayMode != relayconstant.RelayModeCompletions {
+		return true, nil
+	}
+	var chunk streamHoldChunk
+	if err := json.Unmarshal(common.StringToByteSlice(data), &chunk); err != nil {
+		// 无法解析的数据块原样转发，不再等待
+		return true, nil
+	}
+	if chunk.Error != nil && (chunk.Error.Message != "" || chunk.Error.Type != "" || chunk.Error.Code != nil) {
+		return false, chunk.Error
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Text != "" || choice.Delta.Content != "" || choice.Delta.ReasoningContent != "" ||
+			choice.Delta.Reasoning != "" || len(choice.Delta.ToolCalls) > 0 || choice.FinishReason != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// streamHoldError 首个内容块之前流式请求失败时返回的错误，非超时错误会按 5xx 交给重试逻辑
+func streamHoldError(upstreamErr *dto.OpenAIError, scanErr error, timeout bool) *dto.OpenAIErrorWithStatusCode {
+	switch {
+	case upstreamErr != nil:
+		return &dto.OpenAIErrorWithStatusCode{
+			Error:      *upstreamErr,
+			StatusCode: http.StatusBadGateway,
+		}
+	case timeout:
+		// 首包前超时时客户端还没有收到任何内容，使用可重试的错误码以便换渠道重试
+		return service.OpenAIErrorWrapper(errors.New("streaming timeout before first chunk"), service.StreamTimeoutErrorCode, http.StatusGatewayTimeout)
+	case scanErr != nil:
+		return service.OpenAIErrorWrapper(scanErr, "read_stream_failed", http.StatusBadGateway)
+	}
+	return service.OpenAIErrorWrapper(errors.New("empty stream response"), "empty_response", http.StatusBadGateway)
+}
@@ -1,8 +1,15 @@
 package perplexity
 
-import "one-api/dto"
+import (
+	"one-api/dto"
+	"one-api/service"
+)
 
 func requestOpenAI2Perplexity(request dto.GeneralOpenAIRequest) *dto.GeneralOpenAIRequest {
+	if service.StructuredOutputSchema(request.ResponseFormat) == nil {
+		// perplexity 只支持 json_schema，json_object 通过系统提示词模拟
+		service.ApplyStructuredOutputPrompt(&request)
+	}
 	messages := make([]dto.Message, 0, len(request.Messages))
 	for _, message := range request.Messages {
 		messages = append(messages, dto.Message{
@@ -11,11 +18,12 @@ func requestOpenAI2Perplexity(request dto.GeneralOpenAIRequest) *dto.GeneralOpen
 		})
 	}
 	return &dto.GeneralOpenAIRequest{
-		Model:       request.Model,
-		Stream:      request.Stream,
-		Messages:    messages,
-		Temperature: request.Temperature,
-		TopP:        request.TopP,
-		MaxTokens:   request.MaxTokens,
+		Model:          request.Model,
+		Stream:         request.Stream,
+		Messages:       messages,
+		Temperature:    request.Temperature,
+		TopP:           request.TopP,
+		MaxTokens:      request.MaxTokens,
+		ResponseFormat: request.ResponseFormat,
 	}
 }
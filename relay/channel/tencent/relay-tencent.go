@@ -24,6 +24,8 @@ import (
 // https://cloud.tencent.com/document/product/1729/97732
 
 func requestOpenAI2Tencent(a *Adaptor, request dto.GeneralOpenAIRequest) *TencentChatRequest {
+	// 上游不支持 response_format，通过系统提示词模拟
+	service.ApplyStructuredOutputPrompt(&request)
 	messages := make([]*TencentMessage, 0, len(request.Messages))
 	for i := 0; i < len(request.Messages); i++ {
 		message := request.Messages[i]
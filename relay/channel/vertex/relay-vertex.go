@@ -1,6 +1,9 @@
 package vertex
 
-import "one-api/common"
+import (
+	"encoding/json"
+	"one-api/common"
+)
 
 func GetModelRegion(other string, localModelName string) string {
 	// if other is json string
@@ -14,3 +17,9 @@ func GetModelRegion(other string, localModelName string) string {
 	}
 	return other
 }
+
+// SetupClaudeNativeRequestBody 将 Anthropic Messages 请求体调整为 rawPredict 接口的格式，模型由 url 指定
+func SetupClaudeNativeRequestBody(requestBody map[string]json.RawMessage) {
+	delete(requestBody, "model")
+	requestBody["anthropic_version"] = json.RawMessage(`"` + anthropicVersion + `"`)
+}
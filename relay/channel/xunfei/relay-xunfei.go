@@ -24,6 +24,8 @@ import (
 // https://www.xfyun.cn/doc/spark/Web.html
 
 func requestOpenAI2Xunfei(request dto.GeneralOpenAIRequest, xunfeiAppId string, domain string) *XunfeiChatRequest {
+	// 上游不支持 response_format，通过系统提示词模拟
+	service.ApplyStructuredOutputPrompt(&request)
 	messages := make([]XunfeiMessage, 0, len(request.Messages))
 	shouldCovertSystemMessage := !strings.HasSuffix(request.Model, "3.5")
 	for _, message := range request.Messages {
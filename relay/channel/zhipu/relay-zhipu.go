@@ -73,6 +73,8 @@ func getZhipuToken(apikey string) string {
 }
 
 func requestOpenAI2Zhipu(request dto.GeneralOpenAIRequest) *ZhipuRequest {
+	// 上游不支持 response_format，通过系统提示词模拟
+	service.ApplyStructuredOutputPrompt(&request)
 	messages := make([]ZhipuMessage, 0, len(request.Messages))
 	for _, message := range request.Messages {
 		if message.Role == "system" {
@@ -0,0 +1,379 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ParamPolicy 渠道的请求参数策略，配置在渠道 Setting 的 param_policy 中，作用于适配器转换后发送给上游的请求体，例如
+//
+//	{"param_policy": {
+//	  "override": {"temperature": 1},
+//	  "defaults": {"top_p": 0.9},
+//	  "clamp": {"max_tokens": {"max": 8192}, "temperature": {"min": 0, "max": 1}},
+//	  "delete": ["seed", "reasoning_effort"],
+//	  "patch": [{"op": "add", "path": "/metadata/source", "value": "one-api"}],
+//	  "support_stream_options": false
+//	}}
+//
+// override、defaults、clamp、delete 的字段名使用 . 访问嵌套字段，例如 generationConfig.maxOutputTokens；
+// 按 delete、defaults、override、clamp 的顺序处理，最后按 JSON Patch (RFC 6902) 修改请求体。
+// support_stream_options 未设置时按渠道类型判断上游是否支持 stream_options
+type ParamPolicy struct {
+	Override             map[string]any        `json:"override,omitempty"`
+	Defaults             map[string]any        `json:"defaults,omitempty"`
+	Clamp                map[string]ParamRange `json:"clamp,omitempty"`
+	Delete               []string              `json:"delete,omitempty"`
+	Patch                []ParamPatchOperation `json:"patch,omitempty"`
+	SupportStreamOptions *bool                 `json:"support_stream_options,omitempty"`
+}
+
+// ParamRange 数值参数的取值范围，超出时修正为边界值
+type ParamRange struct {
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// ParamPatchOperation JSON Patch 操作，支持 add、remove、replace、move、copy、test
+type ParamPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// GetParamPolicy 从渠道 Setting 中解析参数策略，没有配置时返回 nil
+func GetParamPolicy(channelSetting map[string]interface{}) (*ParamPolicy, error) {
+	raw, ok := channelSetting["param_policy"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	policy := &ParamPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("invalid param_policy: %s", err.Error())
+	}
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (policy *ParamPolicy) validate() error {
+	for field, paramRange := range policy.Clamp {
+		if paramRange.Min != nil && paramRange.Max != nil && *paramRange.Min > *paramRange.Max {
+			return fmt.Errorf("invalid param_policy: clamp range of %s is empty", field)
+		}
+	}
+	for _, operation := range policy.Patch {
+		switch operation.Op {
+		case "add", "replace", "test":
+			if len(operation.Value) == 0 {
+				return fmt.Errorf("invalid param_policy: %s operation on %s requires value", operation.Op, operation.Path)
+			}
+		case "move", "copy":
+			if _, err := parseJSONPointer(operation.From); err != nil {
+				return fmt.Errorf("invalid param_policy: %s", err.Error())
+			}
+		case "remove":
+		default:
+			return fmt.Errorf("invalid param_policy: unsupported patch operation %s", operation.Op)
+		}
+		if _, err := parseJSONPointer(operation.Path); err != nil {
+			return fmt.Errorf("invalid param_policy: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// DeletesField 策略是否删除该顶层字段
+func (policy *ParamPolicy) DeletesField(field string) bool {
+	if policy == nil {
+		return false
+	}
+	for _, name := range policy.Delete {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply 按策略修改 JSON 请求体，请求体不是 JSON 对象时返回错误
+func (policy *ParamPolicy) Apply(jsonData []byte) ([]byte, error) {
+	if policy == nil {
+		return jsonData, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+	var body any
+	if err := decoder.Decode(&body); err != nil {
+		return nil, err
+	}
+	if _, ok := body.(map[string]any); !ok {
+		return nil, errors.New("request body is not a json object")
+	}
+	for _, field := range policy.Delete {
+		deleteParam(body, strings.Split(field, "."))
+	}
+	for field, value := range policy.Defaults {
+		if _, ok := getParam(body, strings.Split(field, ".")); !ok {
+			setParam(body, strings.Split(field, "."), value)
+		}
+	}
+	for field, value := range policy.Override {
+		setParam(body, strings.Split(field, "."), value)
+	}
+	for field, paramRange := range policy.Clamp {
+		clampParam(body, strings.Split(field, "."), paramRange)
+	}
+	var err error
+	for _, operation := range policy.Patch {
+		body, err = applyPatchOperation(body, operation)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(body)
+}
+
+func getParam(body any, path []string) (any, bool) {
+	current := body
+	for _, key := range path {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = object[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setParam 设置字段的值，中间不存在的对象自动创建
+func setParam(body any, path []string, value any) {
+	object, ok := body.(map[string]any)
+	if !ok {
+		return
+	}
+	for _, key := range path[:len(path)-1] {
+		next, ok := object[key].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			object[key] = next
+		}
+		object = next
+	}
+	object[path[len(path)-1]] = value
+}
+
+func deleteParam(body any, path []string) {
+	parent, ok := getParam(body, path[:len(path)-1])
+	if !ok {
+		return
+	}
+	if object, ok := parent.(map[string]any); ok {
+		delete(object, path[len(path)-1])
+	}
+}
+
+func clampParam(body any, path []string, paramRange ParamRange) {
+	value, ok := getParam(body, path)
+	if !ok {
+		return
+	}
+	var number float64
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return
+		}
+		number = f
+	case float64:
+		number = v
+	default:
+		return
+	}
+	if paramRange.Min != nil && number < *paramRange.Min {
+		setParam(body, path, json.Number(strconv.FormatFloat(*paramRange.Min, 'f', -1, 64)))
+	} else if paramRange.Max != nil && number > *paramRange.Max {
+		setParam(body, path, json.Number(strconv.FormatFloat(*paramRange.Max, 'f', -1, 64)))
+	}
+}
+
+// parseJSONPointer 解析 JSON Pointer (RFC 6901)
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid json pointer %s", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func applyPatchOperation(body any, operation ParamPatchOperation) (any, error) {
+	path, err := parseJSONPointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch operation.Op {
+	case "add", "replace", "test":
+		var value any
+		decoder := json.NewDecoder(bytes.NewReader(operation.Value))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		if operation.Op == "test" {
+			current, err := pointerGet(body, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, fmt.Errorf("patch test failed at %s", operation.Path)
+			}
+			return body, nil
+		}
+		if operation.Op == "replace" {
+			if _, err := pointerGet(body, path); err != nil {
+				return nil, err
+			}
+		}
+		return pointerAdd(body, path, value, operation.Op == "replace")
+	case "remove":
+		body, _, err = pointerRemove(body, path)
+		return body, err
+	case "move", "copy":
+		from, err := parseJSONPointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+		var value any
+		if operation.Op == "move" {
+			body, value, err = pointerRemove(body, from)
+		} else {
+			value, err = pointerGet(body, from)
+			value = deepCopyParam(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(body, path, value, false)
+	}
+	return nil, fmt.Errorf("unsupported patch operation %s", operation.Op)
+}
+
+func pointerGet(body any, path []string) (any, error) {
+	current := body
+	for i, token := range path {
+		switch container := current.(type) {
+		case map[string]any:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("path /%s not found", strings.Join(path[:i+1], "/"))
+			}
+			current = value
+		case []any:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(container) {
+				return nil, fmt.Errorf("path /%s not found", strings.Join(path[:i+1], "/"))
+			}
+			current = container[index]
+		default:
+			return nil, fmt.Errorf("path /%s not found", strings.Join(path[:i+1], "/"))
+		}
+	}
+	return current, nil
+}
+
+// pointerAdd 在路径上添加值，数组中间插入时后面的元素后移，"-" 表示追加到数组末尾；
+// replace 为 true 时替换数组中的元素而不是插入
+func pointerAdd(body any, path []string, value any, replace bool) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parentPath, last := path[:len(path)-1], path[len(path)-1]
+	parent, err := pointerGet(body, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	switch container := parent.(type) {
+	case map[string]any:
+		container[last] = value
+		return body, nil
+	case []any:
+		index := len(container)
+		if last != "-" {
+			index, err = strconv.Atoi(last)
+			if err != nil || index < 0 || index > len(container) || (replace && index == len(container)) {
+				return nil, fmt.Errorf("invalid array index %s", last)
+			}
+		}
+		if replace {
+			container[index] = value
+			return body, nil
+		}
+		container = append(container, nil)
+		copy(container[index+1:], container[index:])
+		container[index] = value
+		return pointerAdd(body, parentPath, container, true)
+	}
+	return nil, fmt.Errorf("path %s is not a container", "/"+strings.Join(parentPath, "/"))
+}
+
+func pointerRemove(body any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("cannot remove the whole request body")
+	}
+	value, err := pointerGet(body, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	parentPath, last := path[:len(path)-1], path[len(path)-1]
+	parent, _ := pointerGet(body, parentPath)
+	switch container := parent.(type) {
+	case map[string]any:
+		delete(container, last)
+		return body, value, nil
+	case []any:
+		index, _ := strconv.Atoi(last)
+		container = append(container[:index], container[index+1:]...)
+		body, err = pointerAdd(body, parentPath, container, true)
+		return body, value, err
+	}
+	return nil, nil, fmt.Errorf("path /%s not found", strings.Join(path, "/"))
+}
+
+func deepCopyParam(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(v))
+		for key, item := range v {
+			copied[key] = deepCopyParam(item)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(v))
+		for i, item := range v {
+			copied[i] = deepCopyParam(item)
+		}
+		return copied
+	}
+	return value
+}
@@ -1,9 +1,11 @@
 package common
 
 import (
+	"fmt"
 	"one-api/common"
 	"one-api/constant"
 	"one-api/dto"
+	"one-api/logging"
 	relayconstant "one-api/relay/constant"
 	"strings"
 	"time"
@@ -15,6 +17,7 @@ import (
 type RelayInfo struct {
 	ChannelType          int
 	ChannelId            int
+	ChannelKeyId         int
 	TokenId              int
 	TokenKey             string
 	UserId               int
@@ -46,6 +49,9 @@ type RelayInfo struct {
 	IsFirstRequest       bool
 	AudioUsage           bool
 	ChannelSetting       map[string]interface{}
+	ParamPolicy          *ParamPolicy
+	BatchId              string
+	ResponseCacheHit     bool
 }
 
 func GenRelayInfoWs(c *gin.Context, ws *websocket.Conn) *RelayInfo {
@@ -78,6 +84,7 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 		RequestURLPath:    c.Request.URL.String(),
 		ChannelType:       channelType,
 		ChannelId:         channelId,
+		ChannelKeyId:      c.GetInt("channel_key_id"),
 		TokenId:           tokenId,
 		TokenKey:          tokenKey,
 		UserId:            userId,
@@ -92,6 +99,7 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 		ApiKey:            strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer "),
 		Organization:      c.GetString("channel_organization"),
 		ChannelSetting:    channelSetting,
+		BatchId:           c.GetString(constant.ContextKeyBatchId),
 	}
 	if strings.HasPrefix(c.Request.URL.Path, "/pg") {
 		info.IsPlayground = true
@@ -107,14 +115,42 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 	if info.ChannelType == common.ChannelTypeVertexAi {
 		info.ApiVersion = c.GetString("region")
 	}
-	if info.ChannelType == common.ChannelTypeOpenAI || info.ChannelType == common.ChannelTypeAnthropic ||
-		info.ChannelType == common.ChannelTypeAws || info.ChannelType == common.ChannelTypeGemini ||
-		info.ChannelType == common.ChannelCloudflare || info.ChannelType == common.ChannelTypeAzure {
-		info.SupportStreamOptions = true
+	paramPolicy, err := GetParamPolicy(channelSetting)
+	if err != nil {
+		logging.SysError(fmt.Sprintf("channel #%d: %s", channelId, err.Error()))
 	}
+	info.ParamPolicy = paramPolicy
+	info.SupportStreamOptions = supportStreamOptions(channelType, paramPolicy)
 	return info
 }
 
+// supportStreamOptions 上游是否支持 stream_options，渠道的参数策略可以覆盖按渠道类型的默认判断
+func supportStreamOptions(channelType int, paramPolicy *ParamPolicy) bool {
+	if paramPolicy != nil {
+		if paramPolicy.SupportStreamOptions != nil {
+			return *paramPolicy.SupportStreamOptions
+		}
+		if paramPolicy.DeletesField("stream_options") {
+			return false
+		}
+	}
+	switch channelType {
+	case common.ChannelTypeOpenAI, common.ChannelTypeAnthropic, common.ChannelTypeAws, common.ChannelTypeGemini,
+		common.ChannelCloudflare, common.ChannelTypeAzure:
+		return true
+	}
+	return false
+}
+
+// ApplyParamPolicy 按渠道的参数策略修改适配器转换后的 JSON 请求体。
+// 策略在加载时已校验，出错说明请求体不满足策略（如 test 操作失败），调用方按请求错误处理
+func (info *RelayInfo) ApplyParamPolicy(jsonData []byte) ([]byte, error) {
+	if info.ParamPolicy == nil {
+		return jsonData, nil
+	}
+	return info.ParamPolicy.Apply(jsonData)
+}
+
 func (info *RelayInfo) SetPromptTokens(promptTokens int) {
 	info.PromptTokens = promptTokens
 }
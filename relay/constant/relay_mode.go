@@ -40,6 +40,15 @@ const (
 	RelayModeRerank
 
 	RelayModeRealtime
+
+	RelayModeImagesEdits
+	RelayModeImagesVariations
+
+	RelayModeClaudeMessages
+
+	RelayModeGeminiGenerateContent
+
+	RelayModeResponses
 )
 
 func Path2RelayMode(path string) int {
@@ -56,6 +65,10 @@ func Path2RelayMode(path string) int {
 		relayMode = RelayModeModerations
 	} else if strings.HasPrefix(path, "/v1/images/generations") {
 		relayMode = RelayModeImagesGenerations
+	} else if strings.HasPrefix(path, "/v1/images/edits") {
+		relayMode = RelayModeImagesEdits
+	} else if strings.HasPrefix(path, "/v1/images/variations") {
+		relayMode = RelayModeImagesVariations
 	} else if strings.HasPrefix(path, "/v1/edits") {
 		relayMode = RelayModeEdits
 	} else if strings.HasPrefix(path, "/v1/audio/speech") {
@@ -68,6 +81,12 @@ func Path2RelayMode(path string) int {
 		relayMode = RelayModeRerank
 	} else if strings.HasPrefix(path, "/v1/realtime") {
 		relayMode = RelayModeRealtime
+	} else if strings.HasPrefix(path, "/v1/messages") {
+		relayMode = RelayModeClaudeMessages
+	} else if strings.HasPrefix(path, "/v1beta/models/") {
+		relayMode = RelayModeGeminiGenerateContent
+	} else if strings.HasPrefix(path, "/v1/responses") {
+		relayMode = RelayModeResponses
 	}
 	return relayMode
 }
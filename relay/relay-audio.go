@@ -100,8 +100,8 @@ func AudioHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 		if err != nil {
 			return service.OpenAIErrorWrapper(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
 		}
-		if modelMap[audioRequest.Model] != "" {
-			audioRequest.Model = modelMap[audioRequest.Model]
+		if mapped := common.MapModelName(modelMap, audioRequest.Model); mapped != audioRequest.Model {
+			audioRequest.Model = mapped
 		}
 	}
 	relayInfo.UpstreamModelName = audioRequest.Model
@@ -0,0 +1,184 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"one-api/logging")
+
+// responseCacheWriter 在写给客户端的同时保留一份响应内容，用于写入响应缓存
+type responseCacheWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseCacheWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseCacheWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// saveResponseCache 缓存写给客户端的响应，流式响应合并为非流式的 chat completions 响应后再缓存
+func saveResponseCache(info *relaycommon.RelayInfo, key string, body []byte, usage *dto.Usage) {
+	if !info.IsStream {
+		if json.Valid(body) {
+			service.SetResponseCache(key, body)
+		}
+		return
+	}
+	response, ok := assembleStreamResponse(body, usage)
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	service.SetResponseCache(key, data)
+}
+
+// assembleStreamResponse 将 chat completions 流式响应合并为非流式响应，只支持单个 choice，流不完整时不缓存
+func assembleStreamResponse(body []byte, usage *dto.Usage) (*dto.OpenAITextResponse, bool) {
+	if usage == nil {
+		return nil, false
+	}
+	response := &dto.OpenAITextResponse{
+		Object: "chat.completion",
+		Usage:  *usage,
+	}
+	var content strings.Builder
+	var toolCalls []dto.ToolCall
+	finishReason := ""
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			continue
+		}
+		var streamResponse dto.ChatCompletionsStreamResponse
+		if err := json.Unmarshal([]byte(data), &streamResponse); err != nil {
+			return nil, false
+		}
+		if response.Id == "" {
+			response.Id = streamResponse.Id
+			response.Model = streamResponse.Model
+			response.Created = streamResponse.Created
+		}
+		for _, choice := range streamResponse.Choices {
+			if choice.Index != 0 {
+				return nil, false
+			}
+			content.WriteString(choice.Delta.GetContentString())
+			for _, toolCall := range choice.Delta.ToolCalls {
+				index := 0
+				if toolCall.Index != nil {
+					index = *toolCall.Index
+				}
+				for len(toolCalls) <= index {
+					toolCalls = append(toolCalls, dto.ToolCall{Type: "function"})
+				}
+				if toolCall.ID != "" {
+					toolCalls[index].ID = toolCall.ID
+				}
+				if toolCall.Function.Name != "" {
+					toolCalls[index].Function.Name = toolCall.Function.Name
+				}
+				toolCalls[index].Function.Arguments += toolCall.Function.Arguments
+			}
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				finishReason = *choice.FinishReason
+			}
+		}
+	}
+	if finishReason == "" {
+		return nil, false
+	}
+	message := dto.Message{Role: "assistant"}
+	message.SetStringContent(content.String())
+	if len(toolCalls) > 0 {
+		message.SetToolCalls(toolCalls)
+	}
+	response.Choices = []dto.OpenAITextResponseChoice{
+		{
+			Index:        0,
+			Message:      message,
+			FinishReason: finishReason,
+		},
+	}
+	return response, true
+}
+
+// getResponseCache 读取缓存的响应以及其中的用量，缓存无法解析时视为未命中
+func getResponseCache(key string) ([]byte, *dto.Usage, bool) {
+	cached, ok := service.GetResponseCache(key)
+	if !ok {
+		return nil, nil, false
+	}
+	var response dto.SimpleResponse
+	if err := json.Unmarshal(cached, &response); err != nil {
+		return nil, nil, false
+	}
+	return cached, &response.Usage, true
+}
+
+// writeResponseCache 将缓存的响应写给客户端，流式请求以 SSE 的形式重放
+func writeResponseCache(c *gin.Context, info *relaycommon.RelayInfo, cached []byte) {
+	c.Header(service.ResponseCacheHeader, "hit")
+	if !info.IsStream || info.RelayMode != relayconstant.RelayModeChatCompletions {
+		c.Data(http.StatusOK, "application/json", cached)
+		return
+	}
+	var response dto.OpenAITextResponse
+	if err := json.Unmarshal(cached, &response); err != nil {
+		logging.LogError(c, "unmarshal response cache failed: "+err.Error())
+		return
+	}
+	service.SetEventStreamHeaders(c)
+	for _, choice := range response.Choices {
+		delta := dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant"}
+		delta.SetContentString(choice.Message.StringContent())
+		toolCalls := choice.Message.ParseToolCalls()
+		for i := range toolCalls {
+			toolCalls[i].SetIndex(i)
+		}
+		delta.ToolCalls = toolCalls
+		chunk := dto.ChatCompletionsStreamResponse{
+			Id:      response.Id,
+			Object:  "chat.completion.chunk",
+			Created: response.Created,
+			Model:   response.Model,
+			Choices: []dto.ChatCompletionsStreamResponseChoice{
+				{
+					Index: choice.Index,
+					Delta: delta,
+				},
+			},
+		}
+		if err := service.ObjectData(c, chunk); err != nil {
+			logging.LogError(c, "write response cache failed: "+err.Error())
+			return
+		}
+		stopChunk := service.GenerateStopResponse(response.Id, response.Created, response.Model, choice.FinishReason)
+		stopChunk.Choices[0].Index = choice.Index
+		_ = service.ObjectData(c, stopChunk)
+	}
+	if info.ShouldIncludeUsage {
+		_ = service.ObjectData(c, service.GenerateFinalUsageResponse(response.Id, response.Created, response.Model, response.Usage))
+	}
+	service.Done(c)
+}
@@ -0,0 +1,172 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/relay/channel"
+	"one-api/relay/channel/aws"
+	"one-api/relay/channel/claude"
+	"one-api/relay/channel/vertex"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"one-api/logging")
+
+func getAndValidateClaudeRequest(c *gin.Context) (*claude.ClaudeNativeRequest, error) {
+	claudeRequest := &claude.ClaudeNativeRequest{}
+	err := common.UnmarshalBodyReusable(c, claudeRequest)
+	if err != nil {
+		return nil, err
+	}
+	if claudeRequest.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	if len(claudeRequest.Messages) == 0 {
+		return nil, errors.New("field messages is required")
+	}
+	if claudeRequest.MaxTokens == 0 {
+		return nil, errors.New("field max_tokens is required")
+	}
+	if claudeRequest.MaxTokens > math.MaxInt32/2 {
+		return nil, errors.New("max_tokens is invalid")
+	}
+	return claudeRequest, nil
+}
+
+// isClaudeNativeChannel 判断渠道是否原生支持 Anthropic Messages 格式，支持时请求直接透传
+func isClaudeNativeChannel(info *relaycommon.RelayInfo) bool {
+	switch info.ApiType {
+	case relayconstant.APITypeAnthropic, relayconstant.APITypeAws:
+		return true
+	case relayconstant.APITypeVertexAi:
+		return strings.HasPrefix(info.UpstreamModelName, "claude")
+	}
+	return false
+}
+
+func ClaudeHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
+	relayInfo := relaycommon.GenRelayInfo(c)
+
+	claudeRequest, err := getAndValidateClaudeRequest(c)
+	if err != nil {
+		logging.LogError(c, fmt.Sprintf("getAndValidateClaudeRequest failed: %s", err.Error()))
+		return service.OpenAIErrorWrapperLocal(err, "invalid_claude_request", http.StatusBadRequest)
+	}
+	relayInfo.IsStream = claudeRequest.Stream
+
+	// 转换为 OpenAI 格式，用于计费、敏感词检查以及非 Claude 渠道的转发
+	textRequest, err := claude.RequestClaude2OpenAI(claudeRequest)
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "invalid_claude_request", http.StatusBadRequest)
+	}
+	return relayNativeRequest(c, relayInfo, textRequest, func(c *gin.Context, relayInfo *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+		if isClaudeNativeChannel(relayInfo) {
+			return relayClaudeNative(c, relayInfo)
+		}
+		return relayByOpenAIAdaptor(c, relayInfo, textRequest, &claudeResponseConverter{
+			stream: &claude.OpenAI2ClaudeStreamConverter{
+				PromptTokens: relayInfo.PromptTokens,
+			},
+		})
+	})
+}
+
+// relayClaudeNative 将 Anthropic Messages 请求体几乎原样发送给 Claude 渠道，并原样返回 Anthropic 格式的响应
+func relayClaudeNative(c *gin.Context, info *relaycommon.RelayInfo) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+	requestBody := make(map[string]json.RawMessage)
+	if err := common.UnmarshalBodyReusable(c, &requestBody); err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "invalid_claude_request", http.StatusBadRequest)
+	}
+	requestBody["model"], _ = json.Marshal(info.UpstreamModelName)
+	if info.ParamPolicy != nil {
+		jsonData, err := json.Marshal(requestBody)
+		if err != nil {
+			return nil, service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
+		}
+		jsonData, err = info.ApplyParamPolicy(jsonData)
+		if err != nil {
+			return nil, service.OpenAIErrorWrapperLocal(err, "apply_param_policy_failed", http.StatusBadRequest)
+		}
+		requestBody = make(map[string]json.RawMessage)
+		if err := json.Unmarshal(jsonData, &requestBody); err != nil {
+			return nil, service.OpenAIErrorWrapperLocal(err, "apply_param_policy_failed", http.StatusInternalServerError)
+		}
+	}
+
+	if info.ApiType == relayconstant.APITypeAws {
+		openaiErr, usage := aws.ClaudeNativeHandler(c, info, requestBody)
+		return usage, openaiErr
+	}
+
+	var adaptor channel.Adaptor
+	if info.ApiType == relayconstant.APITypeVertexAi {
+		vertex.SetupClaudeNativeRequestBody(requestBody)
+		vertexAdaptor := &vertex.Adaptor{}
+		vertexAdaptor.Init(info)
+		adaptor = vertexAdaptor
+	} else {
+		adaptor = &claude.Adaptor{RequestMode: claude.RequestModeMessage}
+	}
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
+	}
+	resp, err := channel.DoApiRequest(adaptor, c, info, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, service.RelayErrorHandler(resp)
+	}
+
+	var openaiErr *dto.OpenAIErrorWithStatusCode
+	var usage *dto.Usage
+	if info.IsStream {
+		openaiErr, usage = claude.ClaudeNativeStreamHandler(c, resp, info)
+		if openaiErr == nil {
+			openaiErr = service.StreamIdleTimeoutError(c, resp)
+		}
+	} else {
+		openaiErr, usage = claude.ClaudeNativeHandler(c, resp, info)
+	}
+	return usage, openaiErr
+}
+
+// claudeResponseConverter 将 OpenAI 格式的响应转换为 Anthropic Messages 格式
+type claudeResponseConverter struct {
+	stream *claude.OpenAI2ClaudeStreamConverter
+}
+
+func (r *claudeResponseConverter) formatEvents(events []claude.ClaudeNativeStreamEvent) (string, error) {
+	var builder strings.Builder
+	for _, event := range events {
+		jsonData, err := json.Marshal(event)
+		if err != nil {
+			return "", err
+		}
+		builder.WriteString(fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, string(jsonData)))
+	}
+	return builder.String(), nil
+}
+
+func (r *claudeResponseConverter) StreamChunk(response *dto.ChatCompletionsStreamResponse) (string, error) {
+	return r.formatEvents(r.stream.Convert(response))
+}
+
+func (r *claudeResponseConverter) StreamEnd(usage *dto.Usage) (string, error) {
+	return r.formatEvents(r.stream.Finish(usage))
+}
+
+func (r *claudeResponseConverter) Response(response *dto.OpenAITextResponse, usage *dto.Usage) ([]byte, error) {
+	return json.Marshal(claude.ResponseOpenAI2Claude(response, usage))
+}
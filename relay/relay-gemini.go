@@ -0,0 +1,156 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/relay/channel"
+	"one-api/relay/channel/gemini"
+	"one-api/relay/channel/vertex"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"one-api/logging")
+
+// parseGeminiModelAction 解析 /v1beta/models/{model}:{action} 中的模型名和操作
+func parseGeminiModelAction(modelAction string) (string, string) {
+	index := strings.LastIndex(modelAction, ":")
+	if index < 0 {
+		return modelAction, ""
+	}
+	return modelAction[:index], modelAction[index+1:]
+}
+
+func getAndValidateGeminiRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo) (*gemini.GeminiNativeRequest, string, error) {
+	modelName, action := parseGeminiModelAction(c.Param("model"))
+	switch action {
+	case "generateContent":
+		relayInfo.IsStream = false
+	case "streamGenerateContent":
+		relayInfo.IsStream = true
+	default:
+		return nil, "", fmt.Errorf("unsupported action: %s", action)
+	}
+	if modelName == "" {
+		return nil, "", errors.New("model is required")
+	}
+	geminiRequest := &gemini.GeminiNativeRequest{}
+	err := common.UnmarshalBodyReusable(c, geminiRequest)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(geminiRequest.Contents) == 0 {
+		return nil, "", errors.New("field contents is required")
+	}
+	return geminiRequest, modelName, nil
+}
+
+// isGeminiNativeChannel 判断渠道是否原生支持 Gemini 格式，支持时请求直接透传
+func isGeminiNativeChannel(info *relaycommon.RelayInfo) bool {
+	switch info.ApiType {
+	case relayconstant.APITypeGemini:
+		return true
+	case relayconstant.APITypeVertexAi:
+		return strings.HasPrefix(info.UpstreamModelName, "gemini")
+	}
+	return false
+}
+
+func GeminiHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
+	relayInfo := relaycommon.GenRelayInfo(c)
+
+	geminiRequest, modelName, err := getAndValidateGeminiRequest(c, relayInfo)
+	if err != nil {
+		logging.LogError(c, fmt.Sprintf("getAndValidateGeminiRequest failed: %s", err.Error()))
+		return service.OpenAIErrorWrapperLocal(err, "invalid_gemini_request", http.StatusBadRequest)
+	}
+
+	// 转换为 OpenAI 格式，用于计费、敏感词检查以及非 Gemini 渠道的转发
+	textRequest, err := gemini.RequestGemini2OpenAI(geminiRequest, modelName)
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "invalid_gemini_request", http.StatusBadRequest)
+	}
+	return relayNativeRequest(c, relayInfo, textRequest, func(c *gin.Context, relayInfo *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+		if isGeminiNativeChannel(relayInfo) {
+			return relayGeminiNative(c, relayInfo)
+		}
+		return relayByOpenAIAdaptor(c, relayInfo, textRequest, &geminiResponseConverter{
+			stream: &gemini.OpenAI2GeminiStreamConverter{},
+		})
+	})
+}
+
+// relayGeminiNative 将 Gemini 原生请求体原样发送给 Gemini 渠道，模型由请求地址指定
+func relayGeminiNative(c *gin.Context, info *relaycommon.RelayInfo) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "get_request_body_failed", http.StatusInternalServerError)
+	}
+	requestBody, err = info.ApplyParamPolicy(requestBody)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "apply_param_policy_failed", http.StatusBadRequest)
+	}
+	var adaptor channel.Adaptor
+	if info.ApiType == relayconstant.APITypeVertexAi {
+		vertexAdaptor := &vertex.Adaptor{}
+		vertexAdaptor.Init(info)
+		adaptor = vertexAdaptor
+	} else {
+		adaptor = &gemini.Adaptor{}
+	}
+	resp, err := channel.DoApiRequest(adaptor, c, info, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, service.RelayErrorHandler(resp)
+	}
+
+	var openaiErr *dto.OpenAIErrorWithStatusCode
+	var usage *dto.Usage
+	if info.IsStream {
+		openaiErr, usage = gemini.GeminiNativeStreamHandler(c, resp, info)
+		if openaiErr == nil {
+			openaiErr = service.StreamIdleTimeoutError(c, resp)
+		}
+	} else {
+		openaiErr, usage = gemini.GeminiNativeHandler(c, resp, info)
+	}
+	return usage, openaiErr
+}
+
+// geminiResponseConverter 将 OpenAI 格式的响应转换为 Gemini 原生格式
+type geminiResponseConverter struct {
+	stream *gemini.OpenAI2GeminiStreamConverter
+}
+
+func (r *geminiResponseConverter) formatChunk(response *gemini.GeminiChatResponse) (string, error) {
+	if response == nil {
+		return "", nil
+	}
+	jsonData, err := json.Marshal(response)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("data: %s\n\n", string(jsonData)), nil
+}
+
+func (r *geminiResponseConverter) StreamChunk(response *dto.ChatCompletionsStreamResponse) (string, error) {
+	return r.formatChunk(r.stream.Convert(response))
+}
+
+func (r *geminiResponseConverter) StreamEnd(usage *dto.Usage) (string, error) {
+	return r.formatChunk(r.stream.Finish(usage))
+}
+
+func (r *geminiResponseConverter) Response(response *dto.OpenAITextResponse, usage *dto.Usage) ([]byte, error) {
+	return json.Marshal(gemini.ResponseOpenAI2Gemini(response, usage))
+}
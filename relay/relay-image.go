@@ -11,22 +11,75 @@ import (
 	"one-api/common"
 	"one-api/dto"
 	"one-api/model"
+	"one-api/relay/channel"
 	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
 	"one-api/service"
 	"one-api/setting"
+	"strconv"
 	"strings"
 
 	"one-api/logging")
 
-func getAndValidImageRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.ImageRequest, error) {
-	imageRequest := &dto.ImageRequest{}
-	err := common.UnmarshalBodyReusable(c, imageRequest)
-	if err != nil {
+// getImageFormRequest 解析 edits 和 variations 的 multipart 请求
+func getImageFormRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.ImageRequest, error) {
+	if !strings.HasPrefix(c.Request.Header.Get("Content-Type"), "multipart/form-data") {
+		return nil, errors.New("content type must be multipart/form-data")
+	}
+	// Distribute 中已经解析过表单，这里不会重复读取 body
+	if _, err := c.MultipartForm(); err != nil {
 		return nil, err
 	}
-	if imageRequest.Prompt == "" {
+	imageRequest := &dto.ImageRequest{
+		Model:          c.PostForm("model"),
+		Prompt:         c.PostForm("prompt"),
+		Size:           c.PostForm("size"),
+		Quality:        c.PostForm("quality"),
+		ResponseFormat: c.PostForm("response_format"),
+		User:           c.PostForm("user"),
+	}
+	if n := c.PostForm("n"); n != "" {
+		var err error
+		imageRequest.N, err = strconv.Atoi(n)
+		if err != nil {
+			return nil, errors.New("n must be an integer")
+		}
+	}
+	if !hasImageFormFile(c, "image") && !hasImageFormFile(c, "image[]") {
+		return nil, errors.New("image is required")
+	}
+	if info.RelayMode == relayconstant.RelayModeImagesEdits && imageRequest.Prompt == "" {
 		return nil, errors.New("prompt is required")
 	}
+	return imageRequest, nil
+}
+
+func hasImageFormFile(c *gin.Context, key string) bool {
+	if c.Request.MultipartForm == nil {
+		return false
+	}
+	return len(c.Request.MultipartForm.File[key]) > 0
+}
+
+func getAndValidImageRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.ImageRequest, error) {
+	var imageRequest *dto.ImageRequest
+	var err error
+	switch info.RelayMode {
+	case relayconstant.RelayModeImagesEdits, relayconstant.RelayModeImagesVariations:
+		imageRequest, err = getImageFormRequest(c, info)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		imageRequest = &dto.ImageRequest{}
+		err = common.UnmarshalBodyReusable(c, imageRequest)
+		if err != nil {
+			return nil, err
+		}
+		if imageRequest.Prompt == "" {
+			return nil, errors.New("prompt is required")
+		}
+	}
 	if strings.Contains(imageRequest.Size, "×") {
 		return nil, errors.New("size an unexpected error occurred in the parameter, please use 'x' instead of the multiplication sign '×'")
 	}
@@ -60,7 +113,7 @@ func getAndValidImageRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.
 	//if imageRequest.N != 0 && (imageRequest.N < 1 || imageRequest.N > 10) {
 	//	return service.OpenAIErrorWrapper(errors.New("n must be between 1 and 10"), "invalid_field_value", http.StatusBadRequest)
 	//}
-	if setting.ShouldCheckPromptSensitive() {
+	if setting.ShouldCheckPromptSensitive() && imageRequest.Prompt != "" {
 		err := service.CheckSensitiveInput(imageRequest.Prompt)
 		if err != nil {
 			return nil, err
@@ -86,8 +139,8 @@ func ImageHelper(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
 		if err != nil {
 			return service.OpenAIErrorWrapper(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
 		}
-		if modelMap[imageRequest.Model] != "" {
-			imageRequest.Model = modelMap[imageRequest.Model]
+		if mapped := common.MapModelName(modelMap, imageRequest.Model); mapped != imageRequest.Model {
+			imageRequest.Model = mapped
 		}
 	}
 	relayInfo.UpstreamModelName = imageRequest.Model
@@ -140,14 +193,27 @@ func ImageHelper(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
 
 	convertedRequest, err := adaptor.ConvertImageRequest(c, relayInfo, *imageRequest)
 	if err != nil {
+		if errors.Is(err, channel.ErrRequestNotSupported) {
+			return service.OpenAIErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+		}
 		return service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusInternalServerError)
 	}
 
-	jsonData, err := json.Marshal(convertedRequest)
-	if err != nil {
-		return service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
+	switch convertedRequest.(type) {
+	case io.Reader:
+		// multipart 请求由适配器自行构造
+		requestBody = convertedRequest.(io.Reader)
+	default:
+		jsonData, err := json.Marshal(convertedRequest)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
+		}
+		jsonData, err = relayInfo.ApplyParamPolicy(jsonData)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "apply_param_policy_failed", http.StatusBadRequest)
+		}
+		requestBody = bytes.NewBuffer(jsonData)
 	}
-	requestBody = bytes.NewBuffer(jsonData)
 
 	statusCodeMappingStr := c.GetString("status_code_mapping")
 
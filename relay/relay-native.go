@@ -0,0 +1,256 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"one-api/setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"one-api/logging")
+
+// nativeRelayFunc 将请求发送给渠道，并以客户端使用的原生格式写回响应
+type nativeRelayFunc func(c *gin.Context, relayInfo *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) (*dto.Usage, *dto.OpenAIErrorWithStatusCode)
+
+// relayNativeRequest 处理以 Anthropic、Gemini 等原生格式接入的请求，
+// textRequest 是转换后的 OpenAI 请求，计费、敏感词检查与 TextHelper 保持一致
+func relayNativeRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest, doRelay nativeRelayFunc) (openaiErr *dto.OpenAIErrorWithStatusCode) {
+	modelMapping := c.GetString("model_mapping")
+	if modelMapping != "" && modelMapping != "{}" {
+		modelMap := make(map[string]string)
+		err := json.Unmarshal([]byte(modelMapping), &modelMap)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
+		}
+		if mapped := common.MapModelName(modelMap, textRequest.Model); mapped != textRequest.Model {
+			textRequest.Model = mapped
+		}
+	}
+	relayInfo.UpstreamModelName = textRequest.Model
+	modelPrice, getModelPriceSuccess := common.GetModelPrice(textRequest.Model, false)
+	groupRatio := setting.GetGroupRatio(relayInfo.Group)
+	if relayInfo.BatchId != "" {
+		groupRatio = groupRatio * setting.BatchDiscountRatio
+	}
+
+	var preConsumedQuota int
+	var ratio float64
+	var modelRatio float64
+
+	if setting.ShouldCheckPromptSensitive() {
+		err := service.CheckSensitiveMessages(textRequest.Messages)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "sensitive_words_detected", http.StatusBadRequest)
+		}
+	}
+
+	var promptTokens int
+	if value, exists := c.Get("prompt_tokens"); exists {
+		promptTokens = value.(int)
+	} else {
+		var err error
+		promptTokens, err = service.CountTokenChatRequest(relayInfo, *textRequest)
+		if err != nil {
+			return service.OpenAIErrorWrapper(err, "count_token_messages_failed", http.StatusInternalServerError)
+		}
+		c.Set("prompt_tokens", promptTokens)
+	}
+	relayInfo.PromptTokens = promptTokens
+
+	if !getModelPriceSuccess {
+		preConsumedTokens := common.PreConsumedQuota
+		if textRequest.MaxTokens != 0 {
+			preConsumedTokens = promptTokens + int(textRequest.MaxTokens)
+		}
+		modelRatio = common.GetModelRatio(textRequest.Model)
+		ratio = modelRatio * groupRatio
+		preConsumedQuota = int(float64(preConsumedTokens) * ratio)
+	} else {
+		preConsumedQuota = int(modelPrice * common.QuotaPerUnit * groupRatio)
+	}
+
+	preConsumedQuota, userQuota, openaiErr := preConsumeQuota(c, preConsumedQuota, relayInfo)
+	if openaiErr != nil {
+		return openaiErr
+	}
+	defer func() {
+		if openaiErr != nil {
+			returnPreConsumedQuota(c, relayInfo, userQuota, preConsumedQuota)
+		}
+	}()
+
+	usage, openaiErr := doRelay(c, relayInfo, textRequest)
+	if openaiErr != nil {
+		// reset status code 重置状态码
+		service.ResetStatusCode(openaiErr, c.GetString("status_code_mapping"))
+		return openaiErr
+	}
+
+	postConsumeQuota(c, relayInfo, textRequest.Model, usage, ratio, preConsumedQuota, userQuota, modelRatio, groupRatio, modelPrice, getModelPriceSuccess, "")
+	return nil
+}
+
+// nativeResponseConverter 将适配器输出的 OpenAI 格式响应转换为客户端的原生格式
+type nativeResponseConverter interface {
+	// StreamChunk 返回需要写给客户端的流式数据，可以为空
+	StreamChunk(response *dto.ChatCompletionsStreamResponse) (string, error)
+	// StreamEnd 返回流结束时需要补发的数据
+	StreamEnd(usage *dto.Usage) (string, error)
+	Response(response *dto.OpenAITextResponse, usage *dto.Usage) ([]byte, error)
+}
+
+// relayByOpenAIAdaptor 按 chat completions 请求转发给渠道适配器，再由 converter 将响应转换回客户端的原生格式
+func relayByOpenAIAdaptor(c *gin.Context, info *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest, converter nativeResponseConverter) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+	info.RelayMode = relayconstant.RelayModeChatCompletions
+	info.RequestURLPath = "/v1/chat/completions"
+	textRequest.Stream = info.IsStream
+	if info.SupportStreamOptions && textRequest.Stream {
+		textRequest.StreamOptions = &dto.StreamOptions{
+			IncludeUsage: true,
+		}
+	}
+
+	adaptor := GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		return nil, service.OpenAIErrorWrapperLocal(fmt.Errorf("invalid api type: %d", info.ApiType), "invalid_api_type", http.StatusBadRequest)
+	}
+	adaptor.Init(info)
+	convertedRequest, err := adaptor.ConvertRequest(c, info, textRequest)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusInternalServerError)
+	}
+	jsonData, err := json.Marshal(convertedRequest)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
+	}
+	jsonData, err = info.ApplyParamPolicy(jsonData)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "apply_param_policy_failed", http.StatusBadRequest)
+	}
+	resp, err := adaptor.DoRequest(c, info, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	var httpResp *http.Response
+	if resp != nil {
+		httpResp = resp.(*http.Response)
+		info.IsStream = info.IsStream || strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream")
+		if httpResp.StatusCode != http.StatusOK {
+			return nil, service.RelayErrorHandler(httpResp)
+		}
+	}
+
+	writer := &nativeResponseWriter{
+		ResponseWriter: c.Writer,
+		isStream:       info.IsStream,
+		converter:      converter,
+	}
+	c.Writer = writer
+	usage, openaiErr := adaptor.DoResponse(c, httpResp, info)
+	c.Writer = writer.ResponseWriter
+	if openaiErr == nil {
+		openaiErr = service.StreamIdleTimeoutError(c, httpResp)
+	}
+	if openaiErr != nil {
+		return nil, openaiErr
+	}
+	textUsage, _ := usage.(*dto.Usage)
+	if err = writer.finish(textUsage); err != nil {
+		logging.LogError(c, "write native response failed: "+err.Error())
+	}
+	return textUsage, nil
+}
+
+// nativeResponseWriter 拦截适配器写出的 OpenAI 格式响应，转换后再写给客户端
+type nativeResponseWriter struct {
+	gin.ResponseWriter
+	isStream  bool
+	converter nativeResponseConverter
+	buffer    bytes.Buffer
+}
+
+func (w *nativeResponseWriter) WriteHeader(code int) {
+	// 转换后响应体长度会变化
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *nativeResponseWriter) Write(data []byte) (int, error) {
+	w.buffer.Write(data)
+	if w.isStream {
+		if err := w.convertStream(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *nativeResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *nativeResponseWriter) convertStream() error {
+	for {
+		line, err := w.buffer.ReadString('\n')
+		if err != nil {
+			// 不完整的行放回缓冲区，等待后续数据
+			w.buffer.WriteString(line)
+			return nil
+		}
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			continue
+		}
+		var streamResponse dto.ChatCompletionsStreamResponse
+		if err = json.Unmarshal([]byte(data), &streamResponse); err != nil {
+			logging.SysError("error unmarshalling stream response: " + err.Error())
+			continue
+		}
+		chunk, err := w.converter.StreamChunk(&streamResponse)
+		if err != nil {
+			return err
+		}
+		if chunk == "" {
+			continue
+		}
+		if _, err = w.ResponseWriter.WriteString(chunk); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *nativeResponseWriter) finish(usage *dto.Usage) error {
+	if w.isStream {
+		chunk, err := w.converter.StreamEnd(usage)
+		if err != nil {
+			return err
+		}
+		if _, err = w.ResponseWriter.WriteString(chunk); err != nil {
+			return err
+		}
+		w.ResponseWriter.Flush()
+		return nil
+	}
+	var response dto.OpenAITextResponse
+	if err := json.Unmarshal(w.buffer.Bytes(), &response); err != nil {
+		return err
+	}
+	jsonData, err := w.converter.Response(&response, usage)
+	if err != nil {
+		return err
+	}
+	_, err = w.ResponseWriter.Write(jsonData)
+	return err
+}
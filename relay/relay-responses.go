@@ -0,0 +1,139 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/relay/channel"
+	"one-api/relay/channel/openai"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"one-api/logging")
+
+func getAndValidateResponsesRequest(c *gin.Context) (*dto.OpenAIResponsesRequest, error) {
+	responsesRequest := &dto.OpenAIResponsesRequest{}
+	err := common.UnmarshalBodyReusable(c, responsesRequest)
+	if err != nil {
+		return nil, err
+	}
+	if responsesRequest.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	if len(responsesRequest.Input) == 0 {
+		return nil, errors.New("field input is required")
+	}
+	if responsesRequest.MaxOutputTokens > math.MaxInt32/2 {
+		return nil, errors.New("max_output_tokens is invalid")
+	}
+	return responsesRequest, nil
+}
+
+// isResponsesNativeChannel 判断渠道是否原生支持 Responses API，支持时请求直接透传
+func isResponsesNativeChannel(info *relaycommon.RelayInfo) bool {
+	return info.ChannelType == common.ChannelTypeOpenAI || info.ChannelType == common.ChannelTypeAzure
+}
+
+func ResponsesHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
+	relayInfo := relaycommon.GenRelayInfo(c)
+
+	responsesRequest, err := getAndValidateResponsesRequest(c)
+	if err != nil {
+		logging.LogError(c, fmt.Sprintf("getAndValidateResponsesRequest failed: %s", err.Error()))
+		return service.OpenAIErrorWrapperLocal(err, "invalid_responses_request", http.StatusBadRequest)
+	}
+	relayInfo.IsStream = responsesRequest.Stream
+
+	// 转换为 chat completions 格式，用于计费、敏感词检查以及非 OpenAI 渠道的模拟
+	textRequest, err := openai.RequestResponses2OpenAI(responsesRequest)
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "invalid_responses_request", http.StatusBadRequest)
+	}
+	return relayNativeRequest(c, relayInfo, textRequest, func(c *gin.Context, relayInfo *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+		if isResponsesNativeChannel(relayInfo) {
+			return relayResponsesNative(c, relayInfo)
+		}
+		if err := openai.CheckResponsesEmulation(responsesRequest); err != nil {
+			return nil, service.OpenAIErrorWrapperLocal(err, "unsupported_responses_request", http.StatusBadRequest)
+		}
+		return relayByOpenAIAdaptor(c, relayInfo, textRequest, &responsesResponseConverter{
+			stream: &openai.OpenAI2ResponsesStreamConverter{},
+		})
+	})
+}
+
+// relayResponsesNative 将 Responses API 请求体原样发送给 OpenAI 渠道，仅替换映射后的模型
+func relayResponsesNative(c *gin.Context, info *relaycommon.RelayInfo) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+	requestBody := make(map[string]json.RawMessage)
+	if err := common.UnmarshalBodyReusable(c, &requestBody); err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "invalid_responses_request", http.StatusBadRequest)
+	}
+	requestBody["model"], _ = json.Marshal(info.UpstreamModelName)
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
+	}
+	jsonData, err = info.ApplyParamPolicy(jsonData)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "apply_param_policy_failed", http.StatusBadRequest)
+	}
+
+	adaptor := &openai.Adaptor{}
+	adaptor.Init(info)
+	resp, err := channel.DoApiRequest(adaptor, c, info, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, service.RelayErrorHandler(resp)
+	}
+
+	var openaiErr *dto.OpenAIErrorWithStatusCode
+	var usage *dto.Usage
+	if info.IsStream {
+		openaiErr, usage = openai.ResponsesStreamHandler(c, resp, info)
+		if openaiErr == nil {
+			openaiErr = service.StreamIdleTimeoutError(c, resp)
+		}
+	} else {
+		openaiErr, usage = openai.ResponsesHandler(c, resp, info)
+	}
+	return usage, openaiErr
+}
+
+// responsesResponseConverter 将 chat completions 响应转换为 Responses API 格式
+type responsesResponseConverter struct {
+	stream *openai.OpenAI2ResponsesStreamConverter
+}
+
+func (r *responsesResponseConverter) formatEvents(events []dto.ResponsesStreamEvent) (string, error) {
+	var builder strings.Builder
+	for _, event := range events {
+		jsonData, err := json.Marshal(event)
+		if err != nil {
+			return "", err
+		}
+		builder.WriteString(fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, string(jsonData)))
+	}
+	return builder.String(), nil
+}
+
+func (r *responsesResponseConverter) StreamChunk(response *dto.ChatCompletionsStreamResponse) (string, error) {
+	return r.formatEvents(r.stream.Convert(response))
+}
+
+func (r *responsesResponseConverter) StreamEnd(usage *dto.Usage) (string, error) {
+	return r.formatEvents(r.stream.Finish(usage))
+}
+
+func (r *responsesResponseConverter) Response(response *dto.OpenAITextResponse, usage *dto.Usage) ([]byte, error) {
+	return json.Marshal(openai.ResponseOpenAI2Responses(response, usage))
+}
@@ -0,0 +1,69 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"one-api/setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// structuredOutputWriter 暂存非流式响应，校验通过后再写给客户端，校验失败时丢弃以便重试其他渠道
+type structuredOutputWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *structuredOutputWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *structuredOutputWriter) WriteHeaderNow() {
+}
+
+func (w *structuredOutputWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *structuredOutputWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *structuredOutputWriter) flush() error {
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	_, err := w.ResponseWriter.Write(w.body.Bytes())
+	return err
+}
+
+// shouldValidateStructuredOutput 开启结构化输出校验时，只校验指定了 JSON 输出格式的非流式 chat completions 请求
+func shouldValidateStructuredOutput(info *relaycommon.RelayInfo, format *dto.ResponseFormat) bool {
+	if !setting.StructuredOutputValidationEnabled || info.IsStream || info.RelayMode != relayconstant.RelayModeChatCompletions {
+		return false
+	}
+	return format != nil && (format.Type == "json_object" || format.Type == "json_schema")
+}
+
+// validateStructuredOutput 校验响应中每个 choice 的内容，调用工具或因长度截断的 choice 不校验
+func validateStructuredOutput(format *dto.ResponseFormat, body []byte) error {
+	var response dto.OpenAITextResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("invalid response body: %s", err.Error())
+	}
+	for _, choice := range response.Choices {
+		if choice.FinishReason == "length" || len(choice.Message.ParseToolCalls()) > 0 {
+			continue
+		}
+		if err := service.ValidateStructuredOutput(format, choice.Message.StringContent()); err != nil {
+			return fmt.Errorf("structured output does not match response_format: %s", err.Error())
+		}
+	}
+	return nil
+}
@@ -76,6 +76,21 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 		return service.OpenAIErrorWrapperLocal(err, "invalid_text_request", http.StatusBadRequest)
 	}
 
+	// 响应缓存按映射前的模型计算，命中时不再请求上游
+	responseCacheKey := ""
+	var cachedResponse []byte
+	var cachedUsage *dto.Usage
+	if service.ShouldUseResponseCache(c, relayInfo.RelayMode) {
+		responseCacheKey, err = service.ResponseCacheKey(relayInfo.UserId, relayInfo.RelayMode, textRequest)
+		if err != nil {
+			logging.LogError(c, "get response cache key failed: "+err.Error())
+		} else {
+			cachedResponse, cachedUsage, relayInfo.ResponseCacheHit = getResponseCache(responseCacheKey)
+			// 命中缓存时没有请求上游，不计入渠道的每分钟请求数
+			c.Set("response_cache_hit", relayInfo.ResponseCacheHit)
+		}
+	}
+
 	// map model name
 	//isModelMapped := false
 	modelMapping := c.GetString("model_mapping")
@@ -86,9 +101,9 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 		if err != nil {
 			return service.OpenAIErrorWrapperLocal(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
 		}
-		if modelMap[textRequest.Model] != "" {
+		if mapped := common.MapModelName(modelMap, textRequest.Model); mapped != textRequest.Model {
 			//isModelMapped = true
-			textRequest.Model = modelMap[textRequest.Model]
+			textRequest.Model = mapped
 			// set upstream model name
 			//isModelMapped = true
 		}
@@ -96,6 +111,12 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 	relayInfo.UpstreamModelName = textRequest.Model
 	modelPrice, getModelPriceSuccess := common.GetModelPrice(textRequest.Model, false)
 	groupRatio := setting.GetGroupRatio(relayInfo.Group)
+	if relayInfo.BatchId != "" {
+		groupRatio = groupRatio * setting.BatchDiscountRatio
+	}
+	if relayInfo.ResponseCacheHit {
+		groupRatio = groupRatio * setting.ResponseCacheRatio
+	}
 
 	var preConsumedQuota int
 	var ratio float64
@@ -122,6 +143,9 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 		}
 		c.Set("prompt_tokens", promptTokens)
 	}
+	if openaiErr := service.CheckRelayTokenRateLimit(c, promptTokens); openaiErr != nil {
+		return openaiErr
+	}
 
 	if !getModelPriceSuccess {
 		preConsumedTokens := common.PreConsumedQuota
@@ -167,6 +191,18 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 		relayInfo.ShouldIncludeUsage = true
 	}
 
+	if relayInfo.ResponseCacheHit {
+		writeResponseCache(c, relayInfo, cachedResponse)
+		if cachedUsage.TotalTokens == 0 {
+			cachedUsage = &dto.Usage{PromptTokens: promptTokens, TotalTokens: promptTokens}
+		}
+		// 没有请求上游，上游的提示词缓存不适用，已按响应缓存倍率计费
+		cachedUsage.PromptTokensDetails.CachedTokens = 0
+		cachedUsage.PromptTokensDetails.CachedCreationTokens = 0
+		postConsumeQuota(c, relayInfo, textRequest.Model, cachedUsage, ratio, preConsumedQuota, userQuota, modelRatio, groupRatio, modelPrice, getModelPriceSuccess, "")
+		return nil
+	}
+
 	adaptor := GetAdaptor(relayInfo.ApiType)
 	if adaptor == nil {
 		return service.OpenAIErrorWrapperLocal(fmt.Errorf("invalid api type: %d", relayInfo.ApiType), "invalid_api_type", http.StatusBadRequest)
@@ -184,6 +220,8 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 	//
 	//}
 
+	// 适配器可能在转换时移除 response_format，校验结构化输出时使用客户端请求的格式
+	responseFormat := textRequest.ResponseFormat
 	convertedRequest, err := adaptor.ConvertRequest(c, relayInfo, textRequest)
 	if err != nil {
 		return service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusInternalServerError)
@@ -192,6 +230,10 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 	if err != nil {
 		return service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
 	}
+	jsonData, err = relayInfo.ApplyParamPolicy(jsonData)
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "apply_param_policy_failed", http.StatusBadRequest)
+	}
 	requestBody = bytes.NewBuffer(jsonData)
 
 	statusCodeMappingStr := c.GetString("status_code_mapping")
@@ -212,12 +254,45 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 		}
 	}
 
+	originalWriter := c.Writer
+	var outputWriter *structuredOutputWriter
+	if shouldValidateStructuredOutput(relayInfo, responseFormat) {
+		outputWriter = &structuredOutputWriter{ResponseWriter: c.Writer}
+		c.Writer = outputWriter
+	}
+	var cacheWriter *responseCacheWriter
+	if responseCacheKey != "" {
+		cacheWriter = &responseCacheWriter{ResponseWriter: c.Writer}
+		c.Writer = cacheWriter
+	}
 	usage, openaiErr := adaptor.DoResponse(c, httpResp, relayInfo)
+	c.Writer = originalWriter
+	if openaiErr == nil {
+		// 上游流式响应空闲超时且客户端尚未收到内容时，交给重试逻辑处理
+		openaiErr = service.StreamIdleTimeoutError(c, httpResp)
+	}
 	if openaiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
 		return openaiErr
 	}
+	if outputWriter != nil {
+		if err := validateStructuredOutput(responseFormat, outputWriter.body.Bytes()); err != nil {
+			if c.GetInt("retry_remaining") > 0 {
+				// 上游已经生成了内容，本次尝试照常结算，再按上游错误处理，交给重试逻辑换渠道
+				postConsumeQuota(c, relayInfo, textRequest.Model, usage.(*dto.Usage), ratio, preConsumedQuota, userQuota, modelRatio, groupRatio, modelPrice, getModelPriceSuccess, "结构化输出校验失败，已换渠道重试")
+				return service.OpenAIErrorWrapper(err, "structured_output_mismatch", http.StatusBadGateway)
+			}
+			// 没有可以重试的渠道时返回上游的响应，由客户端处理
+			logging.LogWarn(c, err.Error())
+		}
+		if err := outputWriter.flush(); err != nil {
+			logging.LogError(c, "write structured output response failed: "+err.Error())
+		}
+	}
+	if cacheWriter != nil {
+		saveResponseCache(relayInfo, responseCacheKey, cacheWriter.body.Bytes(), usage.(*dto.Usage))
+	}
 
 	if strings.HasPrefix(relayInfo.UpstreamModelName, "gpt-4o-audio") {
 		service.PostAudioConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, modelRatio, groupRatio, modelPrice, getModelPriceSuccess, "")
@@ -335,10 +410,18 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelN
 
 	tokenName := ctx.GetString("token_name")
 	completionRatio := common.GetCompletionRatio(modelName)
+	// prompt_tokens 包含缓存读取与写入的 token，这两部分按缓存倍率计费
+	cacheTokens := usage.PromptTokensDetails.CachedTokens
+	cacheCreationTokens := usage.PromptTokensDetails.CachedCreationTokens
+	cacheRatio := common.GetCacheRatio(modelName)
+	createCacheRatio := common.GetCreateCacheRatio(modelName)
 
 	quota := 0
 	if !usePrice {
 		quota = promptTokens + int(math.Round(float64(completionTokens)*completionRatio))
+		if cacheTokens > 0 || cacheCreationTokens > 0 {
+			quota += int(math.Round(float64(cacheTokens)*(cacheRatio-1) + float64(cacheCreationTokens)*(createCacheRatio-1)))
+		}
 		quota = int(math.Round(float64(quota) * ratio))
 		if ratio != 0 && quota <= 0 {
 			quota = 1
@@ -350,6 +433,12 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelN
 	var logContent string
 	if !usePrice {
 		logContent = fmt.Sprintf("模型倍率 %.2f，补全倍率 %.2f，分组倍率 %.2f", modelRatio, completionRatio, groupRatio)
+		if cacheTokens > 0 {
+			logContent += fmt.Sprintf("，缓存 %d tokens，缓存倍率 %.2f", cacheTokens, cacheRatio)
+		}
+		if cacheCreationTokens > 0 {
+			logContent += fmt.Sprintf("，缓存写入 %d tokens，缓存写入倍率 %.2f", cacheCreationTokens, createCacheRatio)
+		}
 	} else {
 		logContent = fmt.Sprintf("模型价格 %.2f，分组倍率 %.2f", modelPrice, groupRatio)
 	}
@@ -374,7 +463,13 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelN
 			}
 		}
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
-		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		if !relayInfo.ResponseCacheHit {
+			// 命中缓存时没有请求渠道，不计入渠道用量
+			model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+			model.UpdateChannelKeyUsedQuota(relayInfo.ChannelKeyId, quota)
+			model.RecordChannelTokens(relayInfo.ChannelId, relayInfo.OriginModelName, usage.TotalTokens)
+		}
+		service.RecordRelayRateLimitTokens(ctx, usage.TotalTokens)
 	}
 
 	logModel := modelName
@@ -390,6 +485,14 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelN
 		logContent += ", " + extraContent
 	}
 	other := service.GenerateTextOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio, modelPrice)
+	if cacheTokens > 0 {
+		other["cache_tokens"] = cacheTokens
+		other["cache_ratio"] = cacheRatio
+	}
+	if cacheCreationTokens > 0 {
+		other["cache_creation_tokens"] = cacheCreationTokens
+		other["cache_creation_ratio"] = createCacheRatio
+	}
 	model.RecordConsumeLog(ctx, relayInfo.UserId, relayInfo.ChannelId, promptTokens, completionTokens, logModel,
 		tokenName, quota, logContent, relayInfo.TokenId, userQuota, int(useTimeSeconds), relayInfo.IsStream, relayInfo.Group, other)
 
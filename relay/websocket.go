@@ -32,8 +32,8 @@ func WssHelper(c *gin.Context, ws *websocket.Conn) (openaiErr *dto.OpenAIErrorWi
 		if err != nil {
 			return service.OpenAIErrorWrapperLocal(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
 		}
-		if modelMap[relayInfo.OriginModelName] != "" {
-			relayInfo.UpstreamModelName = modelMap[relayInfo.OriginModelName]
+		if mapped := common.MapModelName(modelMap, relayInfo.OriginModelName); mapped != relayInfo.OriginModelName {
+			relayInfo.UpstreamModelName = mapped
 			// set upstream model name
 			//isModelMapped = true
 		}
@@ -76,6 +76,7 @@ func SetApiRouter(router *gin.Engine) {
 			optionRoute.GET("/", controller.GetOptions)
 			optionRoute.PUT("/", controller.UpdateOption)
 			optionRoute.POST("/rest_model_ratio", controller.ResetModelRatio)
+			optionRoute.POST("/reset_cache_ratio", controller.ResetCacheRatio)
 		}
 		channelRoute := apiRouter.Group("/channel")
 		{
@@ -105,6 +106,11 @@ func SetApiRouter(router *gin.Engine) {
 				adminChannelRoute.GET("/fetch_models/:id", controller.FetchUpstreamModels)
 				adminChannelRoute.POST("/fetch_models", controller.FetchModels)
 				adminChannelRoute.POST("/batch/tag", controller.BatchSetChannelTag)
+				adminChannelRoute.GET("/breaker", controller.GetChannelBreakers)
+				adminChannelRoute.DELETE("/breaker/:id", controller.ResetChannelBreaker)
+				adminChannelRoute.GET("/queue", controller.GetRelayQueueStats)
+				adminChannelRoute.GET("/:id/keys", controller.GetChannelKeys)
+				adminChannelRoute.PUT("/:id/keys/:key_id", controller.UpdateChannelKeyStatus)
 			}
 		}
 		tokenRoute := apiRouter.Group("/token")
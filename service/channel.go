@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"one-api/common"
 	relaymodel "one-api/dto"
+	"one-api/logging"
 	"one-api/model"
 	"strings"
 )
@@ -17,7 +18,29 @@ func DisableChannel(channelId int, channelName string, reason string) {
 	notifyRootUser(subject, content)
 }
 
+func DisableChannelKey(channelId int, channelKeyId int, channelName string, reason string) {
+	remain, err := model.UpdateChannelKeyStatus(channelId, channelKeyId, common.ChannelStatusAutoDisabled, reason)
+	if err != nil {
+		logging.SysError(fmt.Sprintf("failed to disable key #%d of channel #%d: %s", channelKeyId, channelId, err.Error()))
+		return
+	}
+	common.SysLog(fmt.Sprintf("key #%d of channel #%d disabled, %d keys remain, reason: %s", channelKeyId, channelId, remain, reason))
+	if remain == 0 {
+		DisableChannel(channelId, channelName, "所有密钥均已被禁用，最后一个密钥的禁用原因："+reason)
+	}
+}
+
 func EnableChannel(channelId int, channelName string) {
+	// 多密钥渠道在所有密钥被自动禁用后才会被禁用，重新启用时一并启用这些密钥，否则请求时选择不到可用密钥
+	total, enabled, err := model.EnableAutoDisabledChannelKeys(channelId)
+	if err != nil {
+		logging.SysError(fmt.Sprintf("failed to enable keys of channel #%d: %s", channelId, err.Error()))
+		return
+	}
+	if total > 0 && enabled == 0 {
+		common.SysLog(fmt.Sprintf("all keys of channel #%d are manually disabled, skip enabling the channel", channelId))
+		return
+	}
 	model.UpdateChannelStatusById(channelId, common.ChannelStatusEnabled, "")
 	subject := fmt.Sprintf("通道「%s」（#%d）已被启用", channelName, channelId)
 	content := fmt.Sprintf("通道「%s」（#%d）已被启用", channelName, channelId)
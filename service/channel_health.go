@@ -0,0 +1,77 @@
+package service
+
+import (
+	"net/http"
+	"one-api/dto"
+	"one-api/model"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChannelHealthRecorder 记录一次渠道请求的耗时与首字时间，首字时间取第一次写给客户端的时间
+type ChannelHealthRecorder struct {
+	gin.ResponseWriter
+	channelId  int
+	startTime  time.Time
+	firstWrite time.Time
+}
+
+func NewChannelHealthRecorder(c *gin.Context, channelId int) *ChannelHealthRecorder {
+	recorder := &ChannelHealthRecorder{
+		ResponseWriter: c.Writer,
+		channelId:      channelId,
+		startTime:      time.Now(),
+	}
+	c.Writer = recorder
+	return recorder
+}
+
+func (r *ChannelHealthRecorder) markFirstWrite() {
+	if r.firstWrite.IsZero() {
+		r.firstWrite = time.Now()
+	}
+}
+
+func (r *ChannelHealthRecorder) Write(data []byte) (int, error) {
+	r.markFirstWrite()
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *ChannelHealthRecorder) WriteString(s string) (int, error) {
+	r.markFirstWrite()
+	return r.ResponseWriter.WriteString(s)
+}
+
+// Finish 恢复原来的 ResponseWriter 并记录请求结果到渠道健康状况与熔断器，请求本身的错误（如参数错误）
+// 与命中响应缓存的请求不计入，上游限流（429）只计入健康状况，不触发熔断
+func (r *ChannelHealthRecorder) Finish(c *gin.Context, openaiErr *dto.OpenAIErrorWithStatusCode) {
+	c.Writer = r.ResponseWriter
+	if c.GetBool("response_cache_hit") {
+		return
+	}
+	if openaiErr != nil && !isChannelHealthError(openaiErr) {
+		return
+	}
+	ttft := time.Duration(0)
+	if !r.firstWrite.IsZero() && strings.HasPrefix(r.Header().Get("Content-Type"), "text/event-stream") {
+		ttft = r.firstWrite.Sub(r.startTime)
+	}
+	model.RecordChannelHealth(r.channelId, openaiErr == nil, time.Since(r.startTime), ttft)
+	if openaiErr != nil && openaiErr.StatusCode == http.StatusTooManyRequests {
+		return
+	}
+	model.RecordChannelBreaker(r.channelId, c.GetString("original_model"), openaiErr == nil)
+}
+
+func isChannelHealthError(openaiErr *dto.OpenAIErrorWithStatusCode) bool {
+	if openaiErr.LocalError {
+		return false
+	}
+	switch openaiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	}
+	return openaiErr.StatusCode >= 500
+}
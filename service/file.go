@@ -0,0 +1,168 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strings"
+)
+
+func getChannelFileBaseURL(channel *model.Channel) string {
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" && channel.Type >= 0 && channel.Type < len(common.ChannelBaseURLs) {
+		baseURL = common.ChannelBaseURLs[channel.Type]
+	}
+	return strings.TrimRight(baseURL, "/")
+}
+
+// GetChannelFileURL 返回上游渠道 Files API 的地址，path 为 /files 之后的部分
+func GetChannelFileURL(channel *model.Channel, path string) string {
+	baseURL := getChannelFileBaseURL(channel)
+	if channel.Type == common.ChannelTypeAzure {
+		return fmt.Sprintf("%s/openai/files%s?api-version=%s", baseURL, path, channel.Other)
+	}
+	return fmt.Sprintf("%s/v1/files%s", baseURL, path)
+}
+
+// SetChannelFileRequestHeader keyId 为创建文件或任务时固定的密钥，为 0 时使用第一个启用的密钥
+func SetChannelFileRequestHeader(req *http.Request, channel *model.Channel, keyId int) {
+	key, _ := channel.GetPinnedKey(keyId)
+	if channel.Type == common.ChannelTypeAzure {
+		req.Header.Set("api-key", key)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	if channel.OpenAIOrganization != nil && *channel.OpenAIOrganization != "" {
+		req.Header.Set("OpenAI-Organization", *channel.OpenAIOrganization)
+	}
+}
+
+// UploadFileToChannel 将文件上传到上游渠道，返回上游的文件id
+func UploadFileToChannel(channel *model.Channel, keyId int, purpose string, filename string, content io.Reader) (string, error) {
+	if channel.Type != common.ChannelTypeOpenAI && channel.Type != common.ChannelTypeAzure {
+		return "", fmt.Errorf("channel type %d does not support files api", channel.Type)
+	}
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err := writer.WriteField("purpose", purpose); err != nil {
+		return "", err
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(part, content); err != nil {
+		return "", err
+	}
+	if err = writer.Close(); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, GetChannelFileURL(channel, ""), body)
+	if err != nil {
+		return "", err
+	}
+	SetChannelFileRequestHeader(req, channel, keyId)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, err := doChannelRequest(channel, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("upload file to channel failed: status code %d, body: %s", resp.StatusCode, string(responseBody))
+	}
+	var uploaded struct {
+		Id string `json:"id"`
+	}
+	if err = json.Unmarshal(responseBody, &uploaded); err != nil {
+		return "", err
+	}
+	if uploaded.Id == "" {
+		return "", errors.New("upstream returned empty file id")
+	}
+	return uploaded.Id, nil
+}
+
+// DeleteChannelFile 删除上游渠道中的文件，失败时只返回错误，不影响本地删除
+func DeleteChannelFile(channel *model.Channel, keyId int, upstreamFileId string) error {
+	req, err := http.NewRequest(http.MethodDelete, GetChannelFileURL(channel, "/"+upstreamFileId), nil)
+	if err != nil {
+		return err
+	}
+	SetChannelFileRequestHeader(req, channel, keyId)
+	resp, err := doChannelRequest(channel, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("delete channel file failed: status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// EnsureChannelFile 确保文件已上传到指定渠道的指定密钥下，返回上游文件id
+func EnsureChannelFile(file *model.File, channel *model.Channel, keyId int) (string, error) {
+	if file.ChannelId == channel.Id && file.KeyId == keyId && file.UpstreamFileId != "" {
+		return file.UpstreamFileId, nil
+	}
+	storage, err := GetFileStorageByName(file.Storage)
+	if err != nil {
+		return "", err
+	}
+	reader, err := storage.Open(file.StoragePath)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	upstreamFileId, err := UploadFileToChannel(channel, keyId, file.Purpose, file.Filename, reader)
+	if err != nil {
+		return "", err
+	}
+	file.ChannelId = channel.Id
+	file.KeyId = keyId
+	file.UpstreamFileId = upstreamFileId
+	if err = file.Update(); err != nil {
+		return "", err
+	}
+	return upstreamFileId, nil
+}
+
+// SaveUserFile 将网关生成的文件（如 batch 结果）保存到存储后端，不计入用户上传限制
+func SaveUserFile(userId int, tokenId int, filename string, purpose string, content io.Reader) (*model.File, error) {
+	storage, err := GetFileStorage()
+	if err != nil {
+		return nil, err
+	}
+	fileId := "file-" + common.GetUUID()
+	path, size, err := storage.Save(fmt.Sprintf("%d/%s", userId, fileId), content)
+	if err != nil {
+		return nil, err
+	}
+	file := &model.File{
+		FileId:      fileId,
+		UserId:      userId,
+		TokenId:     tokenId,
+		Filename:    filename,
+		Purpose:     purpose,
+		Bytes:       size,
+		Status:      model.FileStatusProcessed,
+		Storage:     storage.Name(),
+		StoragePath: path,
+	}
+	if err = file.Insert(); err != nil {
+		_ = storage.Delete(path)
+		return nil, err
+	}
+	return file, nil
+}
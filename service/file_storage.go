@@ -0,0 +1,109 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"one-api/constant"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileStorage Files API 的存储后端
+type FileStorage interface {
+	Name() string
+	Save(key string, reader io.Reader) (path string, size int64, err error)
+	Open(path string) (io.ReadCloser, error)
+	Delete(path string) error
+}
+
+type LocalFileStorage struct {
+	BaseDir string
+}
+
+func (s *LocalFileStorage) Name() string {
+	return "local"
+}
+
+func (s *LocalFileStorage) resolve(path string) (string, error) {
+	fullPath := filepath.Join(s.BaseDir, filepath.Clean("/"+path))
+	if !strings.HasPrefix(fullPath, filepath.Clean(s.BaseDir)) {
+		return "", errors.New("invalid file path")
+	}
+	return fullPath, nil
+}
+
+func (s *LocalFileStorage) Save(key string, reader io.Reader) (string, int64, error) {
+	fullPath, err := s.resolve(key)
+	if err != nil {
+		return "", 0, err
+	}
+	if err = os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", 0, err
+	}
+	f, err := os.Create(fullPath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	size, err := io.Copy(f, reader)
+	if err != nil {
+		_ = os.Remove(fullPath)
+		return "", 0, err
+	}
+	return key, size, nil
+}
+
+func (s *LocalFileStorage) Open(path string) (io.ReadCloser, error) {
+	fullPath, err := s.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(fullPath)
+}
+
+func (s *LocalFileStorage) Delete(path string) error {
+	fullPath, err := s.resolve(path)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(fullPath)
+	if err != nil && os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+var (
+	fileStorages    = map[string]FileStorage{}
+	fileStorageLock sync.Mutex
+)
+
+// RegisterFileStorage 注册存储后端，后注册的同名后端会覆盖之前的
+func RegisterFileStorage(storage FileStorage) {
+	fileStorageLock.Lock()
+	defer fileStorageLock.Unlock()
+	fileStorages[storage.Name()] = storage
+}
+
+func GetFileStorageByName(name string) (FileStorage, error) {
+	fileStorageLock.Lock()
+	defer fileStorageLock.Unlock()
+	if name == "local" {
+		if _, ok := fileStorages[name]; !ok {
+			fileStorages[name] = &LocalFileStorage{BaseDir: constant.FileStoragePath}
+		}
+	}
+	storage, ok := fileStorages[name]
+	if !ok {
+		return nil, fmt.Errorf("file storage %s not found", name)
+	}
+	return storage, nil
+}
+
+// GetFileStorage 返回当前配置的存储后端
+func GetFileStorage() (FileStorage, error) {
+	return GetFileStorageByName(constant.FileStorageType)
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logging"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/setting"
+	"time"
+)
+
+// GetChannelFineTuningURL 返回上游渠道微调接口的地址，path 为 /fine_tuning/jobs 之后的部分
+func GetChannelFineTuningURL(channel *model.Channel, path string, query url.Values) string {
+	baseURL := getChannelFileBaseURL(channel)
+	if channel.Type == common.ChannelTypeAzure {
+		if query == nil {
+			query = url.Values{}
+		}
+		query.Set("api-version", channel.Other)
+		return fmt.Sprintf("%s/openai/fine_tuning/jobs%s?%s", baseURL, path, query.Encode())
+	}
+	requestURL := fmt.Sprintf("%s/v1/fine_tuning/jobs%s", baseURL, path)
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+	return requestURL
+}
+
+// DoChannelFineTuningRequest 向微调任务所属的渠道发送请求，保证同一任务始终使用创建时的密钥
+func DoChannelFineTuningRequest(channel *model.Channel, keyId int, method string, path string, query url.Values, body io.Reader) (*http.Response, error) {
+	if channel.Type != common.ChannelTypeOpenAI && channel.Type != common.ChannelTypeAzure {
+		return nil, fmt.Errorf("channel type %d does not support fine-tuning api", channel.Type)
+	}
+	req, err := http.NewRequest(method, GetChannelFineTuningURL(channel, path, query), body)
+	if err != nil {
+		return nil, err
+	}
+	SetChannelFileRequestHeader(req, channel, keyId)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return doChannelRequest(channel, req)
+}
+
+// FineTuningStatus2TaskStatus 将上游微调任务状态转换为任务状态
+func FineTuningStatus2TaskStatus(status string) model.TaskStatus {
+	switch status {
+	case "validating_files", "queued":
+		return model.TaskStatusQueued
+	case "running":
+		return model.TaskStatusInProgress
+	case "succeeded":
+		return model.TaskStatusSuccess
+	case "failed", "cancelled":
+		return model.TaskStatusFailure
+	}
+	return model.TaskStatusUnknown
+}
+
+// UpdateFineTuningTask 根据上游返回的微调任务更新本地任务，任务成功时按训练的 token 数结算
+func UpdateFineTuningTask(ctx context.Context, task *model.Task, responseBody []byte) error {
+	var job dto.FineTuningJob
+	if err := json.Unmarshal(responseBody, &job); err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	task.Status = FineTuningStatus2TaskStatus(job.Status)
+	task.Data = responseBody
+	if task.Status == model.TaskStatusInProgress && task.StartTime == 0 {
+		task.StartTime = now
+	}
+	if job.Error != nil && job.Error.Message != "" {
+		task.FailReason = job.Error.Message
+	} else if job.Status == "cancelled" {
+		task.FailReason = "cancelled"
+	}
+	params := map[string]any{
+		"status":      task.Status,
+		"fail_reason": task.FailReason,
+		"start_time":  task.StartTime,
+		"data":        task.Data,
+	}
+	if task.Status != model.TaskStatusSuccess && task.Status != model.TaskStatusFailure {
+		_, err := model.TaskUpdateUnfinished(task.ID, params)
+		return err
+	}
+
+	task.FinishTime = now
+	if job.FinishedAt != nil {
+		task.FinishTime = *job.FinishedAt
+	}
+	trainedTokens := 0
+	if job.TrainedTokens != nil {
+		trainedTokens = *job.TrainedTokens
+	}
+	quota, modelName, modelRatio, groupRatio := 0, "", 0.0, 0.0
+	if task.Status == model.TaskStatusSuccess {
+		quota, modelName, modelRatio, groupRatio = fineTuningQuota(task, trainedTokens)
+	}
+	params["finish_time"] = task.FinishTime
+	params["progress"] = "100%"
+	params["quota"] = quota
+	finished, err := model.TaskUpdateUnfinished(task.ID, params)
+	if err != nil || !finished {
+		// 任务已由其他请求结算
+		return err
+	}
+	task.Progress = "100%"
+	task.Quota = quota
+	if quota > 0 {
+		consumeFineTuningQuota(ctx, task, trainedTokens, quota, modelName, modelRatio, groupRatio)
+	}
+	return nil
+}
+
+func fineTuningQuota(task *model.Task, trainedTokens int) (quota int, modelName string, modelRatio float64, groupRatio float64) {
+	modelName = CoverTaskActionToModelName(constant.TaskPlatformFineTuning, task.Properties.Model)
+	groupRatio = setting.GetGroupRatio(task.Properties.Group)
+	if modelPrice, ok := common.GetModelPrice(modelName, false); ok {
+		// 按次计费时 modelRatio 记录为 0
+		return int(modelPrice * common.QuotaPerUnit * groupRatio), modelName, 0, groupRatio
+	}
+	modelRatio = common.GetModelRatio(modelName)
+	quota = int(float64(trainedTokens) * modelRatio * groupRatio)
+	if modelRatio != 0 && quota <= 0 {
+		quota = 1
+	}
+	return quota, modelName, modelRatio, groupRatio
+}
+
+func consumeFineTuningQuota(ctx context.Context, task *model.Task, trainedTokens int, quota int, modelName string, modelRatio float64, groupRatio float64) {
+	userQuota, err := model.GetUserQuota(task.UserId, false)
+	if err != nil {
+		logging.LogError(ctx, "get user quota failed: "+err.Error())
+		return
+	}
+	relayInfo := &relaycommon.RelayInfo{
+		UserId:    task.UserId,
+		ChannelId: task.ChannelId,
+		TokenId:   task.Properties.TokenId,
+	}
+	tokenName := ""
+	if token, err := model.GetTokenById(task.Properties.TokenId); err == nil {
+		relayInfo.TokenKey = token.Key
+		tokenName = token.Name
+	} else {
+		// 令牌已被删除时只扣除用户额度
+		relayInfo.IsPlayground = true
+	}
+	if err = model.PostConsumeQuota(relayInfo, userQuota, quota, 0, true); err != nil {
+		logging.LogError(ctx, "error consuming fine-tuning quota: "+err.Error())
+	}
+	logContent := fmt.Sprintf("微调任务 %s 训练 %d tokens，模型倍率 %.2f，分组倍率 %.2f", task.TaskID, trainedTokens, modelRatio, groupRatio)
+	other := make(map[string]interface{})
+	other["model_ratio"] = modelRatio
+	other["group_ratio"] = groupRatio
+	other["trained_tokens"] = trainedTokens
+	model.RecordConsumeLog(ctx, task.UserId, task.ChannelId, trainedTokens, 0, modelName, tokenName,
+		quota, logContent, task.Properties.TokenId, userQuota, int(task.FinishTime-task.SubmitTime), false, task.Properties.Group, other)
+	model.UpdateUserUsedQuotaAndRequestCount(task.UserId, quota)
+	model.UpdateChannelUsedQuota(task.ChannelId, quota)
+}
@@ -1,8 +1,11 @@
 package service
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"one-api/common"
+	"one-api/setting"
 	"time"
 )
 
@@ -10,11 +13,16 @@ var httpClient *http.Client
 var impatientHTTPClient *http.Client
 
 func init() {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialContextWithTimeout(0)
 	if common.RelayTimeout == 0 {
-		httpClient = &http.Client{}
+		httpClient = &http.Client{
+			Transport: transport,
+		}
 	} else {
 		httpClient = &http.Client{
-			Timeout: time.Duration(common.RelayTimeout) * time.Second,
+			Transport: transport,
+			Timeout:   time.Duration(common.RelayTimeout) * time.Second,
 		}
 	}
 
@@ -23,6 +31,21 @@ func init() {
 	}
 }
 
+// dialContextWithTimeout 按连接超时建立连接，timeout 为 0 时每次连接读取全局设置，修改设置后无需重建客户端
+func dialContextWithTimeout(timeout int) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		connectTimeout := timeout
+		if connectTimeout <= 0 {
+			connectTimeout = setting.RelayConnectTimeout
+		}
+		dialer := &net.Dialer{
+			Timeout:   time.Duration(connectTimeout) * time.Second,
+			KeepAlive: 30 * time.Second,
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
+
 func GetHttpClient() *http.Client {
 	return httpClient
 }
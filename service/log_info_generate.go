@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"one-api/dto"
 	relaycommon "one-api/relay/common"
+	"one-api/setting"
 )
 
 func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelRatio, groupRatio, completionRatio, modelPrice float64) map[string]interface{} {
@@ -13,6 +14,23 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	other["completion_ratio"] = completionRatio
 	other["model_price"] = modelPrice
 	other["frt"] = float64(relayInfo.FirstResponseTime.UnixMilli() - relayInfo.StartTime.UnixMilli())
+	if relayInfo.BatchId != "" {
+		other["batch_id"] = relayInfo.BatchId
+		other["batch_discount_ratio"] = setting.BatchDiscountRatio
+	}
+	if relayInfo.ResponseCacheHit {
+		other["response_cache_hit"] = true
+		other["response_cache_ratio"] = setting.ResponseCacheRatio
+	}
+	if modelAlias := ctx.GetString("model_alias"); modelAlias != "" {
+		other["model_alias"] = modelAlias
+	}
+	if requestedModel := ctx.GetString("requested_model"); requestedModel != "" {
+		other["requested_model"] = requestedModel
+	}
+	if queueWaitMs := ctx.GetInt64("queue_wait_ms"); queueWaitMs > 0 {
+		other["queue_wait_ms"] = queueWaitMs
+	}
 	adminInfo := make(map[string]interface{})
 	adminInfo["use_channel"] = ctx.GetStringSlice("use_channel")
 	other["admin_info"] = adminInfo
@@ -0,0 +1,17 @@
+package service
+
+import (
+	"one-api/setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveModelAlias 返回别名对应的实际模型，令牌设置的别名优先于分组设置，不是别名时返回空字符串
+func ResolveModelAlias(c *gin.Context, group string, alias string) string {
+	if tokenAlias, ok := c.Get("token_model_alias"); ok {
+		if modelName := tokenAlias.(map[string]string)[alias]; modelName != "" {
+			return modelName
+		}
+	}
+	return setting.GetGroupModelAlias(group, alias)
+}
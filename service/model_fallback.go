@@ -0,0 +1,105 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"one-api/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModelFallbackHeader 使用降级模型处理请求时，响应头中返回实际使用的模型
+const ModelFallbackHeader = "X-Served-Model"
+
+// ShouldModelFallback 指定渠道的请求不降级
+func ShouldModelFallback(c *gin.Context) bool {
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return false
+	}
+	return CanReplaceRequestModel(c)
+}
+
+// CanReplaceRequestModel 只有模型在 JSON 请求体或 Gemini 路径中的接口支持替换模型（降级、别名）
+func CanReplaceRequestModel(c *gin.Context) bool {
+	switch relayconstant.Path2RelayMode(c.Request.URL.Path) {
+	case relayconstant.RelayModeChatCompletions,
+		relayconstant.RelayModeCompletions,
+		relayconstant.RelayModeEmbeddings,
+		relayconstant.RelayModeModerations,
+		relayconstant.RelayModeImagesGenerations,
+		relayconstant.RelayModeAudioSpeech,
+		relayconstant.RelayModeRerank,
+		relayconstant.RelayModeClaudeMessages,
+		relayconstant.RelayModeGeminiGenerateContent,
+		relayconstant.RelayModeResponses:
+		return true
+	}
+	return false
+}
+
+// GetModelFallbackChain 返回模型的降级链，令牌设置的降级链优先于系统设置，令牌无权访问的模型会被跳过
+func GetModelFallbackChain(c *gin.Context, modelName string) []string {
+	var chain []string
+	if tokenFallback, ok := c.Get("token_model_fallback"); ok {
+		chain = tokenFallback.(map[string][]string)[modelName]
+	}
+	if len(chain) == 0 {
+		chain = setting.GetModelFallback(modelName)
+	}
+	if !c.GetBool("token_model_limit_enabled") {
+		return chain
+	}
+	tokenModelLimit, _ := c.Get("token_model_limit")
+	limitMap, _ := tokenModelLimit.(map[string]bool)
+	allowed := make([]string, 0, len(chain))
+	for _, fallbackModel := range chain {
+		if limitMap[fallbackModel] {
+			allowed = append(allowed, fallbackModel)
+		}
+	}
+	return allowed
+}
+
+// ReplaceRequestModel 将请求中的模型替换为降级后或别名对应的模型，后续的重试与计费都使用新的模型
+func ReplaceRequestModel(c *gin.Context, modelName string) error {
+	if strings.HasPrefix(c.Request.URL.Path, "/v1beta/models/") {
+		// /v1beta/models/gemini-pro:generateContent
+		for i, param := range c.Params {
+			if param.Key != "model" {
+				continue
+			}
+			if index := strings.LastIndex(param.Value, ":"); index >= 0 {
+				c.Params[i].Value = modelName + param.Value[index:]
+			} else {
+				c.Params[i].Value = modelName
+			}
+		}
+		c.Header(ModelFallbackHeader, modelName)
+		return nil
+	}
+	if !strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+		return errors.New("replacing model only supports json request")
+	}
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return err
+	}
+	request := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(requestBody, &request); err != nil {
+		return err
+	}
+	request["model"], _ = json.Marshal(modelName)
+	requestBody, err = json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	c.Set(common.KeyRequestBody, requestBody)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+	c.Header(ModelFallbackHeader, modelName)
+	return nil
+}
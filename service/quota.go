@@ -160,6 +160,9 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	} else {
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		model.UpdateChannelKeyUsedQuota(relayInfo.ChannelKeyId, quota)
+		model.RecordChannelTokens(relayInfo.ChannelId, relayInfo.OriginModelName, totalTokens)
+		RecordRelayRateLimitTokens(ctx, totalTokens)
 	}
 
 	logModel := modelName
@@ -230,6 +233,9 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		}
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		model.UpdateChannelKeyUsedQuota(relayInfo.ChannelKeyId, quota)
+		model.RecordChannelTokens(relayInfo.ChannelId, relayInfo.OriginModelName, totalTokens)
+		RecordRelayRateLimitTokens(ctx, totalTokens)
 	}
 
 	logModel := relayInfo.UpstreamModelName
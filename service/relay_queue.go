@@ -0,0 +1,267 @@
+package service
+
+import (
+	"errors"
+	"one-api/setting"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrRelayQueueFull    = errors.New("relay queue is full")
+	ErrRelayQueueTimeout = errors.New("relay queue wait timeout")
+)
+
+const (
+	// relayQueueRetryInterval 排队请求尝试获取渠道失败后，让出机会给后面请求的时间，也是轮询间隔
+	relayQueueRetryInterval = 200 * time.Millisecond
+)
+
+type relayQueueWaiter struct {
+	group      string
+	userId     int
+	priority   int
+	seq        uint64
+	enqueuedAt time.Time
+	notBefore  time.Time
+	lastTry    time.Time
+}
+
+// relayQueue 同一模型的等待队列，不同分组的请求可能共用渠道，因此在同一队列中按分组优先级调度
+type relayQueue struct {
+	waiters []*relayQueueWaiter
+	// 每个用户已出队的请求数，同一优先级内优先调度出队最少的用户，避免单个用户的突发请求占满队列
+	served map[int]int
+}
+
+// next 返回下一个可以尝试获取渠道的请求，调用方需持有锁
+func (queue *relayQueue) next(now time.Time) *relayQueueWaiter {
+	var best *relayQueueWaiter
+	for _, waiter := range queue.waiters {
+		if now.Before(waiter.notBefore) || now.Sub(waiter.lastTry) < relayQueueRetryInterval {
+			continue
+		}
+		if best == nil || queue.less(waiter, best) {
+			best = waiter
+		}
+	}
+	return best
+}
+
+func (queue *relayQueue) less(a, b *relayQueueWaiter) bool {
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	if queue.served[a.userId] != queue.served[b.userId] {
+		return queue.served[a.userId] < queue.served[b.userId]
+	}
+	return a.seq < b.seq
+}
+
+// RelayQueueStat 分组下单个模型的排队统计
+type RelayQueueStat struct {
+	Group     string `json:"group"`
+	Model     string `json:"model"`
+	Depth     int    `json:"depth"`
+	MaxDepth  int    `json:"max_depth"`
+	Enqueued  int64  `json:"enqueued"`
+	Dequeued  int64  `json:"dequeued"`
+	Timeouts  int64  `json:"timeouts"`
+	Rejected  int64  `json:"rejected"`
+	Canceled  int64  `json:"canceled"`
+	AvgWaitMs int64  `json:"avg_wait_ms"`
+	MaxWaitMs int64  `json:"max_wait_ms"`
+
+	totalWaitMs int64
+}
+
+var (
+	relayQueues      = make(map[string]*relayQueue)
+	relayQueueStats  = make(map[string]*RelayQueueStat)
+	relayQueueSeq    uint64
+	relayQueueSignal = make(chan struct{})
+	relayQueueLock   sync.Mutex
+)
+
+func getRelayQueueStat(group string, modelName string) *RelayQueueStat {
+	key := group + ":" + modelName
+	stat, ok := relayQueueStats[key]
+	if !ok {
+		stat = &RelayQueueStat{Group: group, Model: modelName}
+		relayQueueStats[key] = stat
+	}
+	return stat
+}
+
+// broadcastRelayQueue 唤醒所有排队的请求，调用方需持有锁
+func broadcastRelayQueue() {
+	close(relayQueueSignal)
+	relayQueueSignal = make(chan struct{})
+}
+
+// NotifyRelayQueue 渠道释放并发等容量发生变化时调用，让排在最前面的请求立即尝试
+func NotifyRelayQueue() {
+	relayQueueLock.Lock()
+	defer relayQueueLock.Unlock()
+	if len(relayQueues) == 0 {
+		return
+	}
+	for _, queue := range relayQueues {
+		for _, waiter := range queue.waiters {
+			waiter.lastTry = time.Time{}
+		}
+	}
+	broadcastRelayQueue()
+}
+
+func enqueueRelayQueue(c *gin.Context, group string, modelName string, delay time.Duration) (*relayQueueWaiter, error) {
+	relayQueueLock.Lock()
+	defer relayQueueLock.Unlock()
+	stat := getRelayQueueStat(group, modelName)
+	if stat.Depth >= setting.RelayQueueMaxSize {
+		stat.Rejected++
+		return nil, ErrRelayQueueFull
+	}
+	queue, ok := relayQueues[modelName]
+	if !ok {
+		queue = &relayQueue{served: make(map[int]int)}
+		relayQueues[modelName] = queue
+	}
+	relayQueueSeq++
+	now := time.Now()
+	waiter := &relayQueueWaiter{
+		group:      group,
+		userId:     c.GetInt("id"),
+		priority:   setting.GetGroupQueuePriority(group),
+		seq:        relayQueueSeq,
+		enqueuedAt: now,
+		notBefore:  now.Add(delay),
+	}
+	queue.waiters = append(queue.waiters, waiter)
+	stat.Enqueued++
+	stat.Depth++
+	stat.MaxDepth = max(stat.MaxDepth, stat.Depth)
+	return waiter, nil
+}
+
+// leaveRelayQueue 请求离开队列，served 为 true 表示成功获取到渠道
+func leaveRelayQueue(waiter *relayQueueWaiter, modelName string, served bool) {
+	relayQueueLock.Lock()
+	defer relayQueueLock.Unlock()
+	queue := relayQueues[modelName]
+	for i, w := range queue.waiters {
+		if w == waiter {
+			queue.waiters = append(queue.waiters[:i], queue.waiters[i+1:]...)
+			break
+		}
+	}
+	if served {
+		queue.served[waiter.userId]++
+	}
+	if len(queue.waiters) == 0 {
+		delete(relayQueues, modelName)
+	}
+	stat := getRelayQueueStat(waiter.group, modelName)
+	stat.Depth--
+	if served {
+		waitMs := time.Since(waiter.enqueuedAt).Milliseconds()
+		stat.Dequeued++
+		stat.totalWaitMs += waitMs
+		stat.AvgWaitMs = stat.totalWaitMs / stat.Dequeued
+		stat.MaxWaitMs = max(stat.MaxWaitMs, waitMs)
+	}
+	broadcastRelayQueue()
+}
+
+// WaitRelayQueue 在队列中等待，轮到当前请求时调用 try 尝试获取渠道，成功时返回 nil。
+// try 返回 false 和 nil 表示继续等待，返回错误时立即离开队列并返回该错误。
+// delay 为首次尝试前的最短等待时间，用于上游返回 429 后的退避
+func WaitRelayQueue(c *gin.Context, group string, modelName string, deadline time.Time, delay time.Duration, try func() (bool, error)) error {
+	waiter, err := enqueueRelayQueue(c, group, modelName, delay)
+	if err != nil {
+		return err
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	ticker := time.NewTicker(relayQueueRetryInterval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		relayQueueLock.Lock()
+		isNext := relayQueues[modelName].next(now) == waiter
+		if isNext {
+			waiter.lastTry = now
+		}
+		signal := relayQueueSignal
+		relayQueueLock.Unlock()
+		if isNext {
+			ok, err := try()
+			if err != nil {
+				leaveRelayQueue(waiter, modelName, false)
+				return err
+			}
+			if ok {
+				leaveRelayQueue(waiter, modelName, true)
+				c.Set("queue_wait_ms", time.Since(waiter.enqueuedAt).Milliseconds())
+				return nil
+			}
+			// 让排在后面的请求尝试，它们所在的分组可能还有空闲的渠道
+			relayQueueLock.Lock()
+			broadcastRelayQueue()
+			signal = relayQueueSignal
+			relayQueueLock.Unlock()
+		}
+		select {
+		case <-signal:
+		case <-ticker.C:
+		case <-timer.C:
+			leaveRelayQueue(waiter, modelName, false)
+			relayQueueLock.Lock()
+			getRelayQueueStat(group, modelName).Timeouts++
+			relayQueueLock.Unlock()
+			return ErrRelayQueueTimeout
+		case <-c.Request.Context().Done():
+			leaveRelayQueue(waiter, modelName, false)
+			relayQueueLock.Lock()
+			getRelayQueueStat(group, modelName).Canceled++
+			relayQueueLock.Unlock()
+			return c.Request.Context().Err()
+		}
+	}
+}
+
+// RelayQueueErrorMessage 排队失败时返回给客户端的提示
+func RelayQueueErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrRelayQueueFull):
+		return "当前分组上游负载已饱和，排队人数已满，请稍后再试"
+	case errors.Is(err, ErrRelayQueueTimeout):
+		return "当前分组上游负载已饱和，排队等待超时，请稍后再试"
+	}
+	return "当前分组上游负载已饱和，请稍后再试"
+}
+
+// RelayQueueDeadline 从现在开始计算的最长排队时间
+func RelayQueueDeadline() time.Time {
+	return time.Now().Add(time.Duration(setting.RelayQueueMaxWait) * time.Second)
+}
+
+// GetRelayQueueStats 返回各分组各模型的排队统计
+func GetRelayQueueStats() []RelayQueueStat {
+	relayQueueLock.Lock()
+	stats := make([]RelayQueueStat, 0, len(relayQueueStats))
+	for _, stat := range relayQueueStats {
+		stats = append(stats, *stat)
+	}
+	relayQueueLock.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Group != stats[j].Group {
+			return stats[i].Group < stats[j].Group
+		}
+		return stats[i].Model < stats[j].Model
+	})
+	return stats
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"one-api/logging")
+
+const (
+	relayRateLimitKeyPrefix = "relay_rate_limit"
+	// 并发计数在异常退出时无法释放，超过该时间没有新请求后自动清零
+	relayConcurrencyExpiration = 10 * time.Minute
+
+	RelayRateLimitTypeRequests = "requests"
+	RelayRateLimitTypeTokens   = "tokens"
+)
+
+// RelayRateLimitScope 一个需要计数的限制范围，令牌与用户分别计数
+type RelayRateLimitScope struct {
+	Kind  string
+	Id    int
+	Limit setting.RelayRateLimit
+}
+
+func (scope RelayRateLimitScope) concurrencyKey() string {
+	return fmt.Sprintf("%s:concurrency:%s:%d", relayRateLimitKeyPrefix, scope.Kind, scope.Id)
+}
+
+func (scope RelayRateLimitScope) minuteKey(kind string, now time.Time) string {
+	return fmt.Sprintf("%s:%s:%s:%d:%d", relayRateLimitKeyPrefix, kind, scope.Kind, scope.Id, now.Unix()/60)
+}
+
+// RelayRateLimitError 超过限制时返回给客户端的错误
+type RelayRateLimitError struct {
+	Type    string
+	Message string
+}
+
+func (e *RelayRateLimitError) Error() string {
+	return e.Message
+}
+
+// GetRelayRateLimitScopes 返回当前请求需要检查的令牌与用户限制，结果缓存在上下文中
+func GetRelayRateLimitScopes(c *gin.Context) []RelayRateLimitScope {
+	if scopes, ok := c.Get("relay_rate_limit_scopes"); ok {
+		return scopes.([]RelayRateLimitScope)
+	}
+	var scopes []RelayRateLimitScope
+	if tokenLimit, ok := c.Get("token_rate_limit"); ok {
+		if limit := tokenLimit.(setting.RelayRateLimit); !limit.IsEmpty() {
+			scopes = append(scopes, RelayRateLimitScope{Kind: "token", Id: c.GetInt("token_id"), Limit: limit})
+		}
+	}
+	userId := c.GetInt("id")
+	userLimit, err := model.GetUserRateLimit(userId, c.GetString("group"), false)
+	if err != nil {
+		logging.SysError("failed to get user rate limit: " + err.Error())
+	}
+	if !userLimit.IsEmpty() {
+		scopes = append(scopes, RelayRateLimitScope{Kind: "user", Id: userId, Limit: userLimit})
+	}
+	c.Set("relay_rate_limit_scopes", scopes)
+	return scopes
+}
+
+// AcquireRelayRateLimit 请求开始时检查并增加每分钟请求数，流式请求同时占用并发数，
+// 返回的函数用于在请求结束时释放并发
+func AcquireRelayRateLimit(c *gin.Context, scopes []RelayRateLimitScope, isStream bool) (func(), *RelayRateLimitError) {
+	now := time.Now()
+	var acquired []RelayRateLimitScope
+	release := func() {
+		for _, scope := range acquired {
+			common.IncrLimitCounter(scope.concurrencyKey(), -1, relayConcurrencyExpiration)
+		}
+	}
+	for _, scope := range scopes {
+		if scope.Limit.TPM > 0 && common.GetLimitCounter(scope.minuteKey("tpm", now)) >= int64(scope.Limit.TPM) {
+			release()
+			return nil, &RelayRateLimitError{
+				Type:    RelayRateLimitTypeTokens,
+				Message: fmt.Sprintf("Rate limit reached for %s on tokens per min (TPM): Limit %d", scope.Kind, scope.Limit.TPM),
+			}
+		}
+		if isStream && scope.Limit.MaxConcurrency > 0 {
+			if common.IncrLimitCounter(scope.concurrencyKey(), 1, relayConcurrencyExpiration) > int64(scope.Limit.MaxConcurrency) {
+				common.IncrLimitCounter(scope.concurrencyKey(), -1, relayConcurrencyExpiration)
+				release()
+				return nil, &RelayRateLimitError{
+					Type:    RelayRateLimitTypeRequests,
+					Message: fmt.Sprintf("Rate limit reached for %s on concurrent streams: Limit %d", scope.Kind, scope.Limit.MaxConcurrency),
+				}
+			}
+			acquired = append(acquired, scope)
+		}
+	}
+	// 所有检查通过后再计数，被拒绝的请求不占用每分钟请求数
+	for i, scope := range scopes {
+		if scope.Limit.RPM <= 0 {
+			continue
+		}
+		if common.IncrLimitCounter(scope.minuteKey("rpm", now), 1, 2*time.Minute) > int64(scope.Limit.RPM) {
+			for _, counted := range scopes[:i+1] {
+				if counted.Limit.RPM > 0 {
+					common.IncrLimitCounter(counted.minuteKey("rpm", now), -1, 2*time.Minute)
+				}
+			}
+			release()
+			return nil, &RelayRateLimitError{
+				Type:    RelayRateLimitTypeRequests,
+				Message: fmt.Sprintf("Rate limit reached for %s on requests per min (RPM): Limit %d", scope.Kind, scope.Limit.RPM),
+			}
+		}
+	}
+	return release, nil
+}
+
+// CheckRelayTokenRateLimit 得到提示 token 数后检查本次请求是否会超过每分钟 token 数
+func CheckRelayTokenRateLimit(c *gin.Context, promptTokens int) *dto.OpenAIErrorWithStatusCode {
+	now := time.Now()
+	for _, scope := range GetRelayRateLimitScopes(c) {
+		if scope.Limit.TPM <= 0 {
+			continue
+		}
+		used := common.GetLimitCounter(scope.minuteKey("tpm", now))
+		if used+int64(promptTokens) <= int64(scope.Limit.TPM) {
+			continue
+		}
+		SetRelayRateLimitHeaders(c, []RelayRateLimitScope{scope})
+		return &dto.OpenAIErrorWithStatusCode{
+			Error: dto.OpenAIError{
+				Message: fmt.Sprintf("Rate limit reached for %s on tokens per min (TPM): Limit %d, Used %d, Requested %d", scope.Kind, scope.Limit.TPM, used, promptTokens),
+				Type:    RelayRateLimitTypeTokens,
+				Code:    "rate_limit_exceeded",
+			},
+			StatusCode: http.StatusTooManyRequests,
+			LocalError: true,
+		}
+	}
+	return nil
+}
+
+// RecordRelayRateLimitTokens 请求结束后累计令牌与用户每分钟的 token 数
+func RecordRelayRateLimitTokens(c *gin.Context, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	now := time.Now()
+	for _, scope := range GetRelayRateLimitScopes(c) {
+		if scope.Limit.TPM > 0 {
+			common.IncrLimitCounter(scope.minuteKey("tpm", now), int64(tokens), 2*time.Minute)
+		}
+	}
+}
+
+// SetRelayRateLimitHeaders 设置 OpenAI 风格的 x-ratelimit-* 响应头，多个范围时使用剩余最少的
+func SetRelayRateLimitHeaders(c *gin.Context, scopes []RelayRateLimitScope) {
+	now := time.Now()
+	reset := strconv.FormatInt(60-now.Unix()%60, 10) + "s"
+	requestLimit, requestRemaining := int64(-1), int64(-1)
+	tokenLimit, tokenRemaining := int64(-1), int64(-1)
+	for _, scope := range scopes {
+		if scope.Limit.RPM > 0 {
+			remaining := max(int64(scope.Limit.RPM)-common.GetLimitCounter(scope.minuteKey("rpm", now)), 0)
+			if requestRemaining < 0 || remaining < requestRemaining {
+				requestLimit, requestRemaining = int64(scope.Limit.RPM), remaining
+			}
+		}
+		if scope.Limit.TPM > 0 {
+			remaining := max(int64(scope.Limit.TPM)-common.GetLimitCounter(scope.minuteKey("tpm", now)), 0)
+			if tokenRemaining < 0 || remaining < tokenRemaining {
+				tokenLimit, tokenRemaining = int64(scope.Limit.TPM), remaining
+			}
+		}
+	}
+	if requestLimit >= 0 {
+		c.Header("x-ratelimit-limit-requests", strconv.FormatInt(requestLimit, 10))
+		c.Header("x-ratelimit-remaining-requests", strconv.FormatInt(requestRemaining, 10))
+		c.Header("x-ratelimit-reset-requests", reset)
+	}
+	if tokenLimit >= 0 {
+		c.Header("x-ratelimit-limit-tokens", strconv.FormatInt(tokenLimit, 10))
+		c.Header("x-ratelimit-remaining-tokens", strconv.FormatInt(tokenRemaining, 10))
+		c.Header("x-ratelimit-reset-tokens", reset)
+	}
+}
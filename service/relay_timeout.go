@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logging"
+	"one-api/setting"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrFirstByteTimeout  = errors.New("upstream first byte timeout")
+	ErrStreamIdleTimeout = errors.New("upstream stream idle timeout")
+)
+
+// defaultStreamIdleTimeout 全局与渠道都未设置流式空闲超时，且 STREAMING_TIMEOUT 也为 0 时使用的兜底超时
+const defaultStreamIdleTimeout = 60 * time.Second
+
+// StreamTimeoutErrorCode 客户端尚未收到任何内容时的流式超时（空闲超时、首包前的保持超时）错误码，可以换渠道重试
+const StreamTimeoutErrorCode = "stream_idle_timeout"
+
+// RelayTimeouts 当前渠道生效的分段超时，0 表示不限制（流式空闲超时总是有效）
+type RelayTimeouts struct {
+	FirstByte       time.Duration
+	StreamIdle      time.Duration
+	StreamKeepalive time.Duration
+}
+
+// GetRelayTimeouts 返回渠道的分段超时，渠道未设置的项使用全局设置
+func GetRelayTimeouts(channelSetting map[string]interface{}) RelayTimeouts {
+	channelNetworkSetting, _ := GetChannelNetworkSetting(channelSetting)
+	pick := func(channelValue int, globalValue int) time.Duration {
+		if channelValue > 0 {
+			return time.Duration(channelValue) * time.Second
+		}
+		return time.Duration(max(globalValue, 0)) * time.Second
+	}
+	streamIdle := pick(channelNetworkSetting.StreamIdleTimeout, setting.RelayStreamIdleTimeout)
+	if streamIdle <= 0 {
+		// 流式空闲超时不能关闭，否则上游挂起时请求会一直占用，未设置时使用 STREAMING_TIMEOUT 兜底
+		streamIdle = time.Duration(constant.StreamingTimeout) * time.Second
+		if streamIdle <= 0 {
+			streamIdle = defaultStreamIdleTimeout
+		}
+	}
+	return RelayTimeouts{
+		FirstByte:       pick(channelNetworkSetting.FirstByteTimeout, setting.RelayFirstByteTimeout),
+		StreamIdle:      streamIdle,
+		StreamKeepalive: pick(channelNetworkSetting.StreamKeepaliveInterval, setting.StreamKeepaliveInterval),
+	}
+}
+
+// DoRequestWithFirstByteTimeout 发送请求，超过 timeout 仍未收到响应头时取消请求；
+// 收到响应后不再限制，取消函数在响应体关闭时释放
+func DoRequestWithFirstByteTimeout(client *http.Client, req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if timeout <= 0 {
+		return client.Do(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(timeout, cancel)
+	resp, err := client.Do(req.WithContext(ctx))
+	if !timer.Stop() {
+		cancel()
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return nil, fmt.Errorf("%w after %s", ErrFirstByteTimeout, timeout)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+type streamReadResult struct {
+	n   int
+	err error
+}
+
+// streamIdleReader 包装上游的流式响应体，超过空闲时间没有数据时关闭连接，使读取返回 ErrStreamIdleTimeout；
+// 开启保活后，等待数据期间定期向客户端发送 SSE 注释
+type streamIdleReader struct {
+	c                 *gin.Context
+	body              io.ReadCloser
+	idleTimeout       time.Duration
+	keepaliveInterval time.Duration
+	keepalive         atomic.Bool
+	timedOut          atomic.Bool
+
+	buf     []byte
+	pending []byte
+	reading bool
+	results chan streamReadResult
+	err     error
+}
+
+// NewStreamIdleReader 按空闲超时与保活间隔包装流式响应体，两者都未设置时原样返回
+func NewStreamIdleReader(c *gin.Context, body io.ReadCloser, timeouts RelayTimeouts) io.ReadCloser {
+	if timeouts.StreamIdle <= 0 && timeouts.StreamKeepalive <= 0 {
+		return body
+	}
+	return &streamIdleReader{
+		c:                 c,
+		body:              body,
+		idleTimeout:       timeouts.StreamIdle,
+		keepaliveInterval: timeouts.StreamKeepalive,
+		results:           make(chan streamReadResult, 1),
+	}
+}
+
+// Read 在后台协程中读取上游，同一时间只有一个读取在进行，读取结果在下次调用时返回剩余部分
+func (r *streamIdleReader) Read(p []byte) (int, error) {
+	if len(r.pending) > 0 {
+		n := copy(p, r.pending)
+		r.pending = r.pending[n:]
+		return n, nil
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	if !r.reading {
+		if cap(r.buf) < len(p) {
+			r.buf = make([]byte, len(p))
+		}
+		buf := r.buf[:len(p)]
+		r.reading = true
+		go func() {
+			n, err := r.body.Read(buf)
+			r.results <- streamReadResult{n: n, err: err}
+		}()
+	}
+	var idle <-chan time.Time
+	if r.idleTimeout > 0 {
+		timer := time.NewTimer(r.idleTimeout)
+		defer timer.Stop()
+		idle = timer.C
+	}
+	var keepalive <-chan time.Time
+	if r.keepaliveInterval > 0 {
+		ticker := time.NewTicker(r.keepaliveInterval)
+		defer ticker.Stop()
+		keepalive = ticker.C
+	}
+	for {
+		select {
+		case result := <-r.results:
+			r.reading = false
+			n := copy(p, r.buf[:result.n])
+			r.pending = r.buf[n:result.n]
+			r.err = result.err
+			if len(r.pending) > 0 {
+				return n, nil
+			}
+			return n, result.err
+		case <-keepalive:
+			r.sendKeepalive()
+		case <-idle:
+			r.timedOut.Store(true)
+			r.err = ErrStreamIdleTimeout
+			_ = r.body.Close()
+			return 0, ErrStreamIdleTimeout
+		}
+	}
+}
+
+// sendKeepalive 只在首个事件已经输出给客户端后发送：保活本身会使响应变为已写入，
+// 在此之前发送会导致首个数据块之前的超时无法重试
+func (r *streamIdleReader) sendKeepalive() {
+	if !r.keepalive.Load() || r.c.Writer.Header().Get("Content-Type") != "text/event-stream" {
+		return
+	}
+	if r.c.Writer.Size() <= 0 {
+		return
+	}
+	if _, err := r.c.Writer.WriteString(": keepalive\n\n"); err == nil {
+		r.c.Writer.Flush()
+	}
+}
+
+func (r *streamIdleReader) Close() error {
+	return r.body.Close()
+}
+
+// EnableStreamKeepalive 允许在等待上游数据时向客户端发送保活注释。保活在读取上游的协程中写入，
+// 调用方需保证读取上游与写入客户端在同一协程，并且每次写入完整的事件
+func EnableStreamKeepalive(body io.Reader) {
+	if reader, ok := body.(*streamIdleReader); ok {
+		reader.keepalive.Store(true)
+	}
+}
+
+// IsStreamIdleTimeout 上游流式响应是否因空闲超时被中断
+func IsStreamIdleTimeout(body io.Reader) bool {
+	reader, ok := body.(*streamIdleReader)
+	return ok && reader.timedOut.Load()
+}
+
+// StreamIdleTimeoutError 流式响应空闲超时且尚未向客户端输出任何内容时返回可以重试的错误，
+// 已经输出时只记录日志，已输出的内容照常计费
+func StreamIdleTimeoutError(c *gin.Context, resp *http.Response) *dto.OpenAIErrorWithStatusCode {
+	if resp == nil || !IsStreamIdleTimeout(resp.Body) {
+		return nil
+	}
+	if c.Writer.Written() {
+		logging.LogError(c, "upstream stream idle timeout after response started")
+		return nil
+	}
+	return OpenAIErrorWrapper(ErrStreamIdleTimeout, StreamTimeoutErrorCode, http.StatusGatewayTimeout)
+}
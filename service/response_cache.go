@@ -0,0 +1,139 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logging"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseCacheHeader 请求头为 true 时主动使用缓存，为 false 时不使用缓存；命中缓存时响应头为 hit
+const ResponseCacheHeader = "X-Response-Cache"
+
+const responseCacheKeyPrefix = "response_cache"
+
+type responseMemoryCacheEntry struct {
+	data     []byte
+	expireAt time.Time
+}
+
+var (
+	responseMemoryCache     = make(map[string]responseMemoryCacheEntry)
+	responseMemoryCacheLock sync.Mutex
+)
+
+// ShouldUseResponseCache 判断请求能否使用响应缓存：embeddings 的结果是确定的，直接缓存；
+// chat completions 需要显式指定 temperature 为 0，或通过请求头开启
+func ShouldUseResponseCache(c *gin.Context, relayMode int) bool {
+	if !setting.ResponseCacheEnabled {
+		return false
+	}
+	header := strings.ToLower(c.GetHeader(ResponseCacheHeader))
+	if header == "false" {
+		return false
+	}
+	switch relayMode {
+	case relayconstant.RelayModeEmbeddings:
+		return true
+	case relayconstant.RelayModeChatCompletions:
+		if header == "true" {
+			return true
+		}
+		// GeneralOpenAIRequest 无法区分未设置与设置为 0，需要读取原始请求
+		var request struct {
+			Temperature *float64 `json:"temperature"`
+		}
+		if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+			return false
+		}
+		return request.Temperature != nil && *request.Temperature == 0
+	}
+	return false
+}
+
+// ResponseCacheKey 按用户隔离缓存，stream、stream_options、user 不影响响应内容，不参与计算
+func ResponseCacheKey(userId int, relayMode int, request *dto.GeneralOpenAIRequest) (string, error) {
+	normalized := *request
+	normalized.Stream = false
+	normalized.StreamOptions = nil
+	normalized.User = ""
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return fmt.Sprintf("%s:%d:%d:%s", responseCacheKeyPrefix, userId, relayMode, hex.EncodeToString(hash[:])), nil
+}
+
+func GetResponseCache(key string) ([]byte, bool) {
+	if common.RedisEnabled {
+		value, err := common.RedisGet(key)
+		if err != nil {
+			return nil, false
+		}
+		return []byte(value), true
+	}
+	responseMemoryCacheLock.Lock()
+	defer responseMemoryCacheLock.Unlock()
+	entry, ok := responseMemoryCache[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expireAt) {
+		delete(responseMemoryCache, key)
+		return nil, false
+	}
+	return entry.data, true
+}
+
+func SetResponseCache(key string, data []byte) {
+	ttl := time.Duration(setting.ResponseCacheTTL) * time.Second
+	if ttl <= 0 || len(data) == 0 || len(data) > constant.ResponseCacheMaxEntryKB*1024 {
+		return
+	}
+	if common.RedisEnabled {
+		if err := common.RedisSet(key, string(data), ttl); err != nil {
+			logging.SysError("set response cache failed: " + err.Error())
+		}
+		return
+	}
+	if constant.ResponseCacheMemoryEntries <= 0 {
+		return
+	}
+	responseMemoryCacheLock.Lock()
+	defer responseMemoryCacheLock.Unlock()
+	if len(responseMemoryCache) >= constant.ResponseCacheMemoryEntries {
+		evictResponseMemoryCache()
+	}
+	responseMemoryCache[key] = responseMemoryCacheEntry{
+		data:     data,
+		expireAt: time.Now().Add(ttl),
+	}
+}
+
+// evictResponseMemoryCache 先清理过期的缓存，仍然已满时随机淘汰
+func evictResponseMemoryCache() {
+	now := time.Now()
+	for key, entry := range responseMemoryCache {
+		if now.After(entry.expireAt) {
+			delete(responseMemoryCache, key)
+		}
+	}
+	for key := range responseMemoryCache {
+		if len(responseMemoryCache) < constant.ResponseCacheMemoryEntries {
+			break
+		}
+		delete(responseMemoryCache, key)
+	}
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StickySessionHeader 客户端可以通过该请求头指定会话，同一会话的请求使用同一个渠道
+const StickySessionHeader = "X-Session-Id"
+
+// GetStickyRoutingKey 返回粘性路由使用的 key，优先使用会话请求头，其次是请求体中的 user 字段，最后是令牌
+func GetStickyRoutingKey(c *gin.Context, user string) string {
+	userId := c.GetInt("id")
+	if session := strings.TrimSpace(c.GetHeader(StickySessionHeader)); session != "" {
+		return fmt.Sprintf("session:%d:%s", userId, session)
+	}
+	if user != "" {
+		return fmt.Sprintf("user:%d:%s", userId, user)
+	}
+	return fmt.Sprintf("token:%d", c.GetInt("token_id"))
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"one-api/dto"
+	"reflect"
+	"strings"
+)
+
+// StructuredOutputSchema 返回 response_format 中的 JSON Schema，类型不是 json_schema 或没有 schema 时返回 nil
+func StructuredOutputSchema(format *dto.ResponseFormat) map[string]any {
+	if format == nil || format.Type != "json_schema" || format.JsonSchema == nil {
+		return nil
+	}
+	schema, _ := format.JsonSchema.Schema.(map[string]any)
+	return schema
+}
+
+// StructuredOutputPrompt 把 response_format 转换为系统提示词，用于不支持原生结构化输出的上游，不需要 JSON 输出时返回空字符串
+func StructuredOutputPrompt(format *dto.ResponseFormat) string {
+	if format == nil {
+		return ""
+	}
+	switch format.Type {
+	case "json_object":
+		return "Respond only with a single valid JSON object. Do not wrap it in markdown code fences or add any text before or after it."
+	case "json_schema":
+		schema := StructuredOutputSchema(format)
+		if schema == nil {
+			return "Respond only with a single valid JSON object. Do not wrap it in markdown code fences or add any text before or after it."
+		}
+		schemaJson, err := json.Marshal(schema)
+		if err != nil {
+			return ""
+		}
+		prompt := "Respond only with a single valid JSON value that conforms to the following JSON Schema. " +
+			"Do not wrap it in markdown code fences or add any text before or after it.\n"
+		if format.JsonSchema.Description != "" {
+			prompt += "Description: " + format.JsonSchema.Description + "\n"
+		}
+		return prompt + "JSON Schema: " + string(schemaJson)
+	}
+	return ""
+}
+
+// ApplyStructuredOutputPrompt 将 response_format 转换为系统提示词并从请求中移除，用于上游不支持 response_format 的适配器
+func ApplyStructuredOutputPrompt(request *dto.GeneralOpenAIRequest) {
+	prompt := StructuredOutputPrompt(request.ResponseFormat)
+	request.ResponseFormat = nil
+	if prompt == "" {
+		return
+	}
+	messages := make([]dto.Message, 0, len(request.Messages)+1)
+	if len(request.Messages) > 0 && request.Messages[0].Role == "system" && request.Messages[0].IsStringContent() {
+		systemMessage := request.Messages[0]
+		systemMessage.SetStringContent(systemMessage.StringContent() + "\n\n" + prompt)
+		messages = append(messages, systemMessage)
+		messages = append(messages, request.Messages[1:]...)
+	} else {
+		systemMessage := dto.Message{Role: "system"}
+		systemMessage.SetStringContent(prompt)
+		messages = append(messages, systemMessage)
+		messages = append(messages, request.Messages...)
+	}
+	request.Messages = messages
+}
+
+// ValidateStructuredOutput 校验模型输出是否符合 response_format，json_object 只要求是 JSON 对象
+func ValidateStructuredOutput(format *dto.ResponseFormat, content string) error {
+	if format == nil || (format.Type != "json_object" && format.Type != "json_schema") {
+		return nil
+	}
+	var value any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(content)), &value); err != nil {
+		return fmt.Errorf("response is not valid json: %s", err.Error())
+	}
+	schema := StructuredOutputSchema(format)
+	if schema == nil {
+		if _, ok := value.(map[string]any); !ok {
+			return errors.New("response is not a json object")
+		}
+		return nil
+	}
+	return ValidateJSONSchema(schema, value)
+}
+
+// ValidateJSONSchema 按 JSON Schema 校验 JSON 值，value 需由 encoding/json 解码得到。
+// 只检查模拟结构化输出需要保证的结构：type、enum、properties、required、additionalProperties、items、anyOf
+// 以及文档内的 $ref，长度、数值范围、pattern 等约束和其他关键字忽略
+func ValidateJSONSchema(schema map[string]any, value any) error {
+	return validateJSONSchema(schema, schema, value, "$", 0)
+}
+
+// jsonSchemaMaxDepth 限制 $ref 递归展开的深度，避免自引用的 schema 无限递归
+const jsonSchemaMaxDepth = 64
+
+func validateJSONSchema(root map[string]any, schema map[string]any, value any, path string, depth int) error {
+	if depth > jsonSchemaMaxDepth {
+		return fmt.Errorf("%s: schema is nested too deeply", path)
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := resolveSchemaRef(root, ref)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err.Error())
+		}
+		return validateJSONSchema(root, resolved, value, path, depth+1)
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok && len(anyOf) > 0 {
+		matched := false
+		for _, item := range anyOf {
+			if subSchema, ok := item.(map[string]any); ok && validateJSONSchema(root, subSchema, value, path, depth+1) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value does not match any schema in anyOf", path)
+		}
+	}
+	if types, ok := schema["type"]; ok && !matchesSchemaType(types, value) {
+		return fmt.Errorf("%s: expected type %v, got %s", path, types, jsonTypeName(value))
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		matched := false
+		for _, item := range enum {
+			if reflect.DeepEqual(item, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value is not one of the allowed values", path)
+		}
+	}
+	switch typed := value.(type) {
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if key, ok := name.(string); ok {
+					if _, exists := typed[key]; !exists {
+						return fmt.Errorf("%s: missing required property %s", path, key)
+					}
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		for key, item := range typed {
+			if propertySchema, ok := properties[key].(map[string]any); ok {
+				if err := validateJSONSchema(root, propertySchema, item, path+"."+key, depth+1); err != nil {
+					return err
+				}
+			} else if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				return fmt.Errorf("%s: additional property %s is not allowed", path, key)
+			}
+		}
+	case []any:
+		if itemSchema, ok := schema["items"].(map[string]any); ok {
+			for i, item := range typed {
+				if err := validateJSONSchema(root, itemSchema, item, fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolveSchemaRef 解析文档内的引用，例如 #/$defs/address 或 #/definitions/address
+func resolveSchemaRef(root map[string]any, ref string) (map[string]any, error) {
+	if ref == "#" {
+		return root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %s", ref)
+	}
+	var current any = root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %s", ref)
+		}
+		if current, ok = object[token]; !ok {
+			return nil, fmt.Errorf("unresolvable $ref %s", ref)
+		}
+	}
+	resolved, ok := current.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unresolvable $ref %s", ref)
+	}
+	return resolved, nil
+}
+
+func matchesSchemaType(types any, value any) bool {
+	switch typed := types.(type) {
+	case string:
+		return matchesJSONType(typed, value)
+	case []any:
+		for _, item := range typed {
+			if name, ok := item.(string); ok && matchesJSONType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesJSONType(name string, value any) bool {
+	switch name {
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	}
+	return jsonTypeName(value) == name
+}
+
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
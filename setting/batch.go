@@ -0,0 +1,4 @@
+package setting
+
+// BatchDiscountRatio batch 请求的计费折扣，叠加在分组倍率上
+var BatchDiscountRatio = 0.5
@@ -0,0 +1,54 @@
+package setting
+
+import (
+	"encoding/json"
+	"errors"
+	"one-api/logging"
+)
+
+const (
+	// ChannelSelectWeighted 同一优先级内按渠道权重随机选择
+	ChannelSelectWeighted = "weighted"
+	// ChannelSelectHealth 在渠道权重的基础上，按最近的成功率与耗时自动降低慢速或出错渠道的权重
+	ChannelSelectHealth = "health"
+	// ChannelSelectSticky 相同会话的请求在同一优先级内固定选择同一个渠道，以便命中上游的提示词缓存，
+	// 该渠道不可用时按权重随机选择
+	ChannelSelectSticky = "sticky"
+)
+
+// groupChannelSelect 分组使用的渠道选择策略，未设置的分组使用 weighted
+var groupChannelSelect = map[string]string{}
+
+func GroupChannelSelect2JSONString() string {
+	jsonBytes, err := json.Marshal(groupChannelSelect)
+	if err != nil {
+		logging.SysError("error marshalling group channel select: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupChannelSelectByJSONString(jsonStr string) error {
+	groupChannelSelect = make(map[string]string)
+	return json.Unmarshal([]byte(jsonStr), &groupChannelSelect)
+}
+
+func GetGroupChannelSelect(group string) string {
+	if strategy, ok := groupChannelSelect[group]; ok {
+		return strategy
+	}
+	return ChannelSelectWeighted
+}
+
+func CheckGroupChannelSelect(jsonStr string) error {
+	checkGroupChannelSelect := make(map[string]string)
+	err := json.Unmarshal([]byte(jsonStr), &checkGroupChannelSelect)
+	if err != nil {
+		return err
+	}
+	for group, strategy := range checkGroupChannelSelect {
+		if strategy != ChannelSelectWeighted && strategy != ChannelSelectHealth && strategy != ChannelSelectSticky {
+			return errors.New("unknown channel select strategy for group " + group + ": " + strategy)
+		}
+	}
+	return nil
+}
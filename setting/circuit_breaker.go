@@ -0,0 +1,17 @@
+package setting
+
+// CircuitBreakerEnabled 开启后，渠道（以及渠道下的单个模型）在窗口期内连续失败达到阈值时熔断，
+// 熔断期间不再被选中，冷却结束后进入半开状态放行少量探测请求，探测成功即恢复
+var CircuitBreakerEnabled = false
+
+// CircuitBreakerFailureThreshold 窗口期内触发熔断的失败次数
+var CircuitBreakerFailureThreshold = 5
+
+// CircuitBreakerWindow 统计失败次数的窗口期，单位秒
+var CircuitBreakerWindow = 60
+
+// CircuitBreakerOpenDuration 熔断后进入半开状态前的冷却时间，单位秒
+var CircuitBreakerOpenDuration = 30
+
+// CircuitBreakerProbeInterval 半开状态下两次探测请求的最小间隔，单位秒
+var CircuitBreakerProbeInterval = 5
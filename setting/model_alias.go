@@ -0,0 +1,60 @@
+package setting
+
+import (
+	"encoding/json"
+	"errors"
+	"one-api/logging"
+)
+
+// groupModelAlias 分组的模型别名，例如 {"default": {"default-model": "gpt-4o-mini"}}
+// 请求的模型是别名时，在选择渠道之前替换为实际的模型，令牌设置的别名优先
+var groupModelAlias = map[string]map[string]string{}
+
+func GroupModelAlias2JSONString() string {
+	jsonBytes, err := json.Marshal(groupModelAlias)
+	if err != nil {
+		logging.SysError("error marshalling group model alias: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupModelAliasByJSONString(jsonStr string) error {
+	groupModelAlias = make(map[string]map[string]string)
+	return json.Unmarshal([]byte(jsonStr), &groupModelAlias)
+}
+
+func GetGroupModelAlias(group string, alias string) string {
+	return groupModelAlias[group][alias]
+}
+
+func CheckGroupModelAlias(jsonStr string) error {
+	checkGroupModelAlias := make(map[string]map[string]string)
+	err := json.Unmarshal([]byte(jsonStr), &checkGroupModelAlias)
+	if err != nil {
+		return err
+	}
+	for _, aliases := range checkGroupModelAlias {
+		if err = checkModelAlias(aliases); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckModelAlias 校验令牌的模型别名，格式为 {"别名": "实际模型"}
+func CheckModelAlias(jsonStr string) error {
+	aliases := make(map[string]string)
+	if err := json.Unmarshal([]byte(jsonStr), &aliases); err != nil {
+		return err
+	}
+	return checkModelAlias(aliases)
+}
+
+func checkModelAlias(aliases map[string]string) error {
+	for alias, modelName := range aliases {
+		if alias == "" || modelName == "" || alias == modelName {
+			return errors.New("invalid model alias " + alias)
+		}
+	}
+	return nil
+}
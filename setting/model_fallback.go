@@ -0,0 +1,44 @@
+package setting
+
+import (
+	"encoding/json"
+	"errors"
+	"one-api/logging"
+)
+
+// modelFallback 模型的降级链，例如 {"gpt-4o": ["claude-3-5-sonnet-20240620", "gpt-4o-mini"]}
+// 请求的模型没有可用渠道或所有重试都失败时，按顺序尝试降级链中的模型
+var modelFallback = map[string][]string{}
+
+func ModelFallback2JSONString() string {
+	jsonBytes, err := json.Marshal(modelFallback)
+	if err != nil {
+		logging.SysError("error marshalling model fallback: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateModelFallbackByJSONString(jsonStr string) error {
+	modelFallback = make(map[string][]string)
+	return json.Unmarshal([]byte(jsonStr), &modelFallback)
+}
+
+func GetModelFallback(modelName string) []string {
+	return modelFallback[modelName]
+}
+
+func CheckModelFallback(jsonStr string) error {
+	checkModelFallback := make(map[string][]string)
+	err := json.Unmarshal([]byte(jsonStr), &checkModelFallback)
+	if err != nil {
+		return err
+	}
+	for name, chain := range checkModelFallback {
+		for _, fallbackModel := range chain {
+			if fallbackModel == "" || fallbackModel == name {
+				return errors.New("invalid fallback model for " + name)
+			}
+		}
+	}
+	return nil
+}
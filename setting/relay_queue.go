@@ -0,0 +1,41 @@
+package setting
+
+import (
+	"encoding/json"
+	"one-api/logging"
+)
+
+// RelayQueueEnabled 开启后，分组内的渠道全部饱和（达到上游限制或返回 429）时请求进入等待队列，
+// 而不是立即返回 429
+var RelayQueueEnabled = false
+
+// RelayQueueMaxSize 每个分组每个模型最多排队的请求数，超过时直接返回 429
+var RelayQueueMaxSize = 100
+
+// RelayQueueMaxWait 请求在队列中的最长等待时间，单位秒
+var RelayQueueMaxWait = 30
+
+// groupQueuePriority 分组出队优先级，数值越大越先出队，未设置的分组为 0
+var groupQueuePriority = map[string]int{}
+
+func GroupQueuePriority2JSONString() string {
+	jsonBytes, err := json.Marshal(groupQueuePriority)
+	if err != nil {
+		logging.SysError("error marshalling group queue priority: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupQueuePriorityByJSONString(jsonStr string) error {
+	groupQueuePriority = make(map[string]int)
+	return json.Unmarshal([]byte(jsonStr), &groupQueuePriority)
+}
+
+func GetGroupQueuePriority(group string) int {
+	return groupQueuePriority[group]
+}
+
+func CheckGroupQueuePriority(jsonStr string) error {
+	checkGroupQueuePriority := make(map[string]int)
+	return json.Unmarshal([]byte(jsonStr), &checkGroupQueuePriority)
+}
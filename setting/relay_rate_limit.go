@@ -0,0 +1,95 @@
+package setting
+
+import (
+	"encoding/json"
+	"errors"
+	"one-api/logging"
+)
+
+// RelayRateLimit 中继请求限制，0 表示不限制
+// MaxConcurrency 只限制同时进行的流式请求
+type RelayRateLimit struct {
+	RPM            int `json:"rpm"`
+	TPM            int `json:"tpm"`
+	MaxConcurrency int `json:"max_concurrency"`
+}
+
+func (limit RelayRateLimit) IsEmpty() bool {
+	return limit.RPM <= 0 && limit.TPM <= 0 && limit.MaxConcurrency <= 0
+}
+
+// Merge 使用 override 中非 0 的字段覆盖当前限制
+func (limit RelayRateLimit) Merge(override RelayRateLimit) RelayRateLimit {
+	if override.RPM != 0 {
+		limit.RPM = override.RPM
+	}
+	if override.TPM != 0 {
+		limit.TPM = override.TPM
+	}
+	if override.MaxConcurrency != 0 {
+		limit.MaxConcurrency = override.MaxConcurrency
+	}
+	return limit
+}
+
+func (limit RelayRateLimit) check() error {
+	if limit.RPM < 0 || limit.TPM < 0 || limit.MaxConcurrency < 0 {
+		return errors.New("rate limit must not be negative")
+	}
+	return nil
+}
+
+// groupRelayRateLimit 分组内每个用户的默认限制，用户可单独设置覆盖
+var groupRelayRateLimit = map[string]RelayRateLimit{}
+
+func GroupRelayRateLimit2JSONString() string {
+	jsonBytes, err := json.Marshal(groupRelayRateLimit)
+	if err != nil {
+		logging.SysError("error marshalling group relay rate limit: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupRelayRateLimitByJSONString(jsonStr string) error {
+	groupRelayRateLimit = make(map[string]RelayRateLimit)
+	return json.Unmarshal([]byte(jsonStr), &groupRelayRateLimit)
+}
+
+func GetGroupRelayRateLimit(group string) RelayRateLimit {
+	return groupRelayRateLimit[group]
+}
+
+func CheckGroupRelayRateLimit(jsonStr string) error {
+	checkGroupRelayRateLimit := make(map[string]RelayRateLimit)
+	err := json.Unmarshal([]byte(jsonStr), &checkGroupRelayRateLimit)
+	if err != nil {
+		return err
+	}
+	for group, limit := range checkGroupRelayRateLimit {
+		if err = limit.check(); err != nil {
+			return errors.New("invalid rate limit for group " + group + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// CheckRelayRateLimit 校验令牌或用户的限制设置
+func CheckRelayRateLimit(jsonStr string) error {
+	var limit RelayRateLimit
+	if err := json.Unmarshal([]byte(jsonStr), &limit); err != nil {
+		return err
+	}
+	return limit.check()
+}
+
+// ParseRelayRateLimit 解析令牌或用户的限制设置，格式错误时视为不限制
+func ParseRelayRateLimit(jsonStr string) RelayRateLimit {
+	var limit RelayRateLimit
+	if jsonStr == "" {
+		return limit
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &limit); err != nil {
+		logging.SysError("failed to unmarshal relay rate limit: " + err.Error())
+	}
+	return limit
+}
@@ -0,0 +1,22 @@
+package setting
+
+import (
+	"one-api/common"
+	"one-api/constant"
+)
+
+// 上游请求的分段超时，单位秒，0 表示不限制；渠道可以在 Setting 中通过 connect_timeout、first_byte_timeout、
+// stream_idle_timeout、stream_keepalive_interval 单独设置。RELAY_TIMEOUT 仍然作为整个请求的总超时
+
+// RelayConnectTimeout 建立 TCP 连接的超时时间
+var RelayConnectTimeout = common.GetEnvOrDefault("RELAY_CONNECT_TIMEOUT", 30)
+
+// RelayFirstByteTimeout 发送请求后等待上游返回响应头的超时时间
+var RelayFirstByteTimeout = common.GetEnvOrDefault("RELAY_FIRST_BYTE_TIMEOUT", 0)
+
+// RelayStreamIdleTimeout 流式响应两次收到数据之间的最长间隔，超时后中断上游连接，
+// 尚未向客户端输出时可以重试其他渠道；设置为 0 时仍使用 STREAMING_TIMEOUT 兜底，不会无限等待
+var RelayStreamIdleTimeout = constant.StreamingTimeout
+
+// StreamKeepaliveInterval 上游长时间没有数据（例如推理模型思考）时，向客户端发送 SSE 注释保活的间隔
+var StreamKeepaliveInterval = common.GetEnvOrDefault("STREAM_KEEPALIVE_INTERVAL", 0)
@@ -0,0 +1,10 @@
+package setting
+
+// ResponseCacheEnabled 是否启用响应缓存，只缓存 temperature 为 0 或通过请求头主动开启的请求
+var ResponseCacheEnabled = false
+
+// ResponseCacheTTL 缓存有效期，单位秒
+var ResponseCacheTTL = 3600
+
+// ResponseCacheRatio 命中缓存时的计费倍率，叠加在分组倍率上
+var ResponseCacheRatio = 0.1
@@ -0,0 +1,5 @@
+package setting
+
+// StreamHoldFirstChunkEnabled 开启后，流式请求在收到上游第一个内容块之前不向客户端输出，
+// 此前出现的空响应、错误事件或连接中断可以像非流式请求一样重试其他渠道，代价是首字延迟略有增加
+var StreamHoldFirstChunkEnabled = false
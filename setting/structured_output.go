@@ -0,0 +1,6 @@
+package setting
+
+// StructuredOutputValidationEnabled 开启后，非流式 chat completions 请求指定 json_object 或 json_schema 时，
+// 在网关校验上游返回的 JSON，不符合时按上游错误处理并重试其他渠道，校验失败的响应不计费；
+// 没有可以重试的渠道时返回最后一次上游的响应。主要用于只能通过提示词模拟结构化输出的渠道
+var StructuredOutputValidationEnabled = false